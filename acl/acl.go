@@ -0,0 +1,224 @@
+// Access Control Lists for incoming requests
+
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+// Ops - The operations a peer can be allowed to request
+var Ops = []string{"get", "put", "delete", "getdir"}
+
+// Rule - An access control rule ready for matching
+type Rule struct {
+	Peer  *net.IPNet // Addresses the rule applies to, nil is any address
+	Eid   string     // Authenticated EID the rule applies to, "" is any peer
+	Ops   []string   // Operations allowed
+	Paths []string   // Cleaned path prefixes allowed, none is any path
+}
+
+// List - Access control list, the rules and what to do when none match
+type List struct {
+	Default bool   // Allow requests from peers that do not match any rule
+	Rules   []Rule // Rules in the order they were configured
+}
+
+// New - Build the access control list from the acl rules in the config
+func New(c *sarflags.Cliflags) (*List, error) {
+	l := new(List)
+
+	switch c.Acldefault {
+	case "", "allow": // No acl means everyone can do everything as they always could
+		l.Default = true
+	case "deny":
+		l.Default = false
+	default:
+		return nil, errors.New("acl: invalid default " + c.Acldefault + " must be allow or deny")
+	}
+
+	for i, r := range c.Acl {
+		var rule Rule
+		var err error
+
+		if rule.Peer, err = peernet(r.Peer); err != nil {
+			return nil, fmt.Errorf("acl: rule %d: %w", i, err)
+		}
+		rule.Eid = r.Eid
+		if len(r.Ops) == 0 {
+			return nil, fmt.Errorf("acl: rule %d: no ops given", i)
+		}
+		for _, op := range r.Ops {
+			if !validop(op) {
+				return nil, fmt.Errorf("acl: rule %d: invalid op %s", i, op)
+			}
+			rule.Ops = append(rule.Ops, op)
+		}
+		for _, p := range r.Paths {
+			rule.Paths = append(rule.Paths, Clean(p))
+		}
+		l.Rules = append(l.Rules, rule)
+	}
+	return l, nil
+}
+
+// Turn an IP address or CIDR into a network, "" is any address
+func peernet(peer string) (*net.IPNet, error) {
+	if peer == "" {
+		return nil, nil
+	}
+	if strings.Contains(peer, "/") {
+		_, ipnet, err := net.ParseCIDR(peer)
+		if err != nil {
+			return nil, errors.New("invalid peer CIDR " + peer)
+		}
+		return ipnet, nil
+	}
+	ip := net.ParseIP(peer)
+	if ip == nil {
+		return nil, errors.New("invalid peer address " + peer)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Is op one of the operations we control
+func validop(op string) bool {
+	for _, o := range Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Clean - Clean up a path so "../" cannot be used to walk out of a prefix
+// The result is rooted so it stays under whatever directory it is joined to
+func Clean(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Needs - The operations a request type needs to be allowed
+func Needs(reqtype string) []string {
+	switch reqtype {
+	case "get", "put", "delete", "getdir":
+		return []string{reqtype}
+	case "take": // get then delete
+		return []string{"get", "delete"}
+	case "give": // A put as far as we are concerned
+		return []string{"put"}
+	}
+	return nil
+}
+
+// Does the rule apply to the peer
+// An EID rule only matches a peer that authenticated as that EID, never one that just beaconed it
+func (r *Rule) matches(addr net.IP, eid string) bool {
+	if r.Peer != nil && (addr == nil || !r.Peer.Contains(addr)) {
+		return false
+	}
+	if r.Eid != "" && r.Eid != eid {
+		return false
+	}
+	return true
+}
+
+// Does the rule allow op on fname
+func (r *Rule) allows(op string, fname string) bool {
+	found := false
+	for _, o := range r.Ops {
+		if o == op {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if len(r.Paths) == 0 {
+		return true
+	}
+	p := Clean(fname)
+	for _, prefix := range r.Paths {
+		if prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed - Can the peer at addr authenticated as eid make a reqtype request for fname
+// eid is the EID whose key signed the request, "" if it was not signed with an EID key
+// If any rule matches the peer then one of them must allow every op the request needs
+// otherwise the default policy applies
+func (l *List) Allowed(addr net.IP, eid string, reqtype string, fname string) bool {
+	if l == nil { // No acl set up so no restrictions
+		return true
+	}
+	ops := Needs(reqtype)
+	if ops == nil { // noaction or something we do not know about
+		return reqtype == "noaction"
+	}
+
+	matched := false
+	for _, op := range ops {
+		allowed := false
+		for i := range l.Rules {
+			if !l.Rules[i].matches(addr, eid) {
+				continue
+			}
+			matched = true
+			if l.Rules[i].allows(op, fname) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed && matched {
+			return false
+		}
+	}
+	if !matched {
+		return l.Default
+	}
+	return true
+}
+
+// Print - The effective access control rules
+func (l *List) Print() string {
+	if l == nil {
+		return "No access control, all requests allowed"
+	}
+	var sbuf string
+	if l.Default {
+		sbuf = "Default: allow peers not matching a rule\n"
+	} else {
+		sbuf = "Default: deny peers not matching a rule\n"
+	}
+	if len(l.Rules) == 0 {
+		return sbuf + "No rules"
+	}
+	for i, r := range l.Rules {
+		peer := "any"
+		if r.Peer != nil {
+			peer = r.Peer.String()
+		}
+		eid := "any"
+		if r.Eid != "" {
+			eid = r.Eid
+		}
+		paths := "any"
+		if len(r.Paths) > 0 {
+			paths = strings.Join(r.Paths, ",")
+		}
+		sbuf += fmt.Sprintf("%d: peer:%s eid:%s ops:%s paths:%s\n", i, peer, eid,
+			strings.Join(r.Ops, ","), paths)
+	}
+	return strings.TrimRight(sbuf, "\n")
+}
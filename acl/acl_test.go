@@ -0,0 +1,87 @@
+package acl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+func TestAcl(t *testing.T) {
+	cmdptr := new(sarflags.Cliflags)
+	cmdptr.Acldefault = "deny"
+	cmdptr.Acl = []sarflags.Aclrule{
+		{Peer: "10.1.0.0/16", Ops: []string{"get", "getdir"}, Paths: []string{"pub"}},
+		{Peer: "10.1.2.3", Ops: []string{"put", "delete"}, Paths: []string{"/incoming"}},
+		{Eid: "dtn://ground", Ops: []string{"get", "put", "delete", "getdir"}},
+	}
+
+	l, err := New(cmdptr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(l.Print())
+
+	tests := []struct {
+		addr    string
+		eid     string
+		reqtype string
+		fname   string
+		want    bool
+	}{
+		{"10.1.9.9", "", "get", "pub/file", true},
+		{"10.1.9.9", "", "get", "/pub", true},
+		{"10.1.9.9", "", "getdir", "pub/dir", true},
+		{"10.1.9.9", "", "get", "public/file", false},
+		{"10.1.9.9", "", "get", "pub/../secret", false},
+		{"10.1.9.9", "", "put", "pub/file", false},
+		{"10.1.2.3", "", "put", "incoming/file", true},
+		{"10.1.2.3", "", "give", "incoming/file", true},
+		{"10.1.2.3", "", "take", "pub/file", false}, // get allowed but not delete
+		{"10.1.2.3", "", "take", "incoming/file", false},
+		{"10.2.0.1", "", "get", "pub/file", false}, // default deny
+		{"10.2.0.1", "dtn://ground", "take", "any/file", true},
+		{"fe80::1", "dtn://ground", "delete", "file", true},
+		{"fe80::1", "dtn://other", "get", "file", false},
+		{"fe80::1", "", "get", "file", false}, // unauthenticated so not dtn://ground whatever it beacons
+		{"10.2.0.1", "", "noaction", "", true},
+	}
+	for _, tt := range tests {
+		if got := l.Allowed(net.ParseIP(tt.addr), tt.eid, tt.reqtype, tt.fname); got != tt.want {
+			t.Errorf("Allowed(%s, %q, %s, %s) = %v want %v", tt.addr, tt.eid, tt.reqtype, tt.fname, got, tt.want)
+		}
+	}
+
+	// The path the acl checks is the one the file operations use and it cannot leave the root
+	for fname, want := range map[string]string{
+		"../../home/x": "/home/x", "pub/../../etc": "/etc", "/incoming/./f": "/incoming/f", "": "/",
+	} {
+		if got := Clean(fname); got != want {
+			t.Errorf("Clean(%s) = %s want %s", fname, got, want)
+		}
+	}
+
+	// No acl means no restrictions
+	var none *List
+	if !none.Allowed(net.ParseIP("10.2.0.1"), "", "delete", "file") {
+		t.Error("nil acl should allow everything")
+	}
+
+	bad := []sarflags.Aclrule{
+		{Peer: "10.1.0.0/33", Ops: []string{"get"}},
+		{Peer: "not.an.address", Ops: []string{"get"}},
+		{Peer: "10.1.0.1", Ops: []string{"take"}},
+		{Peer: "10.1.0.1"},
+	}
+	for _, r := range bad {
+		cmdptr.Acl = []sarflags.Aclrule{r}
+		if _, err := New(cmdptr); err == nil {
+			t.Errorf("New accepted invalid rule %+v", r)
+		}
+	}
+	cmdptr.Acl = nil
+	cmdptr.Acldefault = "maybe"
+	if _, err := New(cmdptr); err == nil {
+		t.Error("New accepted invalid default")
+	}
+}
@@ -0,0 +1,266 @@
+// Request authentication using pre-shared keys
+
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The Auth field of a request is laid out as
+// Timestamp (8 bytes nanosecs since the epoch) | Nonce (8 bytes) | HMAC-SHA256 (32 bytes)
+// The HMAC covers the request header, session, filename, its terminating null, timestamp and nonce
+const (
+	tslen    = 8
+	noncelen = 8
+	maclen   = sha256.Size
+	// Len - Length of the Auth field in a request
+	Len = tslen + noncelen + maclen
+)
+
+// Salt - The timestamp and nonce of a requests Auth field, nil if it has none
+// They are different for every request and covered by its hmac so salt the keys of encrypted transfers
+func Salt(auth []byte) []byte {
+	if len(auth) != Len {
+		return nil
+	}
+	return auth[:tslen+noncelen]
+}
+
+// Errors returned when a request fails authentication
+var (
+	ErrNoKey    = errors.New("auth: no key for peer")
+	ErrNoAuth   = errors.New("auth: request not authenticated")
+	ErrBadLen   = errors.New("auth: invalid auth field length")
+	ErrBadMac   = errors.New("auth: invalid hmac")
+	ErrStale    = errors.New("auth: timestamp outside window")
+	ErrReplayed = errors.New("auth: request replayed")
+)
+
+// DefaultWindow - How far either side of now a request timestamp can be when none is configured
+const DefaultWindow = 300 * time.Second
+
+// Keys - Per peer pre-shared keys and the replay window
+type Keys struct {
+	mu       sync.Mutex
+	keys     map[string][]byte            // Keyed by peer IP address or EID
+	Window   time.Duration                // How old or new a request timestamp can be
+	Required bool                         // Must every request be authenticated
+	seen     map[string]map[[16]byte]bool // Timestamp & nonce seen from each peer within the window
+	pruned   map[string]time.Time         // When we last pruned seen for each peer
+}
+
+// New - An empty key store
+func New(window time.Duration, required bool) *Keys {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Keys{
+		keys:     make(map[string][]byte),
+		Window:   window,
+		Required: required,
+		seen:     make(map[string]map[[16]byte]bool),
+		pruned:   make(map[string]time.Time),
+	}
+}
+
+// Load - Read the key file, each line is a peer IP address or EID followed by its key in hex
+// Blank lines and lines starting with # are ignored
+func Load(fname string, window time.Duration, required bool) (*Keys, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	k := New(window, required)
+	scanner := bufio.NewScanner(fp)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		f := strings.Fields(s)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("auth: %s line %d: expected <peer> <hexkey>", fname, line)
+		}
+		key, err := hex.DecodeString(f[1])
+		if err != nil || len(key) < 16 {
+			return nil, fmt.Errorf("auth: %s line %d: key must be at least 16 bytes of hex", fname, line)
+		}
+		k.Add(f[0], key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add - Add or replace the key for a peer IP address or EID
+func (k *Keys) Add(peer string, key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if ip := net.ParseIP(peer); ip != nil { // Use the same form as net.IP.String()
+		peer = ip.String()
+	}
+	k.keys[peer] = append([]byte(nil), key...)
+}
+
+// Key - The key for a peer, the EID key if eid is given otherwise the address key
+// The eid must be one the peer has proved it is or one we chose to reach, never one it just claims
+func (k *Keys) Key(addr net.IP, eid string) []byte {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if eid != "" {
+		return k.keys[eid]
+	}
+	if addr != nil {
+		return k.keys[addr.String()]
+	}
+	return nil
+}
+
+// The EIDs we hold keys for and their keys
+func (k *Keys) eidkeys() map[string][]byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := make(map[string][]byte)
+	for peer, key := range k.keys {
+		if net.ParseIP(peer) == nil {
+			keys[peer] = key
+		}
+	}
+	return keys
+}
+
+// Calculate the hmac over the request fields
+func mac(key []byte, header uint32, session uint32, fname string, tsnonce []byte) []byte {
+	var hs [8]byte
+
+	m := hmac.New(sha256.New, key)
+	binary.BigEndian.PutUint32(hs[:4], header)
+	binary.BigEndian.PutUint32(hs[4:], session)
+	m.Write(hs[:])
+	m.Write([]byte(fname))
+	m.Write([]byte{0})
+	m.Write(tsnonce)
+	return m.Sum(nil)
+}
+
+// Sign - Create the Auth field for a request with the current time and a random nonce
+func Sign(key []byte, header uint32, session uint32, fname string) ([]byte, error) {
+	return sign(key, header, session, fname, time.Now())
+}
+
+func sign(key []byte, header uint32, session uint32, fname string, now time.Time) ([]byte, error) {
+	auth := make([]byte, Len)
+	binary.BigEndian.PutUint64(auth[:tslen], uint64(now.UnixNano()))
+	if _, err := rand.Read(auth[tslen : tslen+noncelen]); err != nil {
+		return nil, err
+	}
+	copy(auth[tslen+noncelen:], mac(key, header, session, fname, auth[:tslen+noncelen]))
+	return auth, nil
+}
+
+// Sign - Create the Auth field for a request to the peer, nil if we have no key for it
+// The address key is used if we have one, otherwise the key for eid if it is given
+func (k *Keys) Sign(addr net.IP, eid string, header uint32, session uint32, fname string) ([]byte, error) {
+	key := k.Key(addr, "")
+	if key == nil && eid != "" {
+		key = k.Key(nil, eid)
+	}
+	if key == nil {
+		return nil, nil
+	}
+	return Sign(key, header, session, fname)
+}
+
+// Verify - Check the Auth field of a request received from the peer at addr
+// Which key is checked depends only on the address and the request, never on what a beacon claimed
+// A peer we hold an address key for must use it, otherwise the EID whose key the request
+// is signed with is who it is and is returned. Unsigned requests are only accepted when
+// authentication is not Required and are from no EID
+func (k *Keys) Verify(addr net.IP, header uint32, session uint32, fname string, auth []byte) (string, error) {
+	return k.verify(addr, header, session, fname, auth, time.Now())
+}
+
+func (k *Keys) verify(addr net.IP, header uint32, session uint32, fname string, auth []byte, now time.Time) (string, error) {
+	if k == nil { // No key store so no authentication
+		return "", nil
+	}
+	if key := k.Key(addr, ""); key != nil {
+		return "", k.check(key, addr.String(), header, session, fname, auth, now)
+	}
+	if len(auth) == 0 {
+		if k.Required {
+			return "", ErrNoKey
+		}
+		return "", nil
+	}
+	if len(auth) != Len {
+		return "", ErrBadLen
+	}
+	for eid, key := range k.eidkeys() {
+		if hmac.Equal(auth[tslen+noncelen:], mac(key, header, session, fname, auth[:tslen+noncelen])) {
+			return eid, k.check(key, eid, header, session, fname, auth, now)
+		}
+	}
+	if k.Required {
+		return "", ErrNoKey
+	}
+	return "", nil // Signed with a key we do not share, so as good as unsigned
+}
+
+// Check the auth field was signed with key within the window and is not a replay from peer
+func (k *Keys) check(key []byte, peer string, header uint32, session uint32, fname string, auth []byte, now time.Time) error {
+	if len(auth) == 0 {
+		return ErrNoAuth
+	}
+	if len(auth) != Len {
+		return ErrBadLen
+	}
+	if !hmac.Equal(auth[tslen+noncelen:], mac(key, header, session, fname, auth[:tslen+noncelen])) {
+		return ErrBadMac
+	}
+	ts := time.Unix(0, int64(binary.BigEndian.Uint64(auth[:tslen])))
+	if ts.Before(now.Add(-k.Window)) || ts.After(now.Add(k.Window)) {
+		return ErrStale
+	}
+
+	// Only a valid mac gets this far so we only remember genuine requests
+	var id [16]byte
+	copy(id[:], auth[:tslen+noncelen])
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// Anything older than the window is rejected as stale so forget it every so often
+	if now.Sub(k.pruned[peer]) > 2*k.Window {
+		for s := range k.seen[peer] {
+			if time.Unix(0, int64(binary.BigEndian.Uint64(s[:tslen]))).Before(now.Add(-k.Window)) {
+				delete(k.seen[peer], s)
+			}
+		}
+		k.pruned[peer] = now
+	}
+	if k.seen[peer] == nil {
+		k.seen[peer] = make(map[[16]byte]bool)
+	}
+	if k.seen[peer][id] {
+		return ErrReplayed
+	}
+	k.seen[peer][id] = true
+	return nil
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	kfile := filepath.Join(t.TempDir(), "saratoga.keys")
+	keys := "# Test keys\n" +
+		"10.1.2.3 000102030405060708090a0b0c0d0e0f\n" +
+		"\n" +
+		"dtn://ground 0f0e0d0c0b0a09080706050403020100\n"
+	if err := os.WriteFile(kfile, []byte(keys), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := Load(kfile, 10*time.Second, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := net.ParseIP("10.1.2.3")
+	now := time.Now()
+	header := uint32(0x20000001)
+	a, err := sign(k.Key(peer, ""), header, 1234, "file", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != Len {
+		t.Fatalf("auth length %d want %d", len(a), Len)
+	}
+	b, _ := sign(k.Key(peer, ""), header, 1234, "file", now)
+	if len(Salt(a)) != tslen+noncelen || bytes.Equal(Salt(a), Salt(b)) || Salt(nil) != nil {
+		t.Error("salt not different for each request")
+	}
+	if _, err := k.verify(peer, header, 1234, "file", a, now); err != nil {
+		t.Fatal("valid request rejected:", err)
+	}
+	if _, err := k.verify(peer, header, 1234, "file", a, now); err != ErrReplayed {
+		t.Error("replay not detected:", err)
+	}
+
+	// Anything changed in the request must fail
+	a, _ = sign(k.Key(peer, ""), header, 1234, "file", now)
+	if _, err := k.verify(peer, header, 1234, "other", a, now); err != ErrBadMac {
+		t.Error("changed filename accepted:", err)
+	}
+	if _, err := k.verify(peer, header, 1235, "file", a, now); err != ErrBadMac {
+		t.Error("changed session accepted:", err)
+	}
+	if _, err := k.verify(peer, header^1, 1234, "file", a, now); err != ErrBadMac {
+		t.Error("changed header accepted:", err)
+	}
+	if _, err := k.verify(peer, header, 1234, "file", a[:Len-1], now); err != ErrBadLen {
+		t.Error("short auth accepted:", err)
+	}
+	if _, err := k.verify(peer, header, 1234, "file", nil, now); err != ErrNoAuth {
+		t.Error("missing auth accepted from keyed peer:", err)
+	}
+	if _, err := k.verify(peer, header, 1234, "file", a, now.Add(time.Minute)); err != ErrStale {
+		t.Error("stale auth accepted:", err)
+	}
+
+	// A peer with an address key must use it, whatever EID it claims
+	a, _ = k.Sign(peer, "dtn://ground", header, 1, "file")
+	if eid, err := k.Verify(peer, header, 1, "file", a); err != nil || eid != "" {
+		t.Error("address keyed request rejected:", eid, err)
+	}
+	a, _ = Sign(k.Key(nil, "dtn://ground"), header, 2, "file")
+	if _, err := k.Verify(peer, header, 2, "file", a); err != ErrBadMac {
+		t.Error("eid key accepted for address key:", err)
+	}
+
+	// Anyone else is the EID whose key they signed with, or no one
+	other := net.ParseIP("10.9.9.9")
+	a, _ = k.Sign(other, "dtn://ground", header, 3, "file")
+	if eid, err := k.Verify(other, header, 3, "file", a); err != nil || eid != "dtn://ground" {
+		t.Error("eid keyed request not from its eid:", eid, err)
+	}
+	if _, err := k.Verify(other, header, 3, "file", a); err != ErrReplayed {
+		t.Error("eid keyed replay not detected:", err)
+	}
+	a, _ = Sign([]byte("not a key we share"), header, 4, "file")
+	if eid, err := k.Verify(other, header, 4, "file", a); err != nil || eid != "" {
+		t.Error("unknown key not treated as unsigned:", eid, err)
+	}
+
+	// Peers without keys are only accepted when authentication is not required
+	if eid, err := k.Verify(other, header, 1, "file", nil); err != nil || eid != "" {
+		t.Error("unkeyed peer rejected:", err)
+	}
+	k.Required = true
+	if _, err := k.Verify(other, header, 1, "file", nil); err != ErrNoKey {
+		t.Error("unkeyed peer accepted when required:", err)
+	}
+	if _, err := k.Verify(other, header, 4, "file", a); err != ErrNoKey {
+		t.Error("unknown key accepted when required:", err)
+	}
+	var none *Keys
+	if _, err := none.Verify(other, header, 1, "file", nil); err != nil {
+		t.Error("nil key store rejected request:", err)
+	}
+
+	if err := os.WriteFile(kfile, []byte("10.1.2.3 nothex\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(kfile, 0, false); err == nil {
+		t.Error("invalid key file accepted")
+	}
+}
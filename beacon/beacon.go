@@ -8,13 +8,12 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"reflect"
 	"strings"
-	"sync"
 	"syscall"
 
+	"github.com/charlesetsmith/saratoga/frames"
+	"github.com/charlesetsmith/saratoga/metrics"
 	"github.com/charlesetsmith/saratoga/sarflags"
-	"github.com/charlesetsmith/saratoga/timestamp"
 )
 
 // Beacon -- Holds Beacon frame information
@@ -28,6 +27,8 @@ type Binfo struct {
 	Eid       string
 }
 
+var _ frames.Frame[Binfo] = (*Beacon)(nil)
+
 type Packet struct {
 	Addr net.UDPAddr
 	Info Beacon
@@ -40,7 +41,7 @@ func (b *Beacon) Val(addr *net.UDPAddr) Packet {
 }
 
 // New - Construct a beacon - Fill in the Beacon struct
-func (b *Beacon) New(flags string, info interface{}) error {
+func (b *Beacon) New(flags string, info *Binfo) error {
 	var err error
 
 	// Always present in a Beacon
@@ -84,13 +85,12 @@ func (b *Beacon) New(flags string, info interface{}) error {
 			return errors.New(e)
 		}
 	}
-	e := reflect.ValueOf(info).Elem()
 	// Set the Eid to what is passed in from binfo (normally "")
-	b.Eid = e.FieldByName("Eid").String()
+	b.Eid = info.Eid
 
 	if sarflags.GetStr(b.Header, "freespace") == "yes" {
-		// Assign the values from the interface Dinfo structure
-		b.Freespace = e.FieldByName("Freespace").Uint()
+		// Assign the values from the Binfo structure
+		b.Freespace = info.Freespace
 
 		// Ignore if freespaced is set, just set it to the correct size
 		var fs syscall.Statfs_t
@@ -134,8 +134,7 @@ func (b *Beacon) New(flags string, info interface{}) error {
 }
 
 // Make - Construct a beacon with a given header - return byte slice of frame
-// func (b *Beacon) Make(header uint32, eid string, freespace uint64) error {
-func (b *Beacon) Make(header uint32, info interface{}) error {
+func (b *Beacon) Make(header uint32, info *Binfo) error {
 	var err error
 
 	// Always present in a Beacon
@@ -148,13 +147,12 @@ func (b *Beacon) Make(header uint32, info interface{}) error {
 	}
 	b.Header = header
 
-	e := reflect.ValueOf(info).Elem()
 	// Set the Eid to what is passed in from binfo (normally "")
-	b.Eid = e.FieldByName("Eid").String()
+	b.Eid = info.Eid
 
 	if sarflags.GetStr(b.Header, "freespace") == "yes" {
-		// Assign the values from the interface Dinfo structure
-		b.Freespace = e.FieldByName("Freespace").Uint()
+		// Assign the values from the Binfo structure
+		b.Freespace = info.Freespace
 
 		// Ignore if freespaced is set, just set it to the correct size
 		var fs syscall.Statfs_t
@@ -196,6 +194,24 @@ func (b *Beacon) Make(header uint32, info interface{}) error {
 	return nil
 }
 
+// New - A beacon from flags and info, see Beacon.New
+func New(flags string, info Binfo) (*Beacon, error) {
+	b := new(Beacon)
+	if err := b.New(flags, &info); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Make - A beacon with a given header and info, see Beacon.Make
+func Make(header uint32, info Binfo) (*Beacon, error) {
+	b := new(Beacon)
+	if err := b.Make(header, &info); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
 // Put -- Encode the Saratoga Beacon into a Frame buffer
 func (b Beacon) Encode() ([]byte, error) {
 
@@ -317,69 +333,10 @@ func (b Beacon) Send(conn *net.UDPConn) error {
 	if wlen, err = conn.Write(buf); err != nil {
 		return err
 	}
+	metrics.Sent("beacon", conn, nil, wlen)
 	if wlen != len(buf) {
 		return fmt.Errorf("Beacon sent (%d) to %s != frame size (%d)",
 			wlen, conn.RemoteAddr().String(), len(buf))
 	}
 	return nil
 }
-
-// Peer - beacon peer
-type Peer struct {
-	Addr      string              // The Peer IP Address. is format net.UDPAddr.IP.String()
-	Freespace uint64              // 0 if freespace not advertised
-	Eid       string              // Exactly who sent this and from what PID
-	Maxdesc   string              // The maximum descriptor size of the peer
-	Canrx     string              // Files can be received
-	Cantx     string              // Files can be transmitted
-	Created   timestamp.Timestamp // When was this Peer created
-	Updated   timestamp.Timestamp // When was this Peer last updated
-}
-
-var pmu sync.Mutex // Protect Peers
-// Peers - Slices of unique Peer information learned from beacons
-var Peers []Peer
-
-// NewPeer - Add/Change peer info from received beacon
-func NewPeer(b *Beacon, from *net.UDPAddr) bool {
-	if from == nil {
-		return false
-	}
-	// Scan through existing Peers and change if the peer exists
-	for p := range Peers {
-		if Peers[p].Addr == from.IP.String() { // Source Ip Address matches existing Peer
-			pmu.Lock()
-			defer pmu.Unlock()
-			// Has anything changed since the last beacon for this peer ?
-			if Peers[p].Freespace != b.Freespace ||
-				Peers[p].Eid != b.Eid ||
-				Peers[p].Maxdesc != sarflags.GetStr(b.Header, "descriptor") ||
-				Peers[p].Canrx != sarflags.GetStr(b.Header, "rxwilling") ||
-				Peers[p].Cantx != sarflags.GetStr(b.Header, "txwilling") {
-				Peers[p].Freespace = b.Freespace
-				Peers[p].Eid = b.Eid
-				Peers[p].Maxdesc = sarflags.GetStr(b.Header, "descriptor")
-				Peers[p].Canrx = sarflags.GetStr(b.Header, "rxwilling")
-				Peers[p].Cantx = sarflags.GetStr(b.Header, "txwilling")
-				Peers[p].Updated.Now("posix32_32") // Last updated now
-				return true
-			}
-			return false
-		}
-	}
-	// We have a new Peer - add it
-	// var newp Peer
-	newp := new(Peer)
-	newp.Addr = from.IP.String()
-	newp.Freespace = b.Freespace
-	newp.Eid = b.Eid
-	newp.Maxdesc = sarflags.GetStr(b.Header, "descriptor")
-	newp.Canrx = sarflags.GetStr(b.Header, "rxwilling")
-	newp.Cantx = sarflags.GetStr(b.Header, "txwilling")
-	newp.Created.Now("posix32_32")
-	newp.Updated = newp.Created
-	pmu.Lock()
-	defer pmu.Unlock()
-	Peers = append(Peers, *newp)
-	return true
-}
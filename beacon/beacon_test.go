@@ -0,0 +1,391 @@
+package beacon
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+func TestScheduler(t *testing.T) {
+	laddr, _ := net.ResolveUDPAddr("udp4", "127.0.0.1:0")
+	conn, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	addr := conn.LocalAddr().(*net.UDPAddr)
+
+	var mu sync.Mutex
+	txwilling := "yes"
+	flags := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return "descriptor=d32,freespace=no,txwilling=" + txwilling
+	}
+	sent := make(chan error, 100)
+	sc := NewScheduler(flags, func(s Schedule, b *Beacon, err error) { sent <- err })
+
+	// Counted schedule stops by itself and flags are rebuilt for every beacon
+	if err := sc.Start("local", addr, 3, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	for i := 0; i < 3; i++ {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b Beacon
+		if err := b.Decode(buf[:n]); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		if got := sarflags.GetStr(b.Header, "txwilling"); got != txwilling {
+			t.Errorf("beacon %d txwilling %s want %s", i, got, txwilling)
+		}
+		txwilling = "no"
+		mu.Unlock()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-sent; err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sc.List()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(sc.List()) != 0 {
+		t.Fatal("counted schedule still running")
+	}
+
+	// Forever schedules run until they are stopped
+	if err := sc.Start("v4", addr, 0, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Start("other", addr, 0, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	list := sc.List()
+	if len(list) != 2 || !list[0].Forever() || list[0].Dest != "other" {
+		t.Fatalf("schedules %+v", list)
+	}
+	if !sc.Stop("v4") || sc.Stop("v4") {
+		t.Error("stop of v4 schedule")
+	}
+	sc.StopAll()
+	if len(sc.List()) != 0 {
+		t.Error("schedules left after StopAll")
+	}
+	// Nothing more is sent once stopped
+	for len(sent) > 0 {
+		<-sent
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(sent) != 0 {
+		t.Error("beacons sent after stop")
+	}
+}
+
+// Starts racing for the same destination leave just the one schedule sending
+func TestSchedulerRestart(t *testing.T) {
+	laddr, _ := net.ResolveUDPAddr("udp4", "127.0.0.1:0")
+	conn, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	addr := conn.LocalAddr().(*net.UDPAddr)
+
+	sent := make(chan error, 1000)
+	sc := NewScheduler(func() string { return "descriptor=d32,freespace=no" },
+		func(s Schedule, b *Beacon, err error) { sent <- err })
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sc.Start("v4", addr, 0, 5*time.Millisecond); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if list := sc.List(); len(list) != 1 {
+		t.Fatalf("schedules %+v", list)
+	}
+	sc.StopAll()
+	for len(sent) > 0 {
+		<-sent
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(sent) != 0 {
+		t.Error("replaced schedule still sending after StopAll")
+	}
+}
+
+func TestPeerAging(t *testing.T) {
+	r := NewRegistry()
+	events, cancel := r.Subscribe(10)
+	defer cancel()
+	next := func(want EventType) Event {
+		select {
+		case ev := <-events:
+			if ev.Type != want {
+				t.Fatalf("event %s want %s", ev.Type, want)
+			}
+			return ev
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event", want)
+		}
+		return Event{}
+	}
+	beacon := func(txwilling string) *Beacon {
+		b := new(Beacon)
+		if err := b.New("descriptor=d32,freespace=no,txwilling="+txwilling, &Binfo{Eid: "dtn://peer"}); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	from := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 7542}
+
+	if !r.Update(beacon("yes"), from) {
+		t.Error("new peer not reported")
+	}
+	if ev := next(PeerJoined); ev.Peer.State != Active || ev.Peer.Addr != "192.0.2.1" {
+		t.Errorf("joined %+v", ev.Peer)
+	}
+	// Same beacon again only refreshes last seen
+	if r.Update(beacon("yes"), from) {
+		t.Error("unchanged peer reported")
+	}
+	if r.Update(beacon("no"), from) != true {
+		t.Error("changed peer not reported")
+	}
+	next(PeerChanged)
+
+	p, ok := r.ByAddr(from.IP)
+	if !ok || p.Cantx != "no" {
+		t.Fatalf("peer info %+v", p)
+	}
+	// Not yet expired, then expired once only, and 0 never expires
+	r.Expire(p.LastSeen.Add(time.Second), 2*time.Second)
+	r.Expire(p.LastSeen.Add(time.Hour), 0)
+	r.Expire(p.LastSeen.Add(3*time.Second), 2*time.Second)
+	r.Expire(p.LastSeen.Add(4*time.Second), 2*time.Second)
+	if ev := next(PeerLost); ev.Peer.State != Lost {
+		t.Errorf("lost %+v", ev.Peer)
+	}
+	if len(events) != 0 {
+		t.Error("unexpected events", len(events))
+	}
+	if peers := r.Snapshot(); len(peers) != 1 || peers[0].State != Lost {
+		t.Errorf("peers %+v", peers)
+	}
+	// Heard from again
+	if !r.Update(beacon("no"), from) {
+		t.Error("rejoined peer not reported")
+	}
+	next(PeerJoined)
+
+	// Aging runs until cancelled
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		r.Aging(ctx, time.Millisecond, func() time.Duration { return time.Nanosecond })
+		done <- true
+	}()
+	next(PeerLost)
+	stop()
+	<-done
+}
+
+func TestPeerEid(t *testing.T) {
+	r := NewRegistry()
+	beacon := func(eid string) *Beacon {
+		b := new(Beacon)
+		if err := b.New("descriptor=d32,freespace=no", &Binfo{Eid: eid}); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	v4 := &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 7542}
+	v6 := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 7542}
+	nat := &net.UDPAddr{IP: net.ParseIP("198.51.100.2"), Port: 7542}
+
+	// One dual stack node is one peer with both addresses
+	r.Update(beacon("dtn://dual"), v4)
+	if !r.Update(beacon("dtn://dual"), v6) {
+		t.Error("new address not reported as a change")
+	}
+	p, ok := r.ByEid("dtn://dual")
+	if !ok || len(p.Addrs) != 2 || p.Addr != v4.IP.String() {
+		t.Fatalf("dual stack peer %+v", p)
+	}
+	// Still there while it only beacons from its other address
+	r.Expire(p.LastSeen.Add(3*time.Second), 2*time.Second)
+	if !r.Update(beacon("dtn://dual"), v6) {
+		t.Error("peer heard on its other address not reported as rejoined")
+	}
+	heard := time.Now()
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		r.Update(beacon("dtn://dual"), v6)
+		r.Expire(time.Now().Add(time.Second), 2*time.Second)
+	}
+	if p, _ := r.ByEid("dtn://dual"); p.State != Active || !p.LastSeen.After(heard) || p.Addr != v4.IP.String() {
+		t.Errorf("peer beaconing from its other address %+v", p)
+	}
+
+	// Beaconing an EID from elsewhere does not move where we reach it, authenticating does
+	spoof := &net.UDPAddr{IP: net.ParseIP("203.0.113.9"), Port: 7542}
+	r.Update(beacon("dtn://dual"), spoof)
+	if p, _ := r.ByEid("dtn://dual"); p.Addr != v4.IP.String() {
+		t.Errorf("beacon from %s moved peer to %s", spoof.IP, p.Addr)
+	}
+	if !r.Confirm("dtn://dual", v6.IP) || r.Confirm("dtn://unheard", v6.IP) {
+		t.Error("confirm of heard or unheard peer wrong")
+	}
+	if p, _ = r.ByEid("dtn://dual"); p.Addr != v6.IP.String() {
+		t.Errorf("confirmed peer at %s want %s", p.Addr, v6.IP)
+	}
+	for _, a := range []*net.UDPAddr{v4, v6} {
+		if r.eid(a.IP) != "dtn://dual" {
+			t.Errorf("%s eid %q", a.IP, r.eid(a.IP))
+		}
+	}
+
+	// Two nodes behind one address are two peers, the address is the last one heard
+	r.Update(beacon("ipn:1.0"), nat)
+	r.Update(beacon("ipn:2.0"), nat)
+	if _, ok := r.ByEid("ipn:1.0"); !ok {
+		t.Error("first node behind nat lost")
+	}
+	if r.eid(nat.IP) != "ipn:2.0" {
+		t.Errorf("nat eid %q", r.eid(nat.IP))
+	}
+	// Copies do not share addresses with the table
+	p.Addrs[0] = "changed"
+	if q, _ := r.ByEid("dtn://dual"); q.Addrs[0] == "changed" {
+		t.Error("peer copy shares addresses")
+	}
+}
+
+// The EID of the peer at addr
+func (r *Registry) eid(addr net.IP) string {
+	p, _ := r.ByAddr(addr)
+	return p.Eid
+}
+
+// Beacons arriving on the v4 and v6 listeners at once while peers are listed, looked up and aged
+// Run with go test -race
+func TestPeerRegistryRace(t *testing.T) {
+	const nodes = 10
+	const beacons = 200
+	r := NewRegistry()
+	events, cancel := r.Subscribe(8)
+	var drained sync.WaitGroup
+	drained.Add(1)
+	go func() {
+		defer drained.Done()
+		for range events {
+		}
+	}()
+
+	// Decode each beacon off the wire as the listeners do
+	frame := func(eid string, txwilling string) *Beacon {
+		var b, rx Beacon
+		if err := b.New("descriptor=d32,freespace=no,txwilling="+txwilling, &Binfo{Eid: eid}); err != nil {
+			t.Error(err)
+			return nil
+		}
+		buf, err := b.Encode()
+		if err == nil {
+			err = rx.Decode(buf)
+		}
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		return &rx
+	}
+	eid := func(n int) string { return "ipn:" + strconv.Itoa(n+1) + ".0" }
+	v4 := func(n int) net.IP { return net.IPv4(10, 0, 0, byte(n+1)) }
+	v6 := func(n int) net.IP { return net.ParseIP("2001:db8::" + strconv.Itoa(n+1)) }
+
+	var listeners, background sync.WaitGroup
+	listener := func(addr func(int) net.IP) {
+		defer listeners.Done()
+		for i := 0; i < beacons; i++ {
+			n := i % nodes
+			txwilling := "yes"
+			if i%3 == 0 {
+				txwilling = "no"
+			}
+			if b := frame(eid(n), txwilling); b != nil {
+				r.Update(b, &net.UDPAddr{IP: addr(n), Port: 7542})
+			}
+		}
+	}
+	stop := make(chan struct{})
+	query := func() {
+		defer background.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// Snapshots are ours to change
+			for _, p := range r.Snapshot() {
+				p.Addrs = append(p.Addrs[:0], "scribble")
+				p.State = "scribble"
+			}
+			r.ByAddr(v4(3))
+			r.ByEid(eid(5))
+			r.Len()
+		}
+	}
+	age := func() {
+		defer background.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				r.Expire(time.Now(), time.Nanosecond)
+			}
+		}
+	}
+	listeners.Add(2)
+	go listener(v4)
+	go listener(v6)
+	background.Add(4)
+	go query()
+	go query()
+	go query()
+	go age()
+
+	listeners.Wait()
+	close(stop)
+	background.Wait()
+	cancel()
+	drained.Wait()
+
+	peers := r.Snapshot()
+	if len(peers) != nodes {
+		t.Fatalf("%d peers want %d", len(peers), nodes)
+	}
+	for _, p := range peers {
+		if len(p.Addrs) != 2 || p.State == "scribble" {
+			t.Errorf("peer %s addrs %v state %s", p.Eid, p.Addrs, p.State)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Beacon Peers - What we have learned from the beacons other nodes send us
+
+package beacon
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/timestamp"
+)
+
+// Peer - beacon peer, identified by its EID or by its address if it does not send one
+type Peer struct {
+	Addr      string              // Address we reach the Peer at, format net.UDPAddr.IP.String()
+	Addrs     []string            // Every v4 and v6 address we have heard this Peer from
+	Freespace uint64              // 0 if freespace not advertised
+	Eid       string              // The node identity of the Peer
+	Maxdesc   string              // The maximum descriptor size of the peer
+	Canrx     string              // Files can be received
+	Cantx     string              // Files can be transmitted
+	Created   timestamp.Timestamp // When was this Peer created
+	Updated   timestamp.Timestamp // When was this Peer last updated
+	LastSeen  time.Time           // When did we last receive a beacon from this Peer
+	State     string              // Are we hearing its beacons: active,lost
+}
+
+// Peer states
+const (
+	Active = "active" // Beacons are being received
+	Lost   = "lost"   // No beacons received within the expiry time
+)
+
+// Age - How long since we last heard a beacon from the peer
+func (p *Peer) Age(now time.Time) time.Duration {
+	return now.Sub(p.LastSeen)
+}
+
+// HasAddr - Have we heard the peer from addr
+func (p *Peer) HasAddr(addr string) bool {
+	for _, a := range p.Addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Copy of the peer not sharing its Addrs
+func (p *Peer) copy() Peer {
+	c := *p
+	c.Addrs = append([]string(nil), p.Addrs...)
+	return c
+}
+
+// EventType - What has happened to a peer
+type EventType int
+
+// Peer events
+const (
+	PeerJoined  EventType = iota // New peer or a lost peer heard from again
+	PeerChanged                  // Peer beaconed different information
+	PeerLost                     // No beacon from the peer within the expiry time
+)
+
+var eventnames = map[EventType]string{PeerJoined: "joined", PeerChanged: "changed", PeerLost: "lost"}
+
+func (e EventType) String() string {
+	return eventnames[e]
+}
+
+// Event - Something happened to a peer, Peer is a copy as it was at the time
+type Event struct {
+	Type EventType
+	Peer Peer
+}
+
+// Registry - The peers learned from beacons
+// All access is through its methods which hand out copies, so it is safe to use from
+// the v4 and v6 listeners, the cli and the aging at once
+type Registry struct {
+	mu          sync.Mutex
+	peers       []*Peer
+	subscribers map[chan Event]bool
+}
+
+// NewRegistry - An empty peer registry
+func NewRegistry() *Registry {
+	return &Registry{subscribers: make(map[chan Event]bool)}
+}
+
+// Peers - The registry of peers heard by this node
+var Peers = NewRegistry()
+
+// Snapshot - A copy of the current peers
+func (r *Registry) Snapshot() []Peer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	peers := make([]Peer, len(r.peers))
+	for p := range r.peers {
+		peers[p] = r.peers[p].copy()
+	}
+	return peers
+}
+
+// Len - How many peers we know of
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.peers)
+}
+
+// Subscribe - Receive peer events on the returned channel until cancel is called
+// Events are dropped rather than hold up beacon handling if the channel is full
+func (r *Registry) Subscribe(buffer int) (<-chan Event, func()) {
+	c := make(chan Event, buffer)
+	r.mu.Lock()
+	r.subscribers[c] = true
+	r.mu.Unlock()
+	cancel := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.subscribers[c] {
+			delete(r.subscribers, c)
+			close(c)
+		}
+	}
+	return c, cancel
+}
+
+// Send the event to all subscribers, mu must be held
+func (r *Registry) publish(t EventType, p *Peer) {
+	for c := range r.subscribers {
+		select {
+		case c <- Event{Type: t, Peer: p.copy()}:
+		default:
+		}
+	}
+}
+
+// The peer with eid, or without an eid at addr. mu must be held
+func (r *Registry) find(eid string, addr string) *Peer {
+	for _, p := range r.peers {
+		if eid != "" && p.Eid == eid {
+			return p
+		}
+		if eid == "" && p.Eid == "" && p.Addr == addr {
+			return p
+		}
+	}
+	return nil
+}
+
+// The peer last heard from at addr. mu must be held
+// Several nodes may share an address, prefer an active one then the most recently heard
+func (r *Registry) findaddr(addr string) *Peer {
+	var found *Peer
+	for _, p := range r.peers {
+		if !p.HasAddr(addr) {
+			continue
+		}
+		if found == nil ||
+			(p.State == Active && found.State != Active) ||
+			(p.State == found.State && p.LastSeen.After(found.LastSeen)) {
+			found = p
+		}
+	}
+	return found
+}
+
+// Update - Add/Change peer info from received beacon
+// Peers are keyed by their EID so one node heard on several addresses is one peer
+// Anyone can beacon any EID so only beacons from the address we reach the peer at change it,
+// others just add to the addresses it has been heard from until Confirm moves it there
+// A beacon from any of its addresses shows the peer is still there
+// Returns true if the peer is new, rejoined or its information has changed
+func (r *Registry) Update(b *Beacon, from *net.UDPAddr) bool {
+	if from == nil {
+		return false
+	}
+	addr := from.IP.String()
+	maxdesc := sarflags.GetStr(b.Header, "descriptor")
+	canrx := sarflags.GetStr(b.Header, "rxwilling")
+	cantx := sarflags.GetStr(b.Header, "txwilling")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Change the peer if it exists
+	if p := r.find(b.Eid, addr); p != nil {
+		if !p.HasAddr(addr) {
+			p.Addrs = append(p.Addrs, addr)
+			r.publish(PeerChanged, p)
+			return true
+		}
+		p.LastSeen = time.Now()
+		rejoined := p.State == Lost
+		p.State = Active
+		if p.Addr != addr { // Alive but what it says from here does not change it
+			if rejoined {
+				r.publish(PeerJoined, p)
+			}
+			return rejoined
+		}
+		// Has anything changed since the last beacon for this peer ?
+		if p.Freespace != b.Freespace ||
+			p.Maxdesc != maxdesc || p.Canrx != canrx || p.Cantx != cantx {
+			p.Freespace = b.Freespace
+			p.Maxdesc = maxdesc
+			p.Canrx = canrx
+			p.Cantx = cantx
+			p.Updated.Now("posix32_32") // Last updated now
+			if rejoined {
+				r.publish(PeerJoined, p)
+			} else {
+				r.publish(PeerChanged, p)
+			}
+			return true
+		}
+		if rejoined {
+			r.publish(PeerJoined, p)
+		}
+		return rejoined
+	}
+	// We have a new Peer - add it
+	newp := &Peer{Addr: addr, Addrs: []string{addr}, Freespace: b.Freespace, Eid: b.Eid,
+		Maxdesc: maxdesc, Canrx: canrx, Cantx: cantx, LastSeen: time.Now(), State: Active}
+	newp.Created.Now("posix32_32")
+	newp.Updated = newp.Created
+	r.peers = append(r.peers, newp)
+	r.publish(PeerJoined, newp)
+	return true
+}
+
+// Confirm - The peer with eid has authenticated a request from addr so reach it there from now on
+// Returns false if we have not heard a beacon from eid
+func (r *Registry) Confirm(eid string, addr net.IP) bool {
+	if eid == "" || addr == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.find(eid, "")
+	if p == nil {
+		return false
+	}
+	if a := addr.String(); p.Addr != a {
+		p.Addr = a
+		if !p.HasAddr(a) {
+			p.Addrs = append(p.Addrs, a)
+		}
+		r.publish(PeerChanged, p)
+	}
+	return true
+}
+
+// Expire - Mark peers we have not heard a beacon from within expiry as lost
+// They stay in the registry so we can see when we last heard from them
+func (r *Registry) Expire(now time.Time, expiry time.Duration) {
+	if expiry <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.peers {
+		if p.State == Active && p.Age(now) > expiry {
+			p.State = Lost
+			r.publish(PeerLost, p)
+		}
+	}
+}
+
+// Aging - Check for lost peers every tick until ctx is done
+// expiry is called on every tick so changes to the beacon interval take effect
+func (r *Registry) Aging(ctx context.Context, tick time.Duration, expiry func() time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.Expire(now, expiry())
+		}
+	}
+}
+
+// ByAddr - A copy of what we have learned from beacons sent by the peer at addr
+func (r *Registry) ByAddr(addr net.IP) (Peer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p := r.findaddr(addr.String()); p != nil {
+		return p.copy(), true
+	}
+	return Peer{}, false
+}
+
+// ByEid - A copy of the peer with eid
+func (r *Registry) ByEid(eid string) (Peer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.peers {
+		if p.Eid == eid {
+			return p.copy(), true
+		}
+	}
+	return Peer{}, false
+}
+
+// NewPeer - Add/Change peer info from received beacon in Peers
+func NewPeer(b *Beacon, from *net.UDPAddr) bool {
+	return Peers.Update(b, from)
+}
+
+// PeerEid - The EID learned from beacons sent by the peer at addr, "" if we have not heard from it
+func PeerEid(addr net.IP) string {
+	p, _ := Peers.ByAddr(addr)
+	return p.Eid
+}
+
+// PeerInfo - A copy of what we have learned from beacons sent by the peer at addr
+func PeerInfo(addr net.IP) (Peer, bool) {
+	return Peers.ByAddr(addr)
+}
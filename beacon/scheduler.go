@@ -0,0 +1,150 @@
+// Beacon Scheduler - Send beacons in the background to each destination on its own schedule
+
+package beacon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schedule - Beacons being sent to a destination
+type Schedule struct {
+	Dest     string        // Name of the destination: v4, v6 or the unicast address
+	Addr     *net.UDPAddr  // Where the beacons go
+	Count    uint          // How many beacons to send, 0 is forever
+	Interval time.Duration // Time between beacons, 0 is send just one
+	Sent     uint          // How many sent so far
+	Started  time.Time     // When the schedule was started
+	cancel   context.CancelFunc
+	done     chan struct{} // Closed when the schedule has stopped sending
+}
+
+// Forever - Does the schedule keep sending until it is stopped
+func (s *Schedule) Forever() bool {
+	return s.Count == 0 && s.Interval > 0
+}
+
+// Scheduler - The schedules of beacons being sent
+type Scheduler struct {
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	// Flags is called before every beacon is sent so changes to the flags show in the next beacon
+	Flags func() string
+	// Sent is called after every beacon is sent (or fails to be) so it can be logged
+	Sent func(s Schedule, b *Beacon, err error)
+	// Eid is our node identity sent in every beacon, set it before Start
+	// If empty the local address and PID are sent which change every restart
+	Eid string
+}
+
+// NewScheduler - A scheduler getting its beacon flags from flags and reporting each send to sent
+func NewScheduler(flags func() string, sent func(s Schedule, b *Beacon, err error)) *Scheduler {
+	return &Scheduler{schedules: make(map[string]*Schedule), Flags: flags, Sent: sent}
+}
+
+// Start - Start sending count beacons every interval to addr, replacing any schedule already running for dest
+func (sc *Scheduler) Start(dest string, addr *net.UDPAddr, count uint, interval time.Duration) error {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Schedule{Dest: dest, Addr: addr, Count: count, Interval: interval,
+		Started: time.Now(), cancel: cancel, done: make(chan struct{})}
+	// Swap in one go so two Starts for dest can't both think they replaced it and leave one running
+	sc.mu.Lock()
+	old, ok := sc.schedules[dest]
+	sc.schedules[dest] = s
+	sc.mu.Unlock()
+	if ok { // Wait for it outside the lock, its run needs it to finish
+		old.cancel()
+		<-old.done
+	}
+	go sc.run(ctx, s, conn)
+	return nil
+}
+
+// Send the beacons for a schedule until it is done or cancelled
+func (sc *Scheduler) run(ctx context.Context, s *Schedule, conn *net.UDPConn) {
+	defer close(s.done)
+	defer conn.Close()
+	defer sc.remove(s)
+
+	eid := sc.Eid
+	if eid == "" {
+		eid = fmt.Sprintf("%s-%d", conn.LocalAddr().String(), os.Getpid())
+	}
+	for {
+		var b Beacon
+		binfo := Binfo{Freespace: 0, Eid: eid} // We work out the Freespace in b.New
+		err := b.New(sc.Flags(), &binfo)
+		if err == nil {
+			err = b.Send(conn)
+		}
+		sc.mu.Lock()
+		s.Sent++
+		cur := *s
+		sc.mu.Unlock()
+		if sc.Sent != nil {
+			sc.Sent(cur, &b, err)
+		}
+		if err != nil || s.Interval == 0 || (s.Count != 0 && cur.Sent >= s.Count) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.Interval):
+		}
+	}
+}
+
+// Take a finished schedule out of the list unless it has already been replaced
+func (sc *Scheduler) remove(s *Schedule) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.schedules[s.Dest] == s {
+		delete(sc.schedules, s.Dest)
+	}
+}
+
+// Stop - Stop sending beacons to dest, returns once nothing more will be sent
+func (sc *Scheduler) Stop(dest string) bool {
+	sc.mu.Lock()
+	s, ok := sc.schedules[dest]
+	if ok {
+		delete(sc.schedules, dest)
+	}
+	sc.mu.Unlock()
+	if !ok {
+		return false
+	}
+	s.cancel()
+	<-s.done
+	return true
+}
+
+// StopAll - Stop sending all beacons
+func (sc *Scheduler) StopAll() {
+	for _, s := range sc.List() {
+		sc.Stop(s.Dest)
+	}
+}
+
+// List - Copies of the schedules currently running sorted by destination
+func (sc *Scheduler) List() []Schedule {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	list := make([]Schedule, 0, len(sc.schedules))
+	for _, s := range sc.schedules {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Dest < list[j].Dest })
+	return list
+}
@@ -0,0 +1,86 @@
+// Capabilities - What a node is willing to do as set by its rxwilling, txwilling and stream flags
+
+package capability
+
+import (
+	"errors"
+)
+
+// Flags - The capability flags of a node, values as in the rxwilling, txwilling & stream flags
+type Flags struct {
+	Rxwilling string // Files can be received: no,invalid,capable,yes
+	Txwilling string // Files can be sent: no,invalid,capable,yes
+	Stream    string // Streams can be sent and received: no,yes
+}
+
+// Local - Our capabilities from the global flags
+func Local(global map[string]string) Flags {
+	return Flags{Rxwilling: global["rxwilling"], Txwilling: global["txwilling"], Stream: global["stream"]}
+}
+
+// Only yes means willing, capable says we could but have chosen not to
+func willing(f string) bool {
+	return f == "yes"
+}
+
+// Receives - Does reqtype mean the responder receives a file
+func Receives(reqtype string) bool {
+	switch reqtype {
+	case "put", "give", "putblind":
+		return true
+	}
+	return false
+}
+
+// Sends - Does reqtype mean the responder sends a file or directory
+func Sends(reqtype string) bool {
+	switch reqtype {
+	case "get", "take", "getdir":
+		return true
+	}
+	return false
+}
+
+// Responder - The errcode we reply to a reqtype request with, "success" if we are willing to do it
+func (f Flags) Responder(reqtype string, stream bool) string {
+	if stream && f.Stream != "yes" {
+		return "badrequest"
+	}
+	if Receives(reqtype) && !willing(f.Rxwilling) {
+		return "cantreceive"
+	}
+	if Sends(reqtype) && !willing(f.Txwilling) {
+		return "cantsend"
+	}
+	return "success"
+}
+
+// Errors when an initiator will not start a transfer
+var (
+	ErrLocalRx = errors.New("we are not willing to receive files")
+	ErrLocalTx = errors.New("we are not willing to send files")
+	ErrPeerRx  = errors.New("peer is not willing to receive files")
+	ErrPeerTx  = errors.New("peer is not willing to send files")
+)
+
+// Initiator - Can ttype be started given our flags and what the peer beaconed
+// An empty peer flag means we have not heard a beacon from it so we let it decide
+func Initiator(ttype string, local Flags, peer Flags) error {
+	if Receives(ttype) { // We send it the file
+		if !willing(local.Txwilling) {
+			return ErrLocalTx
+		}
+		if peer.Rxwilling != "" && !willing(peer.Rxwilling) {
+			return ErrPeerRx
+		}
+	}
+	if Sends(ttype) { // It sends us the file
+		if !willing(local.Rxwilling) {
+			return ErrLocalRx
+		}
+		if peer.Txwilling != "" && !willing(peer.Txwilling) {
+			return ErrPeerTx
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package capability
+
+import (
+	"testing"
+)
+
+func TestCapability(t *testing.T) {
+	yes := Flags{Rxwilling: "yes", Txwilling: "yes", Stream: "no"}
+	norx := Flags{Rxwilling: "no", Txwilling: "yes", Stream: "no"}
+	notx := Flags{Rxwilling: "yes", Txwilling: "capable", Stream: "yes"}
+
+	responder := []struct {
+		local   Flags
+		reqtype string
+		stream  bool
+		want    string
+	}{
+		{yes, "put", false, "success"},
+		{yes, "get", false, "success"},
+		{yes, "put", true, "badrequest"},
+		{norx, "put", false, "cantreceive"},
+		{norx, "give", false, "cantreceive"},
+		{norx, "get", false, "success"},
+		{norx, "delete", false, "success"},
+		{notx, "get", false, "cantsend"},
+		{notx, "take", false, "cantsend"},
+		{notx, "getdir", false, "cantsend"},
+		{notx, "put", true, "success"},
+	}
+	for _, tt := range responder {
+		if got := tt.local.Responder(tt.reqtype, tt.stream); got != tt.want {
+			t.Errorf("%+v Responder(%s, %v) = %s want %s", tt.local, tt.reqtype, tt.stream, got, tt.want)
+		}
+	}
+
+	unknown := Flags{} // No beacon heard from the peer
+	initiator := []struct {
+		ttype string
+		local Flags
+		peer  Flags
+		want  error
+	}{
+		{"put", yes, yes, nil},
+		{"put", yes, unknown, nil},
+		{"put", yes, norx, ErrPeerRx},
+		{"putblind", yes, norx, ErrPeerRx},
+		{"put", notx, yes, ErrLocalTx},
+		{"get", yes, notx, ErrPeerTx},
+		{"get", norx, yes, ErrLocalRx},
+		{"get", yes, unknown, nil},
+		{"delete", norx, notx, nil},
+	}
+	for _, tt := range initiator {
+		if got := Initiator(tt.ttype, tt.local, tt.peer); got != tt.want {
+			t.Errorf("Initiator(%s, %+v, %+v) = %v want %v", tt.ttype, tt.local, tt.peer, got, tt.want)
+		}
+	}
+
+	if l := Local(map[string]string{"rxwilling": "no", "txwilling": "yes", "stream": "no"}); l != norx {
+		t.Errorf("Local = %+v", l)
+	}
+}
@@ -0,0 +1,354 @@
+// Control - Drive a running saratoga over a unix domain socket
+// One JSON request per line in, one JSON response or event per line out
+
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/holes"
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+// Request - A command and its arguments as typed into the cmd view
+// e.g. {"id":1,"cmd":"put","args":["192.168.1.1","afile"],"timeout":30}
+type Request struct {
+	Id      int      `json:"id"`                // Returned in the response so requests can overlap
+	Cmd     string   `json:"cmd"`               // Any cli command or subscribe
+	Args    []string `json:"args,omitempty"`    // The rest of the command line
+	Timeout int      `json:"timeout,omitempty"` // Secs to wait for the peer to answer, 0 waits until the connection closes
+}
+
+// Response - How a Request went
+type Response struct {
+	Id      int         `json:"id"`
+	Errcode string      `json:"errcode"`          // success or why not
+	Error   string      `json:"error,omitempty"`  // More on why not
+	Output  []string    `json:"output,omitempty"` // What the command wrote to the msg view
+	Errors  []string    `json:"errors,omitempty"` // What the command wrote to the err view
+	Result  interface{} `json:"result,omitempty"` // Peers and transfers as objects rather than tables
+}
+
+// Event - A transfer or peer event sent to subscribers
+type Event struct {
+	Event  string            `json:"event"` // The kind of event e.g. transfer.added
+	Time   time.Time         `json:"time"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Transfer - What we report of a transfer
+type Transfer struct {
+	Direction string      `json:"direction"`
+	Ttype     string      `json:"ttype"`
+	Peer      string      `json:"peer"`
+	Session   uint32      `json:"session"`
+	File      string      `json:"file"`
+	State     string      `json:"state"`
+	Errcode   string      `json:"errcode,omitempty"` // Why it was cancelled
+	Size      uint64      `json:"size"`
+	Progress  uint64      `json:"progress"`
+	Inrespto  uint64      `json:"inrespto"`
+	Holes     holes.Holes `json:"holes,omitempty"`
+	Started   time.Time   `json:"started"`
+	Rate      float64     `json:"rate"` // Bytes a second
+}
+
+// Peer - What we report of a peer
+type Peer struct {
+	Addr      string   `json:"addr"`
+	Addrs     []string `json:"addrs"`
+	Eid       string   `json:"eid"`
+	Freespace uint64   `json:"freespace"`
+	Maxdesc   string   `json:"maxdesc"`
+	Canrx     string   `json:"canrx"`
+	Cantx     string   `json:"cantx"`
+	Age       string   `json:"age"`
+	State     string   `json:"state"`
+}
+
+// Errcodes other than the ones a peer sends back in a status
+const (
+	Success    = "success"
+	BadJSON    = "badjson"    // The request line is not a Request
+	BadCommand = "badcommand" // No such command
+	BadUsage   = "badusage"   // Wrong arguments for the command
+	Failed     = "failed"     // The command wrote to the err view
+	Timeout    = "timeout"    // The peer did not answer in time
+	Error      = "error"      // Anything else, see the error
+)
+
+// Server - Listens on the unix domain socket for requests
+type Server struct {
+	path  string
+	node  *node.Node
+	log   sarlog.Logger
+	ln    net.Listener
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// New - A Server for n on the socket at path
+func New(path string, n *node.Node, l sarlog.Logger) *Server {
+	if l == nil {
+		l = sarlog.Discard
+	}
+	return &Server{path: path, node: n, log: l, conns: make(map[net.Conn]bool)}
+}
+
+// Start - Listen on the socket, only we can connect to it
+// A socket left behind by an earlier run is removed, one something is listening on is not
+func (s *Server) Start() error {
+	if c, err := net.Dial("unix", s.path); err == nil {
+		c.Close()
+		return fmt.Errorf("control: %s is in use", s.path)
+	}
+	os.Remove(s.path)
+	ln, err := net.Listen("unix", s.path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(s.path, 0600); err != nil {
+		ln.Close()
+		return err
+	}
+	s.ln = ln
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[c] = true
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(c)
+			}()
+		}
+	}()
+	s.log.Msg("Control socket listening", sarlog.F("path", s.path))
+	return nil
+}
+
+// Close - Stop listening, drop the connections and remove the socket
+func (s *Server) Close() error {
+	if s.ln == nil {
+		return nil
+	}
+	err := s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+// A connection - requests are handled as they arrive, answers are written whole lines at a time
+type conn struct {
+	s   *Server
+	c   net.Conn
+	wmu sync.Mutex
+	enc *json.Encoder
+}
+
+func (cn *conn) write(v interface{}) {
+	cn.wmu.Lock()
+	defer cn.wmu.Unlock()
+	cn.enc.Encode(v)
+}
+
+// Read requests from c until it is closed
+func (s *Server) serve(c net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel() // Requests waiting on peers give up
+		wg.Wait()
+		c.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+	cn := &conn{s: s, c: c, enc: json.NewEncoder(c)}
+	scanner := bufio.NewScanner(c)
+	for scanner.Scan() {
+		var r Request
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			cn.write(Response{Errcode: BadJSON, Error: err.Error()})
+			continue
+		}
+		if r.Cmd == "subscribe" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cn.subscribe(ctx, r)
+			}()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cn.write(s.Do(ctx, r))
+		}()
+	}
+}
+
+// Send events to the connection until it is closed
+func (cn *conn) subscribe(ctx context.Context, r Request) {
+	if cn.s.node == nil {
+		cn.write(Response{Id: r.Id, Errcode: Error, Error: "no node to subscribe to"})
+		return
+	}
+	events, cancel := cn.s.node.Events(64)
+	defer cancel()
+	cn.write(Response{Id: r.Id, Errcode: Success})
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-events:
+			cn.write(event(e))
+		}
+	}
+}
+
+// Do - Run the request and say how it went
+// Transfers started through the node wait for the peers answer, everything else runs the cli command
+func (s *Server) Do(ctx context.Context, r Request) Response {
+	switch r.Cmd {
+	case "put", "get", "delete", "getdir":
+		if s.node != nil && len(r.Args) == 2 {
+			return s.transfer(ctx, r)
+		}
+	case "peers":
+		if len(r.Args) == 0 {
+			return Response{Id: r.Id, Errcode: Success, Result: Peers(s.Engine())}
+		}
+	case "tran", "files":
+		if len(r.Args) == 0 {
+			return Response{Id: r.Id, Errcode: Success, Result: Transfers(s.Engine())}
+		}
+	}
+	return s.command(r)
+}
+
+// Run the cli command keeping what it writes to the views
+func (s *Server) command(r Request) Response {
+	g, c := sarwin.NewCapture()
+	defer c.Close()
+	c.SetEngine(s.Engine())
+	line := r.Cmd
+	for _, a := range r.Args {
+		line += " " + a
+	}
+	if err := sarwin.Exec(g, line); err != nil {
+		return Response{Id: r.Id, Errcode: BadCommand, Error: err.Error()}
+	}
+	resp := Response{Id: r.Id, Errcode: Success, Output: c.Lines("msg"), Errors: c.Lines("err")}
+	if len(resp.Errors) > 0 {
+		resp.Errcode = Failed
+	}
+	return resp
+}
+
+// Start a transfer through the node and wait for the peer to answer
+func (s *Server) transfer(ctx context.Context, r Request) Response {
+	peer, err := sarwin.PeerAddress(r.Args[0])
+	if err != nil {
+		return Response{Id: r.Id, Errcode: BadUsage, Error: err.Error()}
+	}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(r.Timeout)*time.Second)
+		defer cancel()
+	}
+	var t *sarwin.Transfer
+	switch r.Cmd {
+	case "put":
+		t, err = s.node.Put(ctx, peer, r.Args[1])
+	case "get":
+		t, err = s.node.Get(ctx, peer, r.Args[1])
+	case "delete":
+		t, err = s.node.Delete(ctx, peer, r.Args[1])
+	case "getdir":
+		t, err = s.node.ListDir(ctx, peer, r.Args[1])
+	}
+	resp := Response{Id: r.Id, Errcode: Success}
+	if t != nil {
+		resp.Result = TransferOf(t)
+	}
+	var se *node.StatusError
+	switch {
+	case err == nil:
+	case errors.As(err, &se):
+		resp.Errcode, resp.Error = se.Errcode, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		resp.Errcode, resp.Error = Timeout, err.Error()
+	default:
+		resp.Errcode, resp.Error = Error, err.Error()
+	}
+	return resp
+}
+
+// TransferOf - What we report of t
+func TransferOf(t *sarwin.Transfer) Transfer {
+	st := t.Stats()
+	return Transfer{Direction: sarwin.Directions[t.Direction], Ttype: t.Ttype, Peer: t.Peer.String(),
+		Session: t.Session, File: t.Filename, State: st.State, Errcode: st.Errcode, Size: st.Size,
+		Progress: st.Progress, Inrespto: st.Inrespto, Holes: st.Holes, Started: st.Started, Rate: st.Rate}
+}
+
+// Engine - The tables of the node we control, the process wide ones without a node
+func (s *Server) Engine() *sarwin.Engine {
+	if s.node == nil {
+		return sarwin.Global()
+	}
+	return s.node.Engine()
+}
+
+// Transfers - What we report of the transfers listed in e
+func Transfers(e *sarwin.Engine) []Transfer {
+	ts := []Transfer{}
+	for _, t := range e.Transfers.Snapshot() {
+		ts = append(ts, TransferOf(t))
+	}
+	return ts
+}
+
+// Peers - What we report of the peers e has heard beacons from
+func Peers(e *sarwin.Engine) []Peer {
+	now := time.Now()
+	ps := []Peer{}
+	for _, p := range e.Peers.Snapshot() {
+		ps = append(ps, Peer{Addr: p.Addr, Addrs: p.Addrs, Eid: p.Eid, Freespace: p.Freespace,
+			Maxdesc: p.Maxdesc, Canrx: p.Canrx, Cantx: p.Cantx,
+			Age: p.Age(now).Round(time.Second).String(), State: p.State})
+	}
+	return ps
+}
+
+func event(e sarlog.Event) Event {
+	fields := make(map[string]string, len(e.Fields))
+	for _, f := range e.Fields {
+		fields[f.Key] = fmt.Sprint(f.Value)
+	}
+	return Event{Event: e.Kind, Time: e.Time, Fields: fields}
+}
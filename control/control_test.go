@@ -0,0 +1,127 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+func TestControl(t *testing.T) {
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+	sarflags.Cliflag = conf
+
+	start := func() *node.Node {
+		n, err := node.New(node.Config{Flags: conf, Addr: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n
+	}
+	a, b := start(), start()
+
+	path := filepath.Join(t.TempDir(), "saratoga.sock")
+	s := New(path, a, nil)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := New(path, a, nil).Start(); err == nil {
+		t.Error("started twice on the same socket")
+	}
+
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	lines := bufio.NewScanner(c)
+	send := func(line string) {
+		if _, err := c.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(v interface{}) {
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if !lines.Scan() {
+			t.Fatal("no reply ", lines.Err())
+		}
+		if err := json.Unmarshal(lines.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	do := func(line string) Response {
+		send(line)
+		var r Response
+		read(&r)
+		return r
+	}
+
+	if r := do(`{"id":1,"cmd":`); r.Errcode != BadJSON {
+		t.Errorf("bad json %+v", r)
+	}
+	if r := do(`{"id":2,"cmd":"nosuch"}`); r.Id != 2 || r.Errcode != BadCommand {
+		t.Errorf("bad command %+v", r)
+	}
+	if r := do(`{"id":3,"cmd":"freespace","args":["yes"]}`); r.Errcode != Success || len(r.Output) != 1 {
+		t.Errorf("freespace %+v", r)
+	}
+	if r := do(`{"id":4,"cmd":"freespace","args":["maybe"]}`); r.Errcode != Failed || len(r.Errors) == 0 {
+		t.Errorf("bad freespace %+v", r)
+	}
+	if r := do(`{"id":5,"cmd":"peers"}`); r.Errcode != Success || r.Result == nil {
+		t.Errorf("peers %+v", r)
+	}
+
+	// Delete through the node, events are seen by subscribers
+	if r := do(`{"id":6,"cmd":"subscribe"}`); r.Id != 6 || r.Errcode != Success {
+		t.Fatalf("subscribe %+v", r)
+	}
+	fname := "victim.txt"
+	if err := os.WriteFile(filepath.Join(conf.Sardir, fname), []byte("bye"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	peer := b.Addrs()[0].String()
+	send(`{"id":7,"cmd":"delete","args":["` + peer + `","` + fname + `"],"timeout":5}`)
+	var deleted bool
+	var added bool
+	for !deleted {
+		var m struct {
+			Response
+			Event
+		}
+		read(&m)
+		switch {
+		case m.Event.Event != "":
+			added = added || m.Event.Event == "transfer.added"
+		case m.Id == 7:
+			if m.Errcode != Success {
+				t.Fatalf("delete %+v", m.Response)
+			}
+			deleted = true
+		}
+	}
+	if !added {
+		t.Error("no transfer.added event")
+	}
+	r := do(`{"id":8,"cmd":"delete","args":["` + peer + `","` + fname + `"],"timeout":5}`)
+	for r.Id != 8 { // Skip events
+		r = Response{}
+		read(&r)
+	}
+	if r.Errcode != "filenotfound" {
+		t.Errorf("second delete %+v", r)
+	}
+}
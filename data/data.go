@@ -7,9 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"reflect"
 	"strings"
 
+	"github.com/charlesetsmith/saratoga/frames"
+	"github.com/charlesetsmith/saratoga/metrics"
 	"github.com/charlesetsmith/saratoga/sarflags"
 	"github.com/charlesetsmith/saratoga/timestamp"
 )
@@ -30,6 +31,8 @@ type Dinfo struct {
 	Payload []byte // Sllice of bytes - make sure you "append" to copy them here
 }
 
+var _ frames.Frame[Dinfo] = (*Data)(nil)
+
 type Packet struct {
 	Addr net.UDPAddr
 	Info Data
@@ -45,8 +48,7 @@ func (d *Data) Val(addr *net.UDPAddr) Packet {
 // New - Construct a data frame - return byte slice of frame and Data structure
 // Flags is of format "flagname1=flagval1,flagname2=flagval2...
 // The timestamp type to use is also in the flags as "timestamp=flagval"
-// func (d *Data) New(flags string, session uint32, offset uint64, payload []byte) error {
-func (d *Data) New(flags string, info interface{}) error {
+func (d *Data) New(flags string, info *Dinfo) error {
 
 	var err error
 
@@ -64,7 +66,7 @@ func (d *Data) New(flags string, info interface{}) error {
 	for fl := range flag {
 		f := strings.Split(flag[fl], "=") // f[0]=name f[1]=val
 		switch f[0] {
-		case "version", "descriptor", "reqstatus", "eod":
+		case "version", "descriptor", "reqstatus", "eod", "encrypt":
 			if d.Header, err = sarflags.Set(d.Header, f[0], f[1]); err != nil {
 				return err
 			}
@@ -98,56 +100,54 @@ func (d *Data) New(flags string, info interface{}) error {
 		}
 	}
 
-	e := reflect.ValueOf(info).Elem()
-	// Assign the values from the interface Dinfo structure
-	d.Session = uint32(e.FieldByName("Session").Uint())
-	d.Offset = e.FieldByName("Offset").Uint()
-	d.Payload = make([]byte, e.FieldByName("Payload").Len())
-	copy(d.Payload, e.FieldByName("Payload").Bytes())
+	d.info(info)
 	return nil
 }
 
 // Make - Construct a data frame with a given header - return byte slice of frame and Data structure
-// func (d *Data) Make(header uint32, session uint32, offset uint64, payload []byte) error {
-func (d *Data) Make(header uint32, info interface{}) error {
-
-	var err error
+func (d *Data) Make(header uint32, info *Dinfo) error {
 
-	d.Header = header
-	if d.Header, err = sarflags.Set(d.Header, "version", "v1"); err != nil {
-		return err
-	}
-	if d.Header, err = sarflags.Set(d.Header, "frametype", "data"); err != nil {
-		return err
-	}
+	h := sarflags.DataHeader(header)
+	h.SetVersion(sarflags.VersionV1)
+	h.SetFrametype(sarflags.FrametypeData)
+	d.Header = uint32(h)
 
-	tstamp := sarflags.GetStr(d.Header, "reqtstamp")
-	switch tstamp {
-	case "no":
-		// Don't do anything it's already set to no
-	case "localinterp", "posix32", "posix64", "posix32_32", "posix64_32", "epoch2000_32":
-		if err = d.Tstamp.Now(tstamp); err != nil { // Set the timestamp to right now
+	if h.Reqtstamp() {
+		if err := d.Tstamp.Now("posix32"); err != nil { // Set the timestamp to right now
 			return err
 		}
-	case "yes":
-		if err = d.Tstamp.Now("posix32"); err != nil { // Set the timestamp to right now
-			return err
-		}
-	default:
-		return errors.New(sarflags.GetStr(d.Header, "reqtstamp") + "invalid timestamp type")
 	}
 
-	e := reflect.ValueOf(info).Elem()
-	// Assign the values from the interface Dinfo structure
-	d.Session = uint32(e.FieldByName("Session").Uint())
-	d.Offset = e.FieldByName("Offset").Uint()
-	dlen := e.FieldByName("Payload").Len()
-	d.Payload = make([]byte, dlen)
-	copy(d.Payload, e.FieldByName("Payload").Bytes())
-
+	d.info(info)
 	return nil
 }
 
+// Assign the values from the Dinfo structure, the payload is copied
+func (d *Data) info(info *Dinfo) {
+	d.Session = info.Session
+	d.Offset = info.Offset
+	d.Payload = make([]byte, len(info.Payload))
+	copy(d.Payload, info.Payload)
+}
+
+// New - A data frame from flags and info, see Data.New
+func New(flags string, info Dinfo) (*Data, error) {
+	d := new(Data)
+	if err := d.New(flags, &info); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Make - A data frame with a given header and info, see Data.Make
+func Make(header uint32, info Dinfo) (*Data, error) {
+	d := new(Data)
+	if err := d.Make(header, &info); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
 // Get -- Decode Data byte slice frame into Data struct
 func (d *Data) Decode(frame []byte) error {
 
@@ -156,56 +156,57 @@ func (d *Data) Decode(frame []byte) error {
 	}
 	d.Header = binary.BigEndian.Uint32(frame[:4])
 	d.Session = binary.BigEndian.Uint32(frame[4:8])
-	if sarflags.GetStr(d.Header, "reqtstamp") == "yes" {
+	h := sarflags.DataHeader(d.Header)
+	if h.Reqtstamp() {
 		if err := d.Tstamp.Get(frame[8:24]); err != nil {
 			return err
 		}
-		switch sarflags.GetStr(d.Header, "descriptor") {
-		case "d16":
+		switch h.Descriptor() {
+		case sarflags.DescriptorD16:
 			d.Offset = uint64(binary.BigEndian.Uint16(frame[24:26])) // 2 bytes
 			d.Payload = make([]byte, len(frame[26:]))
 			copy(d.Payload, frame[26:])
 			if len(d.Payload) == 0 {
 				return errors.New("length of data payload is 0")
 			}
-		case "d32":
+		case sarflags.DescriptorD32:
 			d.Offset = uint64(binary.BigEndian.Uint32(frame[24:28])) // 4 bytes
 			d.Payload = make([]byte, len(frame[28:]))
 			copy(d.Payload, frame[28:])
-		case "d64":
+		case sarflags.DescriptorD64:
 			d.Offset = binary.BigEndian.Uint64(frame[24:32]) // 8 bytes
 			d.Payload = make([]byte, len(frame[32:]))
 			copy(d.Payload, frame[32:])
-		case "d128": // KLUDGE!!!!
-			return errors.New(sarflags.GetStr(d.Header, "descriptor") + "d128 not supported in data")
+		case sarflags.DescriptorD128: // KLUDGE!!!!
+			return errors.New("d128 not supported in data")
 			// d.Offset = binary.BigEndian.Uint64(frame[24+8 : 40]) // 16 bytes
 			// d.Payload = make([]byte, len(frame[64:]))
 			// copy(d.Payload, frame[64:])
 		default:
-			return errors.New(sarflags.GetStr(d.Header, "descriptor") + " invalid descriptor in data")
+			return errors.New(h.Descriptor().String() + " invalid descriptor in data")
 		}
 		return nil
 	}
-	switch sarflags.GetStr(d.Header, "descriptor") {
-	case "d16":
+	switch h.Descriptor() {
+	case sarflags.DescriptorD16:
 		d.Offset = uint64(binary.BigEndian.Uint16(frame[8:10]))
 		d.Payload = make([]byte, len(frame[10:]))
 		copy(d.Payload, frame[10:])
-	case "d32":
+	case sarflags.DescriptorD32:
 		d.Offset = uint64(binary.BigEndian.Uint32(frame[8:12]))
 		d.Payload = make([]byte, len(frame[12:]))
 		copy(d.Payload, frame[12:])
-	case "d64":
+	case sarflags.DescriptorD64:
 		d.Offset = uint64(binary.BigEndian.Uint64(frame[8:16]))
 		d.Payload = make([]byte, len(frame[16:]))
 		copy(d.Payload, frame[16:])
-	case "d128": // KLUDGE!!!!
-		return errors.New(sarflags.GetStr(d.Header, "descriptor") + "d128 not supported in data")
+	case sarflags.DescriptorD128: // KLUDGE!!!!
+		return errors.New("d128 not supported in data")
 		// d.Offset = uint64(binary.BigEndian.Uint64(frame[8+8 : 32]))
 		// d.Payload = make([]byte, len(frame[32:]))
 		// copy(d.Payload, frame[32:])
 	default:
-		return errors.New(sarflags.GetStr(d.Header, "descriptor") + "invalid descriptor in data")
+		return errors.New(h.Descriptor().String() + " invalid descriptor in data")
 	}
 	return nil
 }
@@ -217,23 +218,24 @@ func (d *Data) Encode() ([]byte, error) {
 
 	framelen := 4 + 4 // Header + Session
 
-	if sarflags.GetStr(d.Header, "reqtstamp") == "yes" {
+	h := sarflags.DataHeader(d.Header)
+	if h.Reqtstamp() {
 		framelen += 16 // Timestamp
 		havetstamp = true
 	}
 
-	switch sarflags.GetStr(d.Header, "descriptor") { // Offset
-	case "d16":
+	switch h.Descriptor() { // Offset
+	case sarflags.DescriptorD16:
 		framelen += 2
-	case "d32":
+	case sarflags.DescriptorD32:
 		framelen += 4
-	case "d64":
+	case sarflags.DescriptorD64:
 		framelen += 8
-	case "d128":
-		return nil, errors.New(sarflags.GetStr(d.Header, "descriptor") + "d128 not supported in data")
+	case sarflags.DescriptorD128:
+		return nil, errors.New("d128 not supported in data")
 		// framelen += 16
 	default:
-		return nil, errors.New(sarflags.GetStr(d.Header, "descriptor") + "invalid descriptor in data")
+		return nil, errors.New(h.Descriptor().String() + " invalid descriptor in data")
 	}
 	framelen += len(d.Payload)
 
@@ -246,21 +248,21 @@ func (d *Data) Encode() ([]byte, error) {
 		copy(frame[pos:24], d.Tstamp.Put())
 		pos = 24
 	}
-	switch sarflags.GetStr(d.Header, "descriptor") {
-	case "d16":
+	switch h.Descriptor() {
+	case sarflags.DescriptorD16:
 		binary.BigEndian.PutUint16(frame[pos:pos+2], uint16(d.Offset))
 		pos += 2
-	case "d32":
+	case sarflags.DescriptorD32:
 		binary.BigEndian.PutUint32(frame[pos:pos+4], uint32(d.Offset))
 		pos += 4
-	case "d64":
+	case sarflags.DescriptorD64:
 		binary.BigEndian.PutUint64(frame[pos:pos+8], uint64(d.Offset))
 		pos += 8
-	case "d128": // KLUDGE!!!!!
-		return nil, errors.New(sarflags.GetStr(d.Header, "descriptor") + "d128 not supported in data")
+	case sarflags.DescriptorD128: // KLUDGE!!!!!
+		return nil, errors.New("d128 not supported in data")
 		// pos += 16
 	default:
-		return nil, errors.New(sarflags.GetStr(d.Header, "descriptor") + " invalid descriptor in data")
+		return nil, errors.New(h.Descriptor().String() + " invalid descriptor in data")
 	}
 	copy(frame[pos:], d.Payload)
 	return frame, nil
@@ -304,9 +306,16 @@ func (d Data) Send(conn *net.UDPConn, to *net.UDPAddr) error {
 	if buf, err = d.Encode(); err != nil {
 		return err
 	}
-	if wlen, err = conn.WriteTo(buf, to); err != nil {
+	// A connection dialed to the peer can only Write
+	if conn.RemoteAddr() != nil {
+		wlen, err = conn.Write(buf)
+	} else {
+		wlen, err = conn.WriteTo(buf, to)
+	}
+	if err != nil {
 		return err
 	}
+	metrics.Sent("data", conn, to, wlen)
 	if wlen != len(buf) {
 		return fmt.Errorf("Data sent (%d) to %s != frame size (%d)", wlen, to.String(), len(buf))
 	}
@@ -2,21 +2,12 @@ package data
 
 import (
 	"testing"
-
-	"github.com/charlesetsmith/saratoga/sarflags"
 )
 
 func TestData(t *testing.T) {
 
-	// The Command line interface commands, help & usage to be read from saratoga.json
+	// The Command line interface commands, help & usage are built in to sarflags
 	// Cmdptr := new(sarflags.Cliflags)
-	conf := new(sarflags.Cliflags)
-	// Read in JSON config file and parse it into the Config structure.
-	if err := conf.ReadConfig("../saratoga/saratoga.json"); err != nil {
-		emsg := "Cannot open or parse saratoga.json Readconf error: " + err.Error()
-		t.Fatal(emsg)
-		return
-	}
 	// fmt.Println("Global Settings: ", Cmdptr.Global)
 	var dat Dinfo
 	// Load up the Data Structure
@@ -50,3 +41,27 @@ func TestData(t *testing.T) {
 
 	// fmt.Println("Data Frame: ", dptr.Print())
 }
+
+// What sending and receiving a data frame costs, the header is as a transfer makes it once
+func BenchmarkData(b *testing.B) {
+	var d Data
+	if err := d.New("descriptor=d32,reqstatus=no,eod=no,reqtstamp=no", &Dinfo{Session: 1234}); err != nil {
+		b.Fatal(err)
+	}
+	header := d.Header
+	info := Dinfo{Session: 1234, Payload: make([]byte, 1024)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		info.Offset = uint64(i) * 1024
+		if err := d.Make(header, &info); err != nil {
+			b.Fatal(err)
+		}
+		buf, err := d.Encode()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = d.Decode(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/charlesetsmith/saratoga/fileio"
@@ -67,6 +68,57 @@ func (d *DirEnt) Statfile(name string) error {
 	return nil
 }
 
+// Descriptor - The smallest descriptor that holds size
+func Descriptor(size uint64) string {
+	switch {
+	case size < sarflags.MaxUint16:
+		return "d16"
+	case size < sarflags.MaxUint32:
+		return "d32"
+	}
+	return "d64"
+}
+
+// Info - Construct a directory entry for path from what a stat of the file returned
+// Unlike New the file is not looked up in sardir so path can be any name we want to give it
+func (d *DirEnt) Info(path string, fi os.FileInfo) error {
+
+	var err error
+
+	if len(path) > 1024 {
+		return errors.New("path name exceeds 1024 bytes")
+	}
+	if d.Header, err = sarflags.SetD(0, "sod", "sod"); err != nil {
+		return err
+	}
+	prop := "normalfile"
+	d.Size = uint64(fi.Size())
+	switch mode := fi.Mode(); {
+	case mode.IsDir():
+		prop = "normaldirectory"
+	case !mode.IsRegular(): // Named pipes are streams with no size
+		prop = "specialfile"
+		d.Size = 0
+	}
+	if d.Header, err = sarflags.SetD(d.Header, "property", prop); err != nil {
+		return err
+	}
+	if d.Header, err = sarflags.SetD(d.Header, "descriptor", Descriptor(d.Size)); err != nil {
+		return err
+	}
+
+	var ft sarsys.FileTime
+	ft.NewTime(fi)
+	if err = d.Mtime.New("epoch2000_32", ft.Mtime); err != nil {
+		return err
+	}
+	if err = d.Ctime.New("epoch2000_32", ft.Ctime); err != nil {
+		return err
+	}
+	d.Path = path
+	return nil
+}
+
 // New - Construct a directory entry return byte slice of dirent
 // Flags is of format "flagname1=flagval1,flagname2=flagval2...
 // property - normalfile, normaldirectory, specialfile, specialdirectory
@@ -172,10 +224,6 @@ func (d *DirEnt) Encode() ([]byte, error) {
 		}
 		binary.BigEndian.PutUint32(frame[pos:pos+dsize], uint32(d.Size))
 	case 8:
-		if d.Size > sarflags.MaxUint32 { // This should never happen!!!!
-			e := fmt.Sprintf("Descriptor d64 too small for file size %d", d.Size)
-			return nil, errors.New(e)
-		}
 		binary.BigEndian.PutUint64(frame[pos:pos+dsize], uint64(d.Size))
 	default:
 		e := fmt.Sprintf("Malformed Directory Entry Invalid descriptor size %d", d.Size)
@@ -193,6 +241,27 @@ func (d *DirEnt) Encode() ([]byte, error) {
 	return frame, nil
 }
 
+// Entries - Decode the directory entries one after the other in buf, as a getdir sends them
+func Entries(buf []byte) ([]DirEnt, error) {
+	var ents []DirEnt
+	for len(buf) > 0 {
+		var d DirEnt
+		if err := d.Decode(buf); err != nil {
+			return ents, err
+		}
+		e, err := d.Encode()
+		if err != nil {
+			return ents, err
+		}
+		if len(e) > len(buf) { // The last path was not null terminated
+			return ents, errors.New("directory entry truncated")
+		}
+		ents = append(ents, d)
+		buf = buf[len(e):]
+	}
+	return ents, nil
+}
+
 // Get -- Decode Directory Entry byte slice frame into DirEnt struct
 func (d *DirEnt) Decode(frame []byte) error {
 
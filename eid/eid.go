@@ -0,0 +1,62 @@
+// Endpoint Identifiers - A stable name for a saratoga node independent of its addresses
+
+package eid
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Schemes
+const (
+	Dtn = "dtn://" // dtn://node/service
+	Ipn = "ipn:"   // ipn:node.service
+)
+
+// ErrInvalid - The EID is not a dtn:// or ipn: endpoint identifier
+var ErrInvalid = errors.New("invalid eid must be dtn://node[/service] or ipn:node.service")
+
+// Valid - Is s a dtn://node[/service] or ipn:node.service EID
+func Valid(s string) error {
+	if strings.ContainsAny(s, " \t\r\n") {
+		return ErrInvalid
+	}
+	switch {
+	case strings.HasPrefix(s, Dtn):
+		node, _, _ := strings.Cut(strings.TrimPrefix(s, Dtn), "/")
+		if node == "" {
+			return ErrInvalid
+		}
+		return nil
+	case strings.HasPrefix(s, Ipn):
+		node, service, ok := strings.Cut(strings.TrimPrefix(s, Ipn), ".")
+		if !ok {
+			return ErrInvalid
+		}
+		if _, err := strconv.ParseUint(node, 10, 64); err != nil {
+			return ErrInvalid
+		}
+		if _, err := strconv.ParseUint(service, 10, 64); err != nil {
+			return ErrInvalid
+		}
+		return nil
+	}
+	return ErrInvalid
+}
+
+// Is - Does s look like an EID rather than an address or alias
+func Is(s string) bool {
+	return strings.HasPrefix(s, Dtn) || strings.HasPrefix(s, Ipn)
+}
+
+// Default - The EID used when none is configured, stable across restarts as it is from the hostname
+// The port is in it so nodes sharing a host on different ports are different nodes
+func Default(port int) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return Dtn + strings.ToLower(host) + "/saratoga/" + strconv.Itoa(port)
+}
@@ -0,0 +1,35 @@
+package eid
+
+import (
+	"testing"
+)
+
+func TestEid(t *testing.T) {
+	good := []string{"dtn://node", "dtn://node/saratoga", "dtn://a.b.c/x/y", "ipn:1.0", "ipn:977.42"}
+	bad := []string{"", "dtn://", "dtn:///svc", "dtn://no de", "ipn:1", "ipn:a.1", "ipn:1.b", "ipn:.1",
+		"192.0.2.1", "http://node", "dtn:node"}
+
+	for _, s := range good {
+		if err := Valid(s); err != nil {
+			t.Errorf("%q: %v", s, err)
+		}
+		if !Is(s) {
+			t.Errorf("%q is not an eid", s)
+		}
+	}
+	for _, s := range bad {
+		if Valid(s) == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+	if Is("192.0.2.1") || Is("myalias") {
+		t.Error("address or alias taken as an eid")
+	}
+	d := Default(7542)
+	if Valid(d) != nil || Default(7542) != d {
+		t.Errorf("default %q not valid or not stable", d)
+	}
+	if Default(7543) == d {
+		t.Errorf("nodes on ports 7542 and 7543 both %q", d)
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"github.com/charlesetsmith/saratoga/sarsys"
 )
 
-// Saratoga Directory to read/write local files
+// Saratoga Directory to read/write local files, the current directory without a config
 func Sardir() string {
+	if sarflags.Cliflag == nil || sarflags.Cliflag.Sardir == "" {
+		return "."
+	}
 	return sarflags.Cliflag.Sardir
 }
 
@@ -12,18 +12,18 @@ import (
 func TestFileio(t *testing.T) {
 
 	var err error
-	// The Command line interface commands, help & usage to be read from saratoga.json
+	// The Command line interface commands, help & usage are built in to sarflags
 	// Cmdptr := new(sarflags.Cliflags)
-	conf := new(sarflags.Cliflags)
-	// Read in JSON config file and parse it into the Config structure.
-	if err = conf.ReadConfig("../saratoga/saratoga.json"); err != nil {
-		emsg := "Cannot open or parse saratoga.json Readconf error: " + err.Error()
-		t.Fatal(emsg)
-		return
-	}
+	conf := sarflags.New()
 
+	// Without a config the files are in the current directory
+	cwd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
 	var fp *os.File
-	fname := conf.Sardir + "/testfile.temp"
+	fname := "testfile.temp"
 
 	// Remove the file
 	if err = FileRm(fname); err != nil {
@@ -2,61 +2,53 @@
 
 package frames
 
-import (
-	"net"
-)
-
-type FrameVal interface {
-	Val() Frame
-	Print() string
-}
-
-// Frame - Handler for different frames
+// Codec - What every frame can do with its bytes
 //
 //	beacon, data, metadata, request, status
-type Frame interface {
-	Encode() ([]byte, error)               // Encode from frame struct into []bytes
-	Decode([]byte) error                   // Decode from []bytes into frame struct (beacon, request, data, metadata, status)
-	Print() string                         // Print out contents of some type of frame
-	ShortPrint() string                    // Quick summary print out of some type of frame
-	New(string, interface{}) error         // Create New Frame with flags & info via interface
-	Make(uint32, interface{}) error        // Make New Frame with header & info via interface
-	Send(*net.UDPConn, *net.UDPAddr) error // Send a Frame to the remote peer
-	Val(*net.UDPAddr) Frame                // Frame field information (beacon,data,request,metadata,status)
+type Codec interface {
+	Encode() ([]byte, error) // Encode from frame struct into []bytes
+	Decode([]byte) error     // Decode from []bytes into frame struct (beacon, request, data, metadata, status)
+	Print() string           // Print out contents of some type of frame
+	ShortPrint() string      // Quick summary print out of some type of frame
 }
 
-func Val(f Frame, addr *net.UDPAddr) Frame {
-	return f.Val(addr)
+// Frame - Handler for different frames created from their info struct I
+//
+//	*beacon.Beacon is a Frame[beacon.Binfo], *data.Data a Frame[data.Dinfo],
+//	*metadata.MetaData a Frame[metadata.Minfo], *request.Request a Frame[request.Rinfo]
+//	and *status.Status a Frame[status.Sinfo]
+type Frame[I any] interface {
+	Codec
+	New(string, *I) error  // Create New Frame with flags & info
+	Make(uint32, *I) error // Make New Frame with header & info
 }
 
-// Decode a frame into its structure via Frame interface
-func Decode(f Frame, buf []byte) error {
+// Decode a frame into its structure via Codec interface
+func Decode(f Codec, buf []byte) error {
 	return f.Decode(buf)
 }
 
-// Encode a frame into its structure via Frame interface
-func Encode(f Frame) ([]byte, error) {
+// Encode a frame into its structure via Codec interface
+func Encode(f Codec) ([]byte, error) {
 	return f.Encode()
 }
 
-// Print a frame into its structure via Frame interface
-func Print(f Frame) string {
+// Print a frame into its structure via Codec interface
+func Print(f Codec) string {
 	return f.Print()
 }
 
-// ShortPrint a frame into its structure via Frame interface
-func ShortPrint(f Frame) string {
+// ShortPrint a frame into its structure via Codec interface
+func ShortPrint(f Codec) string {
 	return f.ShortPrint()
 }
 
-func New(f Frame, header string, info interface{}) error {
-	return f.New(header, info)
+// New - Create frame f from flags and info, info must be the info struct of f
+func New[I any](f Frame[I], flags string, info *I) error {
+	return f.New(flags, info)
 }
 
-func Make(f Frame, header uint32, info interface{}) error {
+// Make - Create frame f from header and info, info must be the info struct of f
+func Make[I any](f Frame[I], header uint32, info *I) error {
 	return f.Make(header, info)
 }
-
-func Send(f Frame, conn *net.UDPConn, a *net.UDPAddr) error {
-	return f.Send(conn, a)
-}
@@ -8,11 +8,12 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"reflect"
 	"strings"
 
 	"github.com/charlesetsmith/saratoga/dirent"
 	"github.com/charlesetsmith/saratoga/fileio"
+	"github.com/charlesetsmith/saratoga/frames"
+	"github.com/charlesetsmith/saratoga/metrics"
 	"github.com/charlesetsmith/saratoga/sarflags"
 )
 
@@ -27,8 +28,11 @@ type MetaData struct {
 type Minfo struct {
 	Session uint32
 	Fname   string
+	Dir     *dirent.DirEnt // Entry to send when we already have one, Fname is then not looked at
 }
 
+var _ frames.Frame[Minfo] = (*MetaData)(nil)
+
 type Packet struct {
 	Addr net.UDPAddr
 	Info MetaData
@@ -108,8 +112,7 @@ func statfile(fname string, header uint32) (string, error) {
 
 // New - Construct a Metadata structure
 // Flags is of format "flagname1=flagval1,flagname2=flagval2...
-// func (m *MetaData) New(flags string, session uint32, fname string) error {
-func (m *MetaData) New(flags string, info interface{}) error {
+func (m *MetaData) New(flags string, info *Minfo) error {
 
 	var err error
 
@@ -127,7 +130,7 @@ func (m *MetaData) New(flags string, info interface{}) error {
 	for fl := range flag {
 		f := strings.Split(flag[fl], "=") // f[0]=name f[1]=val
 		switch f[0] {
-		case "descriptor", "progress", "transfer", "reliability":
+		case "descriptor", "progress", "transfer", "reliability", "encrypt":
 			if m.Header, err = sarflags.Set(m.Header, f[0], f[1]); err != nil {
 				return err
 			}
@@ -145,13 +148,15 @@ func (m *MetaData) New(flags string, info interface{}) error {
 		}
 	}
 
-	var fname string
+	if info.Dir != nil {
+		return m.given(info)
+	}
+
 	var direntflags string // Particular Flags for directory entry
 
-	e := reflect.ValueOf(info).Elem()
-	// Get Session and filename from Minfo interface struct
-	m.Session = uint32(e.FieldByName("Session").Uint())
-	fname = e.FieldByName("Fname").String()
+	// Get Session and filename from Minfo struct
+	m.Session = info.Session
+	fname := info.Fname
 	if direntflags, err = statfile(fname, m.Header); err != nil {
 		return err
 	}
@@ -187,8 +192,7 @@ func (m *MetaData) New(flags string, info interface{}) error {
 }
 
 // Make - Construct a Metadata structure given a header
-// func (m *MetaData) Make(header uint32, session uint32, fname string) error {
-func (m *MetaData) Make(header uint32, info interface{}) error {
+func (m *MetaData) Make(header uint32, info *Minfo) error {
 
 	var err error
 
@@ -199,11 +203,12 @@ func (m *MetaData) Make(header uint32, info interface{}) error {
 		return err
 	}
 	m.Header = header
+	if info.Dir != nil {
+		return m.given(info)
+	}
 
-	var fname string
-	e := reflect.ValueOf(info).Elem()
-	m.Session = uint32(e.FieldByName("Session").Uint())
-	fname = e.FieldByName("Session").String()
+	m.Session = info.Session
+	fname := info.Fname
 	var direntflags string
 	if direntflags, err = statfile(fname, m.Header); err != nil {
 		return err
@@ -238,6 +243,40 @@ func (m *MetaData) Make(header uint32, info interface{}) error {
 	return nil
 }
 
+// Use the directory entry we were given, there is no file to checksum
+func (m *MetaData) given(info *Minfo) error {
+	var err error
+
+	if m.Header, err = sarflags.Set(m.Header, "csumtype", "none"); err != nil {
+		return err
+	}
+	if m.Header, err = sarflags.Set(m.Header, "csumlen", "none"); err != nil {
+		return err
+	}
+	m.Session = info.Session
+	m.Checksum = nil
+	m.Dir = info.Dir.Value()
+	return nil
+}
+
+// New - A metadata frame from flags and info, see MetaData.New
+func New(flags string, info Minfo) (*MetaData, error) {
+	m := new(MetaData)
+	if err := m.New(flags, &info); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Make - A metadata frame with a given header and info, see MetaData.Make
+func Make(header uint32, info Minfo) (*MetaData, error) {
+	m := new(MetaData)
+	if err := m.Make(header, &info); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Put -- Encode the Saratoga Metadata buffer
 func (m *MetaData) Encode() ([]byte, error) {
 
@@ -324,9 +363,16 @@ func (m MetaData) Send(conn *net.UDPConn, to *net.UDPAddr) error {
 	if buf, err = m.Encode(); err != nil {
 		return err
 	}
-	if wlen, err = conn.WriteTo(buf, to); err != nil {
+	// A connection dialed to the peer can only Write
+	if conn.RemoteAddr() != nil {
+		wlen, err = conn.Write(buf)
+	} else {
+		wlen, err = conn.WriteTo(buf, to)
+	}
+	if err != nil {
 		return err
 	}
+	metrics.Sent("metadata", conn, to, wlen)
 	if wlen != len(buf) {
 		return fmt.Errorf("MetaData sent (%d) to %s != frame size (%d)", wlen, to.String(), len(buf))
 	}
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/charlesetsmith/saratoga/sarflags"
@@ -10,29 +11,32 @@ import (
 func TestMetadata(t *testing.T) {
 	// var err error
 	// var Cmdptr *sarflags.Cliflags
-	// The Command line interface commands, help & usage to be read from saratoga.json
+	// The Command line interface commands, help & usage are built in to sarflags
 	// Cmdptr = new(sarflags.Cliflags)
 
-	// The Command line interface commands, help & usage to be read from saratoga.json
+	// The Command line interface commands, help & usage are built in to sarflags
 	// Cmdptr := new(sarflags.Cliflags)
-	conf := new(sarflags.Cliflags)
-
-	// Read in JSON config file and parse it into the Config structure.
-	if err := conf.ReadConfig("../saratoga/saratoga.json"); err != nil {
-		emsg := "Cannot open or parse saratoga.json Readconf error: " + err.Error()
-		t.Fatal(emsg)
-		return
-	}
+	conf := sarflags.New()
 	for i := range conf.Global {
 		fmt.Println(i, "=", conf.Global[i])
 	}
 	fmt.Println("Timeouts:", conf.Timeout)
 
+	// Without a config the file is in the current directory
+	cwd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.WriteFile("testfile", []byte("metadata test file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	// fmt.Println("Global Settings: ", Cmdptr.Global)
 	var met Minfo
 	// Load up the Status Structure
 	met.Session = 1234
-	met.Fname = "go.mod"
+	met.Fname = "testfile"
 
 	var m MetaData
 	mptr := &m
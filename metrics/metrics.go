@@ -0,0 +1,255 @@
+// Metrics - Counters and gauges in the Prometheus text format
+// The frame packages count what they send, the listeners what they receive
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/sarnet"
+)
+
+// Sample - A gauge value with its label values in the order the labels were given
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// metric - Anything we can write out
+type metric interface {
+	write(w io.Writer) error
+}
+
+var mu sync.Mutex
+var registry = make(map[string]metric)
+
+func register(name string, m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	registry[name] = m
+}
+
+// Counter - A count that only goes up, one per set of label values
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64 // Keyed by the label values joined with \xff
+}
+
+// NewCounter - Register a counter with the labels its values are counted by
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	if len(labels) == 0 { // Shown as 0 until counted
+		c.values[""] = 0
+	}
+	register(name, c)
+	return c
+}
+
+// Add - Add v to the count for the label values
+func (c *Counter) Add(v float64, labels ...string) {
+	if len(labels) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d labels got %d", c.name, len(c.labels), len(labels)))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labels, "\xff")] += v
+}
+
+// Inc - Add 1 to the count for the label values
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Value - The count for the label values
+func (c *Counter) Value(labels ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labels, "\xff")]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	samples := make([]Sample, 0, len(c.values))
+	for k, v := range c.values {
+		var labels []string
+		if len(c.labels) > 0 {
+			labels = strings.Split(k, "\xff")
+		}
+		samples = append(samples, Sample{Labels: labels, Value: v})
+	}
+	c.mu.Unlock()
+	return write(w, c.name, c.help, "counter", c.labels, samples)
+}
+
+// Gauge - A value worked out when the metrics are read
+type Gauge struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() []Sample
+}
+
+// NewGauge - Register a gauge, collect gives its values when the metrics are read
+func NewGauge(name string, help string, labels []string, collect func() []Sample) *Gauge {
+	g := &Gauge{name: name, help: help, labels: labels, collect: collect}
+	register(name, g)
+	return g
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	return write(w, g.name, g.help, "gauge", g.labels, g.collect())
+}
+
+// Write out a metric in the text format, samples sorted by their label values
+func write(w io.Writer, name string, help string, mtype string, labels []string, samples []Sample) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, mtype); err != nil {
+		return err
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].Labels, "\xff") < strings.Join(samples[j].Labels, "\xff")
+	})
+	for _, s := range samples {
+		var pairs []string
+		for i, l := range labels {
+			if i < len(s.Labels) {
+				pairs = append(pairs, l+"=\""+escape(s.Labels[i])+"\"")
+			}
+		}
+		lstr := ""
+		if len(pairs) > 0 {
+			lstr = "{" + strings.Join(pairs, ",") + "}"
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %v\n", name, lstr, s.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Write - All the metrics in the text format sorted by name
+func Write(w io.Writer) error {
+	mu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ms := make([]metric, 0, len(names))
+	for _, name := range names {
+		ms = append(ms, registry[name])
+	}
+	mu.Unlock()
+	for _, m := range ms {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler - Serves the metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// The metrics
+var (
+	FramesRx = NewCounter("saratoga_frames_received_total", "Frames received by frame type and IP family",
+		"frametype", "family")
+	FramesTx = NewCounter("saratoga_frames_sent_total", "Frames sent by frame type and IP family",
+		"frametype", "family")
+	BytesRx = NewCounter("saratoga_bytes_received_total", "Bytes of frames received by IP family", "family")
+	BytesTx = NewCounter("saratoga_bytes_sent_total", "Bytes of frames sent by IP family", "family")
+	Errors  = NewCounter("saratoga_frame_errors_total", "Frames received that we could not use by reason",
+		"reason")
+	Errcodes = NewCounter("saratoga_errcodes_total", "Status errcodes sent and received",
+		"direction", "errcode")
+	Retransmitted = NewCounter("saratoga_retransmitted_bytes_total", "Bytes of data sent again to fill holes")
+)
+
+// Family - ipv4 or ipv6
+func Family(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// Received - Count a frame of n bytes received from addr
+func Received(frametype string, from *net.UDPAddr, n int) {
+	family := Family(from.IP)
+	FramesRx.Inc(frametype, family)
+	BytesRx.Add(float64(n), family)
+}
+
+// Sent - Count a frame of n bytes sent on conn, to is used if conn is not dialed
+func Sent(frametype string, conn *net.UDPConn, to *net.UDPAddr, n int) {
+	if ra, ok := conn.RemoteAddr().(*net.UDPAddr); ok {
+		to = ra
+	}
+	family := "ipv6"
+	if to != nil {
+		family = Family(to.IP)
+	}
+	FramesTx.Inc(frametype, family)
+	BytesTx.Add(float64(n), family)
+}
+
+// Server - Serves the metrics on /metrics
+type Server struct {
+	srv *http.Server
+	ln  net.Listener
+}
+
+// New - A Server listening on addr, with no host given it listens on localhost only
+func New(addr string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &Server{srv: &http.Server{Addr: sarnet.LocalAddr(addr), Handler: mux, ReadHeaderTimeout: 10 * time.Second}}
+}
+
+// Start - Listen and serve in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	go s.srv.Serve(ln)
+	return nil
+}
+
+// Addr - Where we are listening, nil before Start
+func (s *Server) Addr() net.Addr {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close - Stop serving
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	c := NewCounter("test_total", "A test counter", "kind")
+	c.Inc("b")
+	c.Add(2, "a\"q")
+	c.Inc("b")
+	NewGauge("test_gauge", "A test gauge", nil, func() []Sample { return []Sample{{Value: 7}} })
+
+	var buf bytes.Buffer
+	if err := Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# HELP test_total A test counter\n# TYPE test_total counter\ntest_total{kind=\"a\\\"q\"} 2\ntest_total{kind=\"b\"} 2\n",
+		"# TYPE test_gauge gauge\ntest_gauge 7\n",
+		"saratoga_retransmitted_bytes_total 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+
+	Received("data", &net.UDPAddr{IP: net.ParseIP("::1")}, 100)
+	Received("data", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}, 10)
+	if FramesRx.Value("data", "ipv6") != 1 || BytesRx.Value("ipv4") != 10 {
+		t.Error("received not counted")
+	}
+
+	s := New("127.0.0.1:0")
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	resp, err := http.Get("http://" + s.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `saratoga_frames_received_total{frametype="data",family="ipv6"} 1`) {
+		t.Errorf("served\n%s", body)
+	}
+}
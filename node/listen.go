@@ -0,0 +1,171 @@
+// Listen - Receive frames, decode them and hand them to the engine
+
+package node
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/charlesetsmith/saratoga/beacon"
+	"github.com/charlesetsmith/saratoga/data"
+	"github.com/charlesetsmith/saratoga/metadata"
+	"github.com/charlesetsmith/saratoga/metrics"
+	"github.com/charlesetsmith/saratoga/request"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/sarnet"
+	"github.com/charlesetsmith/saratoga/status"
+	"github.com/charlesetsmith/saratoga/trans"
+)
+
+// Read frames from conn until it fails, decode them and pass them on to the handlers
+// Frames the handlers want sent back go to the tx channel, as do status frames for bad frames
+func (n *Node) listen(conn *net.UDPConn, tx chan interface{}) error {
+	l := logger{n}
+
+	// Investigate using conn.PathMTU()
+	maxframesize := sarflags.Mtu() - 60   // Handles IPv4 & IPv6 header
+	buf := make([]byte, maxframesize+100) // Just in case...
+
+	for { // Loop forever grabbing frames
+		framelen, remoteAddr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+
+		// Very basic frame checks before we get into what it is
+		if framelen < 8 {
+			metrics.Errors.Inc("tooshort")
+			l.Err("Rx Saratoga Frame too short", sarlog.F("from", sarnet.UDPinfo(remoteAddr)))
+			continue
+		}
+		if framelen > maxframesize {
+			metrics.Errors.Inc("toolong")
+			l.Err("Rx Saratoga Frame too long", sarlog.F("len", framelen), sarlog.F("from", sarnet.UDPinfo(remoteAddr)))
+			continue
+		}
+
+		// OK so we might have a valid frame so copy it to to the frame byte slice
+		framebuf := make([]byte, framelen)
+		copy(framebuf, buf[:framelen])
+
+		// Grab the Saratoga Header which is the first 32 bits
+		header := sarflags.Header(binary.BigEndian.Uint32(framebuf[:4]))
+		if header.Version() != sarflags.VersionV1 { // Make sure we are Version 1
+			// If a bad version received then send back a Status errcode to the initiator
+			metrics.Errors.Inc("badversion")
+			l.Err("Not Saratoga Version 1 Frame", sarlog.F("from", sarnet.UDPinfo(remoteAddr)))
+			n.badframe(tx, remoteAddr, "errcode=badpacket", 0, 0)
+			continue
+		}
+		// Bad frames other than beacons and requests get a badpacket status back
+		badpacket := "descriptor=" + header.Descriptor().String() +
+			",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=badpacket"
+		session := binary.BigEndian.Uint32(framebuf[4:8])
+		frametype := header.Frametype().String()
+		metrics.Received(frametype, remoteAddr, framelen)
+
+		switch frametype {
+		case "beacon":
+			var b beacon.Beacon
+			if err := b.Decode(framebuf); err != nil {
+				// Bad beacons are dropped, we dont send status frames for beacons
+				metrics.Errors.Inc("badbeacon")
+				l.Err("Bad Beacon", sarlog.F("from", sarnet.UDPinfo(remoteAddr)), sarlog.F("err", err))
+				continue
+			}
+			l.Packet("Rx " + b.ShortPrint())
+			// Add a new or update an existing peer information from the beacon
+			n.e.Peers.Update(&b, remoteAddr)
+
+		case "request":
+			var r request.Request
+			if err := r.Decode(framebuf); err != nil {
+				// Bad requests are dropped
+				metrics.Errors.Inc("badrequest")
+				l.Err("Bad Request", sarlog.F("from", sarnet.UDPinfo(remoteAddr)), sarlog.F("err", err))
+				continue
+			}
+			l.Packet("Rx " + r.ShortPrint())
+			// We have received a request to send or receive a file or dir
+			if trans.AddRxTran(l, n.e, conn, &r, remoteAddr, tx) {
+				l.Msg("New transfer request", sarlog.F("from", remoteAddr))
+			}
+
+		case "data":
+			var d data.Data
+			if err := d.Decode(framebuf); err != nil {
+				metrics.Errors.Inc("baddata")
+				l.Err("Bad Data", sarlog.F("from", sarnet.UDPinfo(remoteAddr)), sarlog.F("session", d.Session),
+					sarlog.F("err", err))
+				n.badframe(tx, remoteAddr, badpacket, d.Session, d.Offset)
+				continue
+			}
+			l.Packet("Rx " + d.ShortPrint())
+			// Write it where it belongs in the file we are receiving
+			trans.DataRx(l, n.e, &d, remoteAddr)
+
+		case "metadata":
+			var m metadata.MetaData
+			if err := m.Decode(framebuf); err != nil {
+				metrics.Errors.Inc("badmetadata")
+				l.Err("Bad MetaData", sarlog.F("from", sarnet.UDPinfo(remoteAddr)), sarlog.F("session", session),
+					sarlog.F("err", err))
+				n.badframe(tx, remoteAddr, badpacket, session, 0)
+				continue
+			}
+			l.Packet("Rx " + m.ShortPrint())
+			// Make sure it will fit, replies go back via tx
+			// Before any data that follows it so the data has somewhere to go
+			trans.MetadataRx(l, n.e, &m, remoteAddr, tx)
+
+		case "status":
+			var s status.Status
+			if err := s.Decode(framebuf); err != nil {
+				metrics.Errors.Inc("badstatus")
+				l.Err("Bad Status", sarlog.F("from", sarnet.UDPinfo(remoteAddr)), sarlog.F("session", session),
+					sarlog.F("err", err))
+				n.badframe(tx, remoteAddr, badpacket, session, 0)
+				continue
+			}
+			l.Packet("Rx " + s.ShortPrint())
+			metrics.Errcodes.Inc("received", sarflags.StatusHeader(s.Header).Errcode().String())
+			// The receiver telling us what it has or the answer to our request
+			if t := n.e.Transfers.Match(remoteAddr.String(), s.Session); t != nil {
+				t.StatusRx(l, s)
+			}
+
+		default:
+			// Bad Packet drop it
+			metrics.Errors.Inc("badframetype")
+			l.Err("Invalid Saratoga Frame", sarlog.F("from", sarnet.UDPinfo(remoteAddr)))
+		}
+	}
+}
+
+// Send a status with flags back to the sender of a frame we could not handle
+func (n *Node) badframe(tx chan interface{}, to *net.UDPAddr, flags string, session uint32, progress uint64) {
+	st, err := status.New(flags, status.Sinfo{Session: session, Progress: progress})
+	if err != nil {
+		logger{n}.Err("Cannot create badpacket status", sarlog.F("err", err))
+		return
+	}
+	tx <- st.Val(to)
+}
+
+// Send the frames the handlers give us out of conn until the listener closes tx
+func (n *Node) send(conn *net.UDPConn, tx chan interface{}) {
+	l := logger{n}
+	for frame := range tx {
+		switch pkt := frame.(type) {
+		case status.Packet:
+			if err := pkt.Info.Send(conn, &pkt.Addr); err != nil {
+				l.Err("Cannot send STATUS", sarlog.F("to", pkt.Addr.String()), sarlog.F("err", err))
+				continue
+			}
+			l.Packet("Tx " + pkt.Info.ShortPrint())
+		default:
+			l.Err("Cannot send frame, only status frames are sent from here")
+		}
+	}
+}
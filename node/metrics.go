@@ -0,0 +1,96 @@
+// Metrics - Gauges of the transfers and peers the running nodes hold
+
+package node
+
+import (
+	"sync"
+
+	"github.com/charlesetsmith/saratoga/beacon"
+	"github.com/charlesetsmith/saratoga/metrics"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+var gaugesonce sync.Once
+
+var enginesmu sync.Mutex
+var engines = make(map[*sarwin.Registry]*sarwin.Engine) // Of running nodes, by their transfers table
+
+// Count e in the gauges until it is unregistered
+// Nodes on the process wide tables share them so they are only counted once
+func register(e *sarwin.Engine) {
+	gauges()
+	enginesmu.Lock()
+	defer enginesmu.Unlock()
+	engines[e.Transfers] = e
+}
+
+// Stop counting e in the gauges
+func unregister(e *sarwin.Engine) {
+	enginesmu.Lock()
+	defer enginesmu.Unlock()
+	delete(engines, e.Transfers)
+}
+
+// The engines of the running nodes
+func running() []*sarwin.Engine {
+	enginesmu.Lock()
+	defer enginesmu.Unlock()
+	var es []*sarwin.Engine
+	for _, e := range engines {
+		es = append(es, e)
+	}
+	return es
+}
+
+// Register the gauges, they add up every running node so only once
+func gauges() {
+	gaugesonce.Do(func() {
+		metrics.NewGauge("saratoga_transfers_active", "Transfers running by type and direction",
+			[]string{"ttype", "direction"}, func() []metrics.Sample {
+				count := make(map[[2]string]float64)
+				for _, e := range running() {
+					for _, t := range e.Transfers.Snapshot() {
+						if t.Running() {
+							count[[2]string{t.Ttype, sarwin.Directions[t.Direction]}]++
+						}
+					}
+				}
+				var samples []metrics.Sample
+				for k, v := range count {
+					samples = append(samples, metrics.Sample{Labels: k[:], Value: v})
+				}
+				return samples
+			})
+		metrics.NewGauge("saratoga_holes_outstanding", "Holes still to be filled in running transfers",
+			nil, func() []metrics.Sample {
+				var holes float64
+				for _, e := range running() {
+					for _, t := range e.Transfers.Snapshot() {
+						if st := t.Stats(); st.State == sarwin.Running {
+							holes += float64(len(st.Holes))
+						}
+					}
+				}
+				return []metrics.Sample{{Value: holes}}
+			})
+		metrics.NewGauge("saratoga_peers", "Peers we have heard beacons from by state",
+			[]string{"state"}, func() []metrics.Sample {
+				count := map[string]float64{beacon.Active: 0, beacon.Lost: 0}
+				seen := make(map[*beacon.Registry]bool)
+				for _, e := range running() {
+					if seen[e.Peers] {
+						continue
+					}
+					seen[e.Peers] = true
+					for _, p := range e.Peers.Snapshot() {
+						count[p.State]++
+					}
+				}
+				var samples []metrics.Sample
+				for k, v := range count {
+					samples = append(samples, metrics.Sample{Labels: []string{k}, Value: v})
+				}
+				return samples
+			})
+	})
+}
@@ -0,0 +1,388 @@
+// Node - A Saratoga peer that can be embedded in a Go program
+// The listeners, frame handlers and transfer engine that main used to wire together
+
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/beacon"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+// Config - How to run a Node
+type Config struct {
+	Flags  *sarflags.Cliflags // Settings read from saratoga.json, copied into each transfer we start
+	Addr   string             // Unicast address to listen on e.g. 127.0.0.1:7542, "" if Conns are given
+	Conns  []*net.UDPConn     // Sockets already open to listen on such as the multicast ones
+	Logger sarlog.Logger      // Where log lines and events go, nil throws them away
+	Engine *sarwin.Engine     // Tables to run on, nil gives the node its own made from Flags
+}
+
+// StatusError - The peer answered our request with an errcode other than success
+type StatusError struct {
+	Errcode string
+}
+
+func (e *StatusError) Error() string {
+	return "peer replied " + e.Errcode
+}
+
+// Errors
+var (
+	ErrStarted    = errors.New("node: already started")
+	ErrNotStarted = errors.New("node: not started")
+	ErrNoConns    = errors.New("node: nothing to listen on")
+	ErrCancelled  = errors.New("node: transfer cancelled")
+)
+
+// Node - A running Saratoga peer
+// Each Node has its own peers, transfers, access control, keys and quotas in its Engine
+// so several can run in one process, main runs one on the process wide tables
+type Node struct {
+	cfg     Config
+	e       *sarwin.Engine
+	log     sarlog.Logger
+	mu      sync.Mutex
+	conns   []*net.UDPConn
+	subs    map[chan sarlog.Event]bool
+	mine    map[*sarwin.Transfer]bool // Transfers this node started
+	started bool
+	ctx     context.Context // Done when the node is stopping
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	err     error         // Why the node stopped
+	done    chan struct{} // Closed when the node has stopped
+}
+
+// New - A Node ready to Start
+func New(cfg Config) (*Node, error) {
+	if cfg.Flags == nil {
+		return nil, errors.New("node: no flags")
+	}
+	if cfg.Addr == "" && len(cfg.Conns) == 0 {
+		return nil, ErrNoConns
+	}
+	n := &Node{cfg: cfg, e: cfg.Engine, subs: make(map[chan sarlog.Event]bool),
+		mine: make(map[*sarwin.Transfer]bool), done: make(chan struct{})}
+	if n.e == nil {
+		var err error
+		if n.e, err = sarwin.NewEngine(cfg.Flags); err != nil {
+			return nil, err
+		}
+	}
+	n.log = cfg.Logger
+	if n.log == nil {
+		n.log = sarlog.Discard
+	}
+	return n, nil
+}
+
+// Start - Listen for frames and age out peers until ctx is done or Stop is called
+func (n *Node) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.started {
+		return ErrStarted
+	}
+	if sarflags.Mtu() == 0 { // Main sets it from the interface, assume ethernet if no one has
+		sarflags.MtuSet(1500)
+	}
+
+	n.conns = append(n.conns, n.cfg.Conns...)
+	if n.cfg.Addr != "" {
+		addr, err := net.ResolveUDPAddr("udp", n.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return err
+		}
+		n.conns = append(n.conns, conn)
+	}
+	n.started = true
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.ctx = ctx
+	for _, conn := range n.conns {
+		n.serve(ctx, conn, n.fail)
+	}
+	// Transfers we start, including those the cli starts on our engine,
+	// hear back from the responder on the connection they dialed it with
+	n.e.Transfers.OnAdd(func(t *sarwin.Transfer) {
+		if t.Direction == sarwin.Initiator && t.Conn != nil {
+			n.initiator(t)
+		}
+	})
+	register(n.e)
+	peers, unsubscribe := n.e.Peers.Subscribe(16)
+	n.wg.Add(2)
+	go func() {
+		defer n.wg.Done()
+		n.e.Peers.Aging(ctx, time.Second, n.e.PeerExpiry)
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.peerevents(peers)
+	}()
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+		unregister(n.e)
+		n.mu.Lock()
+		for _, conn := range n.conns {
+			conn.Close()
+		}
+		for t := range n.mine { // Their listeners stop too
+			t.Conn.Close()
+		}
+		n.mu.Unlock()
+		n.wg.Wait()
+		close(n.done)
+	}()
+	return nil
+}
+
+// Listen on conn and send what the handlers give back out of it until conn is closed
+// If reading from conn fails before ctx is done failed is called with the error
+func (n *Node) serve(ctx context.Context, conn *net.UDPConn, failed func(error)) {
+	tx := make(chan interface{})
+	n.wg.Add(2)
+	go func() {
+		defer n.wg.Done()
+		defer close(tx)
+		if err := n.listen(conn, tx); err != nil && ctx.Err() == nil {
+			failed(err)
+		}
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.send(conn, tx)
+	}()
+}
+
+// Listen for the responders frames on the connection an initiator dialed it with
+// Only once, whoever added the transfer may already be listening
+func (n *Node) initiator(t *sarwin.Transfer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ctx.Err() != nil || !t.Claim() {
+		return
+	}
+	n.mine[t] = true
+	l := logger{n}
+	// Removing the transfer closes the connection which stops its listener
+	tctx, stop := context.WithCancel(n.ctx)
+	context.AfterFunc(t.Context(), stop)
+	n.serve(tctx, t.Conn, func(err error) {
+		if !errors.Is(err, net.ErrClosed) {
+			l.Err("Cannot read from peer", sarlog.F("peer", t.Peer), sarlog.F("err", err))
+		}
+	})
+}
+
+// Stop - Stop listening and wait for the node to finish
+func (n *Node) Stop() {
+	n.mu.Lock()
+	cancel := n.cancel
+	n.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-n.done
+	}
+}
+
+// Done - Closed when the node has stopped
+func (n *Node) Done() <-chan struct{} {
+	return n.done
+}
+
+// Err - Why the node stopped, nil if it was stopped or is still running
+func (n *Node) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+// Stop the node because something it cannot run without has failed
+func (n *Node) fail(err error) {
+	n.mu.Lock()
+	if n.err == nil {
+		n.err = err
+	}
+	cancel := n.cancel
+	n.mu.Unlock()
+	cancel()
+}
+
+// Addrs - The local addresses the node is listening on
+func (n *Node) Addrs() []*net.UDPAddr {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	addrs := make([]*net.UDPAddr, 0, len(n.conns))
+	for _, conn := range n.conns {
+		addrs = append(addrs, conn.LocalAddr().(*net.UDPAddr))
+	}
+	return addrs
+}
+
+// Put - Send the file at path to peer
+func (n *Node) Put(ctx context.Context, peer *net.UDPAddr, path string) (*sarwin.Transfer, error) {
+	return n.request(ctx, "put", peer, path)
+}
+
+// Get - Get the file at path from peer
+func (n *Node) Get(ctx context.Context, peer *net.UDPAddr, path string) (*sarwin.Transfer, error) {
+	return n.request(ctx, "get", peer, path)
+}
+
+// Delete - Remove the file at path on peer
+func (n *Node) Delete(ctx context.Context, peer *net.UDPAddr, path string) (*sarwin.Transfer, error) {
+	return n.request(ctx, "delete", peer, path)
+}
+
+// ListDir - Get the directory at path from peer
+func (n *Node) ListDir(ctx context.Context, peer *net.UDPAddr, path string) (*sarwin.Transfer, error) {
+	return n.request(ctx, "getdir", peer, path)
+}
+
+// Start a transfer and wait for it to complete
+// A StatusError is returned if the peer refuses or cancels it, if ctx is done first the transfer is cancelled
+func (n *Node) request(ctx context.Context, ttype string, peer *net.UDPAddr, path string) (*sarwin.Transfer, error) {
+	n.mu.Lock()
+	running := n.started && n.ctx.Err() == nil
+	n.mu.Unlock()
+	if !running {
+		return nil, ErrNotStarted
+	}
+	l := logger{n}
+	t, err := n.e.NewInitiator(l, ttype, peer, "", path)
+	if err != nil {
+		return nil, err
+	}
+	n.initiator(t) // In case the node stopped before it could
+	errflag := make(chan error, 1)
+	go t.Do(l, errflag)
+	if err := <-errflag; err != nil {
+		t.Cancel(l, false)
+		return t, err
+	}
+	select {
+	case <-t.Done():
+		switch st := t.Stats(); {
+		case st.State == sarwin.Completed:
+			return t, nil
+		case st.Errcode == sarwin.Cancelled: // By us, it timed out or could not go on
+			return t, ErrCancelled
+		default:
+			return t, &StatusError{Errcode: st.Errcode}
+		}
+	case <-ctx.Done():
+		t.Cancel(l, false)
+		return t, ctx.Err()
+	case <-n.done:
+		return t, ErrNotStarted
+	}
+}
+
+// Engine - The tables the node runs on
+func (n *Node) Engine() *sarwin.Engine {
+	return n.e
+}
+
+// Logger - Lines go to the configured logger, events to it and the subscribers to Events
+func (n *Node) Logger() sarlog.Logger {
+	return logger{n}
+}
+
+// Peers - Who we have heard beacons from
+func (n *Node) Peers() []beacon.Peer {
+	return n.e.Peers.Snapshot()
+}
+
+// Transfers - The transfers this node started that are still listed
+func (n *Node) Transfers() []*sarwin.Transfer {
+	listed := make(map[*sarwin.Transfer]bool)
+	for _, t := range n.e.Transfers.Snapshot() {
+		listed[t] = true
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var ts []*sarwin.Transfer
+	for t := range n.mine {
+		if !listed[t] {
+			delete(n.mine, t)
+			continue
+		}
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// Events - Events from the node until cancel is called
+// Events are dropped rather than holding up the node if the channel is full
+func (n *Node) Events(buffer int) (<-chan sarlog.Event, func()) {
+	c := make(chan sarlog.Event, buffer)
+	n.mu.Lock()
+	n.subs[c] = true
+	n.mu.Unlock()
+	cancel := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if n.subs[c] {
+			delete(n.subs, c)
+			close(c)
+		}
+	}
+	return c, cancel
+}
+
+// Send the event to the subscribers and the configured logger
+func (n *Node) publish(e sarlog.Event) {
+	n.mu.Lock()
+	for c := range n.subs {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+	n.mu.Unlock()
+	if s, ok := n.log.(sarlog.EventSink); ok {
+		s.Event(e)
+	}
+}
+
+// Report peers joining, changing and being lost
+func (n *Node) peerevents(events <-chan beacon.Event) {
+	l := logger{n}
+	for ev := range events {
+		fields := []sarlog.Field{sarlog.F("peer", ev.Peer.Addr), sarlog.F("eid", ev.Peer.Eid)}
+		switch ev.Type {
+		case beacon.PeerJoined:
+			sarlog.Emit(l, sarlog.PeerJoined, fields...)
+		case beacon.PeerChanged:
+			sarlog.Emit(l, sarlog.PeerChanged, fields...)
+		case beacon.PeerLost:
+			sarlog.Emit(l, sarlog.PeerLost,
+				append(fields, sarlog.F("silent", ev.Peer.Age(time.Now()).Round(time.Second)))...)
+		}
+	}
+}
+
+// logger - What the engine is given to log to, lines go to the configured logger
+// and events to it and the nodes subscribers
+type logger struct {
+	n *Node
+}
+
+func (l logger) Msg(msg string, fields ...sarlog.Field)    { l.n.log.Msg(msg, fields...) }
+func (l logger) Err(msg string, fields ...sarlog.Field)    { l.n.log.Err(msg, fields...) }
+func (l logger) Packet(msg string, fields ...sarlog.Field) { l.n.log.Packet(msg, fields...) }
+func (l logger) Event(e sarlog.Event)                      { l.n.publish(e) }
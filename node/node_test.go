@@ -0,0 +1,640 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/auth"
+	"github.com/charlesetsmith/saratoga/beacon"
+	"github.com/charlesetsmith/saratoga/capability"
+	"github.com/charlesetsmith/saratoga/data"
+	"github.com/charlesetsmith/saratoga/dirent"
+	"github.com/charlesetsmith/saratoga/holes"
+	"github.com/charlesetsmith/saratoga/metadata"
+	"github.com/charlesetsmith/saratoga/metrics"
+	"github.com/charlesetsmith/saratoga/request"
+	"github.com/charlesetsmith/saratoga/sarcrypt"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/sarwin"
+	"github.com/charlesetsmith/saratoga/status"
+)
+
+func TestNode(t *testing.T) {
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+
+	// Two nodes talking to each other over loopback
+	start := func() *Node {
+		n, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n
+	}
+	a, b := start(), start()
+	if err := a.Start(context.Background()); err != ErrStarted {
+		t.Errorf("second start %v", err)
+	}
+	events, cancel := a.Events(16)
+	defer cancel()
+
+	fname := "victim.txt"
+	if err := os.WriteFile(filepath.Join(conf.Sardir, fname), []byte("bye"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	peer := b.Addrs()[0]
+	if _, err := a.Delete(ctx, peer, fname); err != nil {
+		t.Fatal("delete ", err)
+	}
+	if _, err := os.Stat(filepath.Join(conf.Sardir, fname)); !os.IsNotExist(err) {
+		t.Errorf("file still there %v", err)
+	}
+	select {
+	case e := <-events:
+		if e.Kind != sarlog.TransferAdded || e.Get("ttype") != "delete" {
+			t.Errorf("event %s %v", e.Kind, e.Fields)
+		}
+	default:
+		t.Error("no transfer.added event")
+	}
+
+	if metrics.FramesRx.Value("request", "ipv4") == 0 || metrics.Errcodes.Value("received", "success") == 0 {
+		t.Error("frames not counted")
+	}
+
+	// It has gone so the peer tells us so
+	_, err := a.Delete(ctx, peer, fname)
+	var se *StatusError
+	if !errors.As(err, &se) || se.Errcode != "filenotfound" {
+		t.Errorf("second delete %v", err)
+	}
+
+	// Nothing is answered once stopped
+	b.Stop()
+	select {
+	case <-b.Done():
+	default:
+		t.Error("not done after stop")
+	}
+	if b.Err() != nil {
+		t.Errorf("stopped with %v", b.Err())
+	}
+	if _, err := b.Delete(ctx, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, fname); err != ErrNotStarted {
+		t.Errorf("delete after stop %v", err)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	// Two nodes each with their own sardir and tables
+	start := func() (*Node, string) {
+		conf := sarflags.New()
+		conf.Sardir = t.TempDir()
+		conf.Timeout.Status = 1 // Ask again quickly for what loopback drops
+		n, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n, conf.Sardir
+	}
+	a, adir := start()
+	b, bdir := start()
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+
+	// Many frames worth so it takes more than one status to get it all
+	want := make([]byte, 200000)
+	rand.Read(want)
+	if err := os.WriteFile(filepath.Join(adir, "put.bin"), want, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := a.Put(ctx, b.Addrs()[0], "put.bin")
+	if err != nil {
+		t.Fatal("put ", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(bdir, "put.bin")); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("put file %d bytes want %d %v", len(got), len(want), err)
+	}
+	if st := tr.Stats(); st.State != "completed" || st.Progress != uint64(len(want)) {
+		t.Errorf("put %+v", st)
+	}
+	if len(a.Transfers()) != 1 || b.Engine().Transfers.Len() != 1 || a.Engine().Transfers == b.Engine().Transfers {
+		t.Errorf("transfers not kept apart a %d b %d", len(a.Transfers()), b.Engine().Transfers.Len())
+	}
+	if r := b.Engine().Limits.Reserved(); r != 0 {
+		t.Errorf("%d bytes still reserved after put", r)
+	}
+
+	// Metadata for a transfer nobody asked for holds no space
+	var d dirent.DirEnt
+	fi, err := os.Stat(filepath.Join(adir, "put.bin"))
+	if err == nil {
+		err = d.Info("put.bin", fi)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := metadata.New("descriptor=d64,transfer=file,progress=inprogress,reliability=udponly",
+		metadata.Minfo{Session: 99, Dir: &d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := net.DialUDP("udp", nil, b.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	for i := 0; i < 3; i++ {
+		if err := m.Send(raw, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if r := b.Engine().Limits.Reserved(); r != 0 {
+		t.Errorf("%d bytes reserved for unasked metadata", r)
+	}
+
+	// And back again as another name, an empty file too
+	if err := os.WriteFile(filepath.Join(bdir, "empty"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(bdir, "put.bin"), filepath.Join(bdir, "get.bin")); err != nil {
+		t.Fatal(err)
+	}
+	for _, fname := range []string{"get.bin", "empty"} {
+		if _, err := a.Get(ctx, b.Addrs()[0], fname); err != nil {
+			t.Fatal("get ", fname, " ", err)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(adir, "get.bin")); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("get file %d bytes want %d %v", len(got), len(want), err)
+	}
+	if fi, err := os.Stat(filepath.Join(adir, "empty")); err != nil || fi.Size() != 0 {
+		t.Errorf("empty file %v", err)
+	}
+	// We will not overwrite what we have
+	if _, err := a.Get(ctx, b.Addrs()[0], "get.bin"); err == nil {
+		t.Error("get over an existing file")
+	}
+
+	tr, err = a.ListDir(ctx, b.Addrs()[0], "/")
+	if err != nil {
+		t.Fatal("getdir ", err)
+	}
+	ents, err := tr.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(map[string]uint64)
+	for _, d := range ents {
+		sizes[d.Path] = d.Size
+	}
+	if len(sizes) != 2 || sizes["get.bin"] != uint64(len(want)) || sizes["empty"] != 0 {
+		t.Errorf("getdir %v", sizes)
+	}
+}
+
+func TestCapability(t *testing.T) {
+	// A node willing to do everything and two that are not
+	start := func(flag string, value string) (*Node, string) {
+		conf := sarflags.New()
+		conf.Sardir = t.TempDir()
+		conf.Timeout.Status = 1
+		if flag != "" {
+			conf.Global[flag] = value
+		}
+		n, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n, conf.Sardir
+	}
+	a, adir := start("", "")
+	norx, _ := start("rxwilling", "no")
+	notx, notxdir := start("txwilling", "no")
+	for dir, fname := range map[string]string{adir: "put.txt", notxdir: "get.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, fname), []byte("capable"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+
+	// Not having heard their beacons we ask and they refuse
+	var se *StatusError
+	if _, err := a.Put(ctx, norx.Addrs()[0], "put.txt"); !errors.As(err, &se) || se.Errcode != "cantreceive" {
+		t.Errorf("put to rxwilling=no %v", err)
+	}
+	if _, err := a.Get(ctx, notx.Addrs()[0], "get.txt"); !errors.As(err, &se) || se.Errcode != "cantsend" {
+		t.Errorf("get from txwilling=no %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(adir, "get.txt")); !os.IsNotExist(err) {
+		t.Errorf("refused get left a file %v", err)
+	}
+
+	// Once it has beaconed that it will not receive we do not ask
+	conn, err := net.DialUDP("udp4", nil, a.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var b beacon.Beacon
+	if err := b.New("descriptor=d32,freespace=no,rxwilling=no,txwilling=yes", &beacon.Binfo{Eid: "dtn://norx"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Send(conn); err != nil {
+		t.Fatal(err)
+	}
+	for end := time.Now().Add(2 * time.Second); a.Engine().Peers.Len() == 0 && time.Now().Before(end); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = a.Put(ctx, norx.Addrs()[0], "put.txt")
+	if err == nil || errors.As(err, &se) || !strings.Contains(err.Error(), capability.ErrPeerRx.Error()) {
+		t.Errorf("put to a peer beaconing rxwilling=no %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+	a, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"})
+	if err == nil {
+		err = a.Start(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+	if err := os.WriteFile(filepath.Join(conf.Sardir, "put.txt"), []byte("never all of it"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A peer that never answers so the transfers are still running when we cancel them
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	to := peer.LocalAddr().(*net.UDPAddr)
+	g, c := sarwin.NewCapture()
+	defer c.Close()
+	c.SetEngine(a.Engine())
+
+	// Cancelled from the cli the transfer ends cancelled and the peer hears how
+	for ttype, told := range map[string]func(h uint32) bool{
+		"put": func(h uint32) bool { // A sender ends with terminated metadata
+			return sarflags.Header(h).Frametype() == sarflags.FrametypeMetadata &&
+				sarflags.MetadataHeader(h).Progress() == sarflags.ProgressTerminated
+		},
+		"get": func(h uint32) bool { // A receiver with a status saying it is not interested
+			return sarflags.Header(h).Frametype() == sarflags.FrametypeStatus &&
+				sarflags.StatusHeader(h).Errcode() == sarflags.ErrcodeRxnotinterested
+		},
+	} {
+		ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		result := make(chan error, 1)
+		go func() {
+			var err error
+			if ttype == "put" {
+				_, err = a.Put(ctx, to, "put.txt")
+			} else {
+				_, err = a.Get(ctx, to, "get.txt")
+			}
+			result <- err
+		}()
+		var tr *sarwin.Transfer
+		for end := time.Now().Add(2 * time.Second); tr == nil && time.Now().Before(end); {
+			for _, i := range a.Transfers() {
+				if i.Ttype == ttype && i.Running() {
+					tr = i
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if tr == nil {
+			t.Fatalf("%s not started", ttype)
+		}
+		if err := sarwin.Exec(g, fmt.Sprintf("cancel %s %d", to, tr.Session)); err != nil {
+			t.Fatal(err)
+		}
+		if errs := c.Lines("err"); len(errs) != 0 {
+			t.Fatalf("cancel %s %q", ttype, errs)
+		}
+		if err := <-result; err != ErrCancelled {
+			t.Errorf("%s ended with %v", ttype, err)
+		}
+		if st := tr.Stats(); st.State != sarwin.Cancelled {
+			t.Errorf("%s state %s", ttype, st.State)
+		}
+		buf := make([]byte, 9000)
+		heard := false
+		for !heard {
+			peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, err := peer.Read(buf)
+			if err != nil {
+				t.Fatalf("peer not told of %s cancel: %v", ttype, err)
+			}
+			heard = n >= 8 && told(binary.BigEndian.Uint32(buf))
+		}
+		done()
+	}
+}
+
+func TestRetransmitted(t *testing.T) {
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+	conf.Timeout.Status = 0 // Only resend what the status asks for
+	a, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"})
+	if err == nil {
+		err = a.Start(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+	want := make([]byte, 20000)
+	rand.Read(want)
+	if err := os.WriteFile(filepath.Join(conf.Sardir, "put.bin"), want, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// We are the receiver so we say what went missing
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	result := make(chan error, 1)
+	go func() {
+		_, err := a.Put(ctx, peer.LocalAddr().(*net.UDPAddr), "put.bin")
+		result <- err
+	}()
+	var from *net.UDPAddr
+	var session uint32
+	buf := make([]byte, 9000)
+	next := func(frametype string) []byte {
+		for {
+			peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, addr, err := peer.ReadFromUDP(buf)
+			if err != nil {
+				t.Fatalf("no %s frame: %v", frametype, err)
+			}
+			if n >= 8 && sarflags.Header(binary.BigEndian.Uint32(buf)).Frametype().String() == frametype {
+				from, session = addr, binary.BigEndian.Uint32(buf[4:8])
+				return buf[:n]
+			}
+		}
+	}
+	reply := func(progress uint64, h holes.Holes) {
+		st, err := status.New("descriptor=d64,metadatarecvd=yes,allholes=yes,reqholes=requested,errcode=success",
+			status.Sinfo{Session: session, Progress: progress, Inrespto: uint64(len(want)), Holes: h})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Send(peer, from); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := func() (uint64, uint64) {
+		var d data.Data
+		if err := d.Decode(next("data")); err != nil {
+			t.Fatal(err)
+		}
+		return d.Offset, d.Offset + uint64(len(d.Payload))
+	}
+
+	next("request")
+	reply(0, nil) // We agree
+	_, plen := sent()
+	for end := plen; end < uint64(len(want)); {
+		_, end = sent()
+	}
+	var out bytes.Buffer
+	if err := metrics.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if active := `saratoga_transfers_active{ttype="put",direction="Initiator"} 1`; !strings.Contains(out.String(), active) {
+		t.Errorf("no %s in\n%s", active, out.String())
+	}
+
+	// Only the first frame went missing so only it is sent again
+	before := metrics.Retransmitted.Value()
+	reply(plen, holes.Holes{{Start: 0, End: int(plen)}})
+	if off, end := sent(); off != 0 || end != plen {
+		t.Errorf("resent %d-%d want 0-%d", off, end, plen)
+	}
+	if got := metrics.Retransmitted.Value() - before; got != float64(plen) {
+		t.Errorf("retransmitted %v bytes want %d", got, plen)
+	}
+	reply(uint64(len(want)), nil)
+	if err := <-result; err != nil {
+		t.Errorf("put %v", err)
+	}
+	if metrics.FramesRx.Value("status", "ipv4") < 3 || metrics.FramesTx.Value("data", "ipv4") == 0 {
+		t.Error("frames not counted")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	// Two nodes sharing a key that will only talk encrypted
+	keyfile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keyfile, []byte("127.0.0.1 0123456789abcdef0123456789abcdef\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	start := func() (*Node, string) {
+		conf := sarflags.New()
+		conf.Sardir = t.TempDir()
+		conf.Timeout.Status = 1
+		conf.Global["encrypt"] = "yes"
+		conf.Auth.Keyfile, conf.Auth.Required = keyfile, "yes"
+		n, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n, conf.Sardir
+	}
+	a, adir := start()
+	b, bdir := start()
+	if k := a.Engine().Keys; k == nil || !k.Required {
+		t.Fatal("keys not loaded from the key file")
+	}
+	conf := sarflags.New()
+	conf.Auth.Keyfile = filepath.Join(t.TempDir(), "nokeys")
+	if _, err := New(Config{Flags: conf, Addr: "127.0.0.1:0"}); err == nil {
+		t.Error("node without its key file")
+	}
+
+	// Everything between them goes through a relay that keeps a copy
+	relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	tob, err := net.DialUDP("udp4", nil, b.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tob.Close()
+	var mu sync.Mutex
+	var wire [][]byte
+	var froma *net.UDPAddr
+	keep := func(frame []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		wire = append(wire, append([]byte(nil), frame...))
+	}
+	go func() {
+		buf := make([]byte, 9000)
+		for {
+			n, from, err := relay.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			froma = from
+			mu.Unlock()
+			keep(buf[:n])
+			tob.Write(buf[:n])
+		}
+	}()
+	go func() {
+		buf := make([]byte, 9000)
+		for {
+			n, err := tob.Read(buf)
+			if err != nil {
+				return
+			}
+			keep(buf[:n])
+			mu.Lock()
+			to := froma
+			mu.Unlock()
+			relay.WriteToUDP(buf[:n], to)
+		}
+	}()
+
+	want := bytes.Repeat([]byte("saratoga plaintext "), 1000)
+	if err := os.WriteFile(filepath.Join(adir, "secret.txt"), want, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+	tr, err := a.Put(ctx, relay.LocalAddr().(*net.UDPAddr), "secret.txt")
+	if err != nil {
+		t.Fatal("put ", err)
+	}
+	if tr.Crypt == nil {
+		t.Error("transfer not encrypted")
+	}
+	if got, err := os.ReadFile(filepath.Join(bdir, "secret.txt")); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("file on disk %d bytes want %d %v", len(got), len(want), err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var datas int
+	for _, frame := range wire { // Only the request names the file, the responder needs that to agree
+		if bytes.Contains(frame, []byte("plaintext")) || bytes.Contains(frame, []byte("secret.txt")) &&
+			sarflags.Header(binary.BigEndian.Uint32(frame[:4])).Frametype().String() != "request" {
+			t.Fatalf("frame in the clear %q", frame)
+		}
+		if sarflags.Header(binary.BigEndian.Uint32(frame[:4])).Frametype().String() == "data" {
+			datas++
+		}
+	}
+	if datas < len(want)/int(sarflags.Mtu()) {
+		t.Errorf("only %d data frames relayed", datas)
+	}
+
+	// Being told the sender has given up only ends an encrypted transfer when it was sealed with its key
+	key, _ := hex.DecodeString("0123456789abcdef0123456789abcdef") // As in the key file
+	spoofer, err := net.DialUDP("udp4", nil, b.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spoofer.Close()
+	h := sarflags.NewRequestHeader()
+	h.SetReqtype(sarflags.ReqtypePut)
+	h.SetEncrypt(true)
+	sig, err := auth.Sign(key, uint32(h), 99, "spoof.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer answer.Close()
+	rx, err := b.Engine().NewResponder(sarlog.Discard, answer, &request.Request{Header: uint32(h), Session: 99,
+		Fname: "spoof.txt", Auth: sig}, "spoof.txt", spoofer.LocalAddr().(*net.UDPAddr), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d dirent.DirEnt
+	if err := d.Info("spoof.txt", fi); err != nil {
+		t.Fatal(err)
+	}
+	terminated := func(crypt *sarcrypt.Session) {
+		m, err := metadata.New("descriptor=d32,transfer=file,progress=terminated,reliability=udponly,csumtype=none,encrypt=yes",
+			metadata.Minfo{Session: 99, Dir: &d})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crypt != nil {
+			m.Dir.Path = crypt.SealPath(m.Header, m.Dir.Path)
+		}
+		if err := m.Send(spoofer, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	terminated(nil)
+	select {
+	case <-rx.Done():
+		t.Fatal("unsealed metadata ended an encrypted transfer")
+	case <-time.After(200 * time.Millisecond):
+	}
+	crypt, err := sarcrypt.New(key, 99, auth.Salt(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	terminated(crypt)
+	select {
+	case <-rx.Done():
+	case <-time.After(2 * time.Second):
+		t.Error("sealed metadata did not end the transfer")
+	}
+}
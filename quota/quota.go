@@ -0,0 +1,141 @@
+// Receiver freespace and per peer quotas for incoming files
+
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarsys"
+)
+
+// Errors when an incoming file will not fit
+var (
+	ErrNoSpace = errors.New("quota: not enough free space")
+	ErrQuota   = errors.New("quota: peer quota exceeded")
+)
+
+const mb = 1024 * 1024
+
+// Available - Bytes available to us on the file system holding dir
+// A variable so it can be replaced when testing
+var Available = func(dir string) uint64 {
+	return sarsys.NewDiskUsage(dir).Available()
+}
+
+// Key of an incoming file
+type key struct {
+	peer    string
+	session uint32
+}
+
+// Quotas - Space checks made before we accept an incoming file
+// Every accepted file holds a reservation until its transfer finishes so that
+// transfers in progress cannot between them overcommit the disk or a peers quota
+type Quotas struct {
+	mu       sync.Mutex
+	Sardir   string            // Where incoming files are written
+	Reserve  uint64            // Bytes always kept free
+	Default  uint64            // Bytes a peer without its own quota can have in progress, 0 is unlimited
+	Peers    map[string]uint64 // Bytes a peer IP address or EID can have in progress, 0 is unlimited
+	reserved map[key]uint64    // Bytes reserved by each incoming file
+}
+
+// New - Set up the quotas from the config, config values are in MB
+func New(c *sarflags.Cliflags) *Quotas {
+	q := &Quotas{
+		Sardir:   c.Sardir,
+		Reserve:  c.Quota.Reserve * mb,
+		Default:  c.Quota.Default * mb,
+		Peers:    make(map[string]uint64),
+		reserved: make(map[key]uint64),
+	}
+	for p, v := range c.Quota.Peers {
+		if ip := net.ParseIP(p); ip != nil {
+			p = ip.String()
+		}
+		q.Peers[p] = v * mb
+	}
+	return q
+}
+
+// The quota for the peer, an EID quota takes precedence over an address quota
+func (q *Quotas) limit(addr net.IP, eid string) uint64 {
+	if eid != "" {
+		if l, ok := q.Peers[eid]; ok {
+			return l
+		}
+	}
+	if l, ok := q.Peers[addr.String()]; ok {
+		return l
+	}
+	return q.Default
+}
+
+// Check - Reserve size bytes for the incoming file of session from the peer
+// Metadata can be resent so a second check for the same session replaces its reservation
+func (q *Quotas) Check(addr net.IP, eid string, session uint32, size uint64) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	k := key{peer: addr.String(), session: session}
+	var total, peer uint64 // What is already reserved in total and by this peer
+	for rk, rv := range q.reserved {
+		if rk == k {
+			continue
+		}
+		total += rv
+		if rk.peer == k.peer {
+			peer += rv
+		}
+	}
+	if l := q.limit(addr, eid); l != 0 && peer+size > l {
+		return fmt.Errorf("%w: %d bytes with %d in progress, quota %d", ErrQuota, size, peer, l)
+	}
+	// Reservations have not been written yet so still show as available
+	avail := Available(q.Sardir)
+	if avail < q.Reserve+total || size > avail-q.Reserve-total {
+		return fmt.Errorf("%w: %d bytes with %d available", ErrNoSpace, size, avail)
+	}
+	q.reserved[k] = size
+	return nil
+}
+
+// Release - The incoming file of session from the peer is finished with
+func (q *Quotas) Release(addr net.IP, session uint32) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.reserved, key{peer: addr.String(), session: session})
+}
+
+// Reserved - Bytes held for incoming files in total
+func (q *Quotas) Reserved() uint64 {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var total uint64
+	for _, v := range q.reserved {
+		total += v
+	}
+	return total
+}
+
+// Fits - Will size bytes fit in the freespace (kB) a peer advertised in its beacons
+// A peer that does not advertise its freespace might have room so we let it decide
+func Fits(freespace uint64, size uint64) bool {
+	if freespace == 0 {
+		return true
+	}
+	return size <= freespace*1024
+}
@@ -0,0 +1,75 @@
+package quota
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+func TestQuota(t *testing.T) {
+	var avail uint64 = 1000 * mb
+	Available = func(dir string) uint64 { return avail }
+
+	conf := new(sarflags.Cliflags)
+	conf.Quota.Reserve = 100
+	conf.Quota.Default = 500
+	conf.Quota.Peers = map[string]uint64{"10.1.2.3": 50, "dtn://ground": 0}
+	q := New(conf)
+
+	limited := net.ParseIP("10.1.2.3")
+	other := net.ParseIP("10.9.9.9")
+
+	// Per peer quotas
+	if err := q.Check(limited, "", 1, 40*mb); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Check(limited, "", 2, 20*mb); !errors.Is(err, ErrQuota) {
+		t.Error("peer quota not enforced across transfers:", err)
+	}
+	if err := q.Check(limited, "", 1, 45*mb); err != nil { // Resent metadata replaces its reservation
+		t.Error("resent metadata rejected:", err)
+	}
+	q.Release(limited, 1)
+	if err := q.Check(limited, "", 2, 50*mb); err != nil {
+		t.Error("released space not reusable:", err)
+	}
+	if err := q.Check(limited, "dtn://ground", 3, 600*mb); err != nil { // EID is unlimited
+		t.Error("eid quota not used:", err)
+	}
+	if r := q.Reserved(); r != 650*mb {
+		t.Errorf("reserved %d", r)
+	}
+	q.Release(limited, 2)
+	q.Release(limited, 3)
+	if err := q.Check(other, "", 4, 501*mb); !errors.Is(err, ErrQuota) {
+		t.Error("default quota not enforced:", err)
+	}
+
+	// Freespace less the reserve and what is already in progress
+	if err := q.Check(other, "", 5, 400*mb); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Check(limited, "dtn://ground", 6, 501*mb); !errors.Is(err, ErrNoSpace) {
+		t.Error("freespace not enforced:", err)
+	}
+	if err := q.Check(limited, "dtn://ground", 6, 500*mb); err != nil {
+		t.Error("file that fits rejected:", err)
+	}
+	avail = 50 * mb // Less than the reserve
+	if err := q.Check(other, "", 7, 1); !errors.Is(err, ErrNoSpace) {
+		t.Error("reserve not kept:", err)
+	}
+
+	// No quotas set up
+	var none *Quotas
+	if err := none.Check(other, "", 8, 1<<62); err != nil {
+		t.Error("nil quotas rejected file:", err)
+	}
+
+	// Beaconed freespace is in kB, 0 is not advertised
+	if !Fits(0, 1<<40) || !Fits(1, 1024) || Fits(1, 1025) {
+		t.Error("Fits wrong")
+	}
+}
@@ -7,9 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"reflect"
 	"strings"
 
+	"github.com/charlesetsmith/saratoga/frames"
+	"github.com/charlesetsmith/saratoga/metrics"
 	"github.com/charlesetsmith/saratoga/sarflags"
 )
 
@@ -26,6 +27,8 @@ type Rinfo struct {
 	Auth    []byte
 }
 
+var _ frames.Frame[Rinfo] = (*Request)(nil)
+
 type Packet struct {
 	Addr net.UDPAddr
 	Info Request
@@ -38,8 +41,7 @@ func (r *Request) Val(addr *net.UDPAddr) Packet {
 }
 
 // New - Construct a request - Fill in the request struct
-// func (r *Request) New(flags string, session uint32, fname string, auth []byte) error {
-func (r *Request) New(flags string, info interface{}) error {
+func (r *Request) New(flags string, info *Rinfo) error {
 	var err error
 
 	// Always present in a Request
@@ -60,7 +62,7 @@ func (r *Request) New(flags string, info interface{}) error {
 		f := strings.Split(flag[fl], "=") // f[0]=name f[1]=val
 		switch f[0] {
 		case "frametype", "version", "descriptor", "stream", "txwilling",
-			"rxwilling", "fileordir", "reqtype", "udplite":
+			"rxwilling", "fileordir", "reqtype", "udplite", "encrypt":
 			if r.Header, err = sarflags.Set(r.Header, f[0], f[1]); err != nil {
 				return err
 			}
@@ -68,17 +70,12 @@ func (r *Request) New(flags string, info interface{}) error {
 			return errors.New("Request.New: Invalid Flag " + f[0] + "=" + f[1] + "<" + flags + ">")
 		}
 	}
-	e := reflect.ValueOf(info).Elem()
-	r.Session = uint32(e.FieldByName("Session").Uint())
-	r.Fname = e.FieldByName("Fname").String()
-	copy(r.Auth, e.FieldByName("Auth").Bytes())
-
+	r.info(info)
 	return nil
 }
 
 // Make - Construct a request frame with a given header
-// func (r *Request) Make(header uint32, session uint32, fname string, auth []byte) error {
-func (r *Request) Make(header uint32, info interface{}) error {
+func (r *Request) Make(header uint32, info *Rinfo) error {
 
 	var err error
 
@@ -92,14 +89,38 @@ func (r *Request) Make(header uint32, info interface{}) error {
 
 	r.Header = header
 
-	e := reflect.ValueOf(info).Elem()
-	r.Session = uint32(e.Elem().FieldByName("Session").Uint())
-	r.Fname = e.FieldByName("Fname").String()
-	copy(r.Auth, e.FieldByName("Auth").Bytes())
-
+	r.info(info)
 	return nil
 }
 
+// Assign the values from the Rinfo structure, the auth is copied
+func (r *Request) info(info *Rinfo) {
+	r.Session = info.Session
+	r.Fname = info.Fname
+	r.Auth = nil
+	if len(info.Auth) > 0 {
+		r.Auth = append(r.Auth, info.Auth...)
+	}
+}
+
+// New - A request frame from flags and info, see Request.New
+func New(flags string, info Rinfo) (*Request, error) {
+	r := new(Request)
+	if err := r.New(flags, &info); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Make - A request frame with a given header and info, see Request.Make
+func Make(header uint32, info Rinfo) (*Request, error) {
+	r := new(Request)
+	if err := r.Make(header, &info); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 // Put -- Encode the Saratoga Request buffer
 func (r *Request) Encode() ([]byte, error) {
 
@@ -154,7 +175,7 @@ func (r Request) Print() string {
 	}
 	sflag += fmt.Sprintf("  session:%d\n", r.Session)
 	sflag += fmt.Sprintf("  filename:%s\n", r.Fname)
-	sflag += fmt.Sprintf("  auth:%s", r.Auth)
+	sflag += fmt.Sprintf("  auth:%x", r.Auth)
 	return sflag
 }
 
@@ -163,7 +184,7 @@ func (r Request) ShortPrint() string {
 	sflag := fmt.Sprintf("Request: 0x%x\n", r.Header)
 	sflag += fmt.Sprintf("  session:%d\n", r.Session)
 	sflag += fmt.Sprintf("  filename:%s\n", r.Fname)
-	sflag += fmt.Sprintf("  auth:%s", r.Auth)
+	sflag += fmt.Sprintf("  auth:%x", r.Auth)
 	return sflag
 }
 
@@ -176,9 +197,16 @@ func (r Request) Send(conn *net.UDPConn, to *net.UDPAddr) error {
 	if buf, err = r.Encode(); err != nil {
 		return err
 	}
-	if wlen, err = conn.WriteTo(buf, to); err != nil {
+	// A connection dialed to the peer can only Write
+	if conn.RemoteAddr() != nil {
+		wlen, err = conn.Write(buf)
+	} else {
+		wlen, err = conn.WriteTo(buf, to)
+	}
+	if err != nil {
 		return err
 	}
+	metrics.Sent("request", conn, to, wlen)
 	if wlen != len(buf) {
 		return fmt.Errorf("Request sent (%d) to %s != frame size (%d)", wlen, to.String(), len(buf))
 	}
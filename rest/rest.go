@@ -0,0 +1,287 @@
+// Rest - An HTTP server showing and driving peers and transfers
+// JSON for dashboards and scripts, a status page for people
+
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/control"
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/sarnet"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+// Start - What POST /transfers wants, the request timeout is in secs
+// e.g. {"ttype":"put","peer":"192.168.1.1","file":"afile","timeout":30}
+type Start struct {
+	Ttype   string `json:"ttype"` // put, get, delete or getdir
+	Peer    string `json:"peer"`
+	File    string `json:"file"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+// Error - What we send back when a request fails
+type Error struct {
+	Errcode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+// Server - The HTTP server
+type Server struct {
+	addr string
+	ctl  *control.Server
+	log  sarlog.Logger
+	tlog sarlog.Logger // What cancels log to, the node so its subscribers see the events
+	srv  *http.Server
+	ln   net.Listener
+}
+
+// New - A Server for n listening on addr, with no host given it listens on localhost only
+func New(addr string, n *node.Node, l sarlog.Logger) *Server {
+	if l == nil {
+		l = sarlog.Discard
+	}
+	s := &Server{addr: sarnet.LocalAddr(addr), ctl: control.New("", n, l), log: l, tlog: l}
+	if n != nil {
+		s.tlog = n.Logger()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.status)
+	mux.HandleFunc("/peers", s.peers)
+	mux.HandleFunc("/transfers", s.transfers)
+	mux.HandleFunc("/transfers/", s.transfer)
+	s.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// Start - Listen and serve in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.log.Err("HTTP server failed", sarlog.F("addr", s.addr), sarlog.F("err", err))
+		}
+	}()
+	s.log.Msg("HTTP server listening", sarlog.F("addr", ln.Addr()))
+	return nil
+}
+
+// Addr - Where we are listening, nil before Start
+func (s *Server) Addr() net.Addr {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close - Stop serving, requests waiting on peers are dropped
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// Write v as JSON with status code
+func reply(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func fail(w http.ResponseWriter, code int, errcode string, err string) {
+	reply(w, code, Error{Errcode: errcode, Error: err})
+}
+
+// Only let a request change anything if it was made to us by name and not from another sites page
+// A browser sends the Host it looked up and the Origin of the page, a page elsewhere cannot fake either
+func (s *Server) trusted(w http.ResponseWriter, r *http.Request) bool {
+	if !s.local(r.Host) {
+		fail(w, http.StatusForbidden, control.BadUsage, "host "+r.Host+" is not this server")
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			fail(w, http.StatusForbidden, control.BadUsage, "cross origin request from "+origin)
+			return false
+		}
+	}
+	return true
+}
+
+// Is host what we are reached by, localhost, a loopback address or the address we were told to listen on
+// Other names could point anywhere so are refused
+func (s *Server) local(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	listen, _, _ := net.SplitHostPort(s.addr)
+	return ip.Equal(net.ParseIP(listen))
+}
+
+// GET /peers
+func (s *Server) peers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		fail(w, http.StatusMethodNotAllowed, control.BadUsage, "GET only")
+		return
+	}
+	reply(w, http.StatusOK, control.Peers(s.ctl.Engine()))
+}
+
+// GET /transfers and POST /transfers
+func (s *Server) transfers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		reply(w, http.StatusOK, control.Transfers(s.ctl.Engine()))
+	case http.MethodPost:
+		if !s.trusted(w, r) {
+			return
+		}
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+			fail(w, http.StatusUnsupportedMediaType, control.BadUsage, "Content-Type must be application/json")
+			return
+		}
+		var st Start
+		if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+			fail(w, http.StatusBadRequest, control.BadJSON, err.Error())
+			return
+		}
+		switch st.Ttype {
+		case "put", "get", "delete", "getdir":
+		default:
+			fail(w, http.StatusBadRequest, control.BadUsage, "ttype must be put, get, delete or getdir")
+			return
+		}
+		resp := s.ctl.Do(r.Context(), control.Request{Cmd: st.Ttype, Args: []string{st.Peer, st.File},
+			Timeout: st.Timeout})
+		switch resp.Errcode {
+		case control.Success:
+			reply(w, http.StatusCreated, resp.Result)
+		case control.BadUsage:
+			fail(w, http.StatusBadRequest, resp.Errcode, resp.Error)
+		case control.Timeout:
+			fail(w, http.StatusGatewayTimeout, resp.Errcode, resp.Error)
+		case control.Error, control.Failed, control.BadCommand:
+			fail(w, http.StatusInternalServerError, resp.Errcode, resp.Error)
+		default: // The peer said no
+			fail(w, http.StatusBadGateway, resp.Errcode, resp.Error)
+		}
+	default:
+		fail(w, http.StatusMethodNotAllowed, control.BadUsage, "GET or POST only")
+	}
+}
+
+// GET and DELETE /transfers/{peer}/{session}[?direction=initiator|responder]
+// DELETE cancels the transfer and tells the peer, ?rm=yes removes what we have received
+func (s *Server) transfer(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/transfers/"), "/")
+	if len(parts) != 2 {
+		fail(w, http.StatusNotFound, control.BadUsage, "want /transfers/{peer}/{session}")
+		return
+	}
+	ip := net.ParseIP(parts[0])
+	if ip == nil {
+		if host, _, err := net.SplitHostPort(parts[0]); err == nil {
+			ip = net.ParseIP(host)
+		}
+	}
+	session, err := strconv.ParseUint(parts[1], 10, 32)
+	if ip == nil || err != nil {
+		fail(w, http.StatusBadRequest, control.BadUsage, "bad peer or session")
+		return
+	}
+	var t *sarwin.Transfer
+	for _, f := range s.ctl.Engine().Transfers.Find(ip, uint32(session)) {
+		if d := r.URL.Query().Get("direction"); d == "" || strings.EqualFold(d, sarwin.Directions[f.Direction]) {
+			t = f
+			break
+		}
+	}
+	if t == nil {
+		fail(w, http.StatusNotFound, "unknownid", "no such transfer")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		reply(w, http.StatusOK, control.TransferOf(t))
+	case http.MethodDelete:
+		if !s.trusted(w, r) {
+			return
+		}
+		err := t.Cancel(s.tlog, r.URL.Query().Get("rm") == "yes")
+		switch {
+		case errors.Is(err, sarwin.ErrNotRunning):
+			fail(w, http.StatusConflict, control.Error, err.Error())
+		case err != nil: // Cancelled but the peer may not know
+			fail(w, http.StatusBadGateway, control.Error, err.Error())
+		default:
+			reply(w, http.StatusOK, control.TransferOf(t))
+		}
+	default:
+		fail(w, http.StatusMethodNotAllowed, control.BadUsage, "GET or DELETE only")
+	}
+}
+
+var page = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>Saratoga</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #999; padding: 2px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Saratoga {{.Time.Format "2006-01-02 15:04:05"}}</h1>
+<h2>Peers</h2>
+{{if .Peers}}<table>
+<tr><th>Address</th><th>EID</th><th>Free</th><th>Rx</th><th>Tx</th><th>Age</th><th>State</th></tr>
+{{range .Peers}}<tr><td>{{.Addr}}</td><td>{{.Eid}}</td><td>{{.Freespace}}</td><td>{{.Canrx}}</td><td>{{.Cantx}}</td><td>{{.Age}}</td><td>{{.State}}</td></tr>
+{{end}}</table>{{else}}<p>No Peers</p>{{end}}
+<h2>Transfers</h2>
+{{if .Transfers}}<table>
+<tr><th>Direction</th><th>Type</th><th>Peer</th><th>Session</th><th>File</th><th>State</th><th>Errcode</th><th>Progress</th><th>Size</th><th>Rate B/s</th><th>Holes</th></tr>
+{{range .Transfers}}<tr><td>{{.Direction}}</td><td>{{.Ttype}}</td><td>{{.Peer}}</td><td>{{.Session}}</td><td>{{.File}}</td><td>{{.State}}</td><td>{{.Errcode}}</td><td>{{.Progress}}</td><td>{{.Size}}</td><td>{{printf "%.0f" .Rate}}</td><td>{{len .Holes}}</td></tr>
+{{end}}</table>{{else}}<p>No Transfers</p>{{end}}
+</body>
+</html>
+`))
+
+// GET / - The status page
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.Execute(w, struct {
+		Time      time.Time
+		Peers     []control.Peer
+		Transfers []control.Transfer
+	}{time.Now(), control.Peers(s.ctl.Engine()), control.Transfers(s.ctl.Engine())})
+}
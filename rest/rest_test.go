@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charlesetsmith/saratoga/control"
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+func TestRest(t *testing.T) {
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+	sarflags.Cliflag = conf
+
+	start := func() *node.Node {
+		n, err := node.New(node.Config{Flags: conf, Addr: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(n.Stop)
+		return n
+	}
+	a, b := start(), start()
+
+	s := New("0", a, nil)
+	if s.addr != "127.0.0.1:0" {
+		t.Errorf("listening on %s not localhost", s.addr)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	url := "http://" + s.Addr().String()
+
+	do := func(method string, path string, body string, want int, v interface{}) {
+		req, err := http.NewRequest(method, url+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Errorf("%s %s got %d want %d %s", method, path, resp.StatusCode, want, data)
+			return
+		}
+		if v != nil {
+			if err := json.Unmarshal(data, v); err != nil {
+				t.Errorf("%s %s %v %s", method, path, err, data)
+			}
+		}
+	}
+
+	var peers []control.Peer
+	do("GET", "/peers", "", http.StatusOK, &peers)
+	do("POST", "/peers", "", http.StatusMethodNotAllowed, nil)
+	do("GET", "/transfers/127.0.0.1/99", "", http.StatusNotFound, nil)
+	do("GET", "/transfers/nowhere/99", "", http.StatusBadRequest, nil)
+	do("POST", "/transfers", `{"ttype":"take"}`, http.StatusBadRequest, nil)
+
+	fname := "victim.txt"
+	if err := os.WriteFile(filepath.Join(conf.Sardir, fname), []byte("bye"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	start1 := `{"ttype":"delete","peer":"` + b.Addrs()[0].String() + `","file":"` + fname + `","timeout":5}`
+	var tr control.Transfer
+	do("POST", "/transfers", start1, http.StatusCreated, &tr)
+	if tr.Ttype != "delete" || tr.File != fname {
+		t.Errorf("delete %+v", tr)
+	}
+	var e Error
+	do("POST", "/transfers", start1, http.StatusBadGateway, &e)
+	if e.Errcode != "filenotfound" {
+		t.Errorf("second delete %+v", e)
+	}
+
+	// The refused delete is listed as cancelled with why
+	var trs []control.Transfer
+	do("GET", "/transfers", "", http.StatusOK, &trs)
+	if len(trs) != 1 || trs[0].State != "cancelled" || trs[0].Errcode != "filenotfound" {
+		t.Fatalf("transfers %+v", trs)
+	}
+	path := "/transfers/" + trs[0].Peer + "/" + strconv.FormatUint(uint64(trs[0].Session), 10)
+	do("GET", path, "", http.StatusOK, &tr)
+	do("DELETE", path, "", http.StatusConflict, nil) // Already cancelled
+
+	// Only JSON from a page of our own sent to us by an address or localhost can change anything
+	for _, tc := range []struct {
+		method string
+		path   string
+		ctype  string
+		origin string
+		host   string
+		want   int
+	}{
+		{"POST", "/transfers", "text/plain", "", "", http.StatusUnsupportedMediaType},
+		{"POST", "/transfers", "", "", "", http.StatusUnsupportedMediaType},
+		{"POST", "/transfers", "application/json", "http://evil.example", "", http.StatusForbidden},
+		{"POST", "/transfers", "application/json", "", "evil.example", http.StatusForbidden},
+		{"POST", "/transfers", "application/json; charset=utf-8", "http://" + s.Addr().String(), "", http.StatusBadRequest},
+		{"POST", "/transfers", "application/json", "", "localhost", http.StatusBadRequest},
+		{"DELETE", path, "", "http://evil.example", "", http.StatusForbidden},
+		{"DELETE", path, "", "", "rebound.example:80", http.StatusForbidden},
+		{"DELETE", path, "", "http://" + s.Addr().String(), "", http.StatusConflict},
+		{"GET", path, "", "http://evil.example", "evil.example", http.StatusOK}, // Looking changes nothing
+	} {
+		req, err := http.NewRequest(tc.method, url+tc.path, strings.NewReader(`{"ttype":"take"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.ctype != "" {
+			req.Header.Set("Content-Type", tc.ctype)
+		}
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if tc.host != "" {
+			req.Host = tc.host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s type %q origin %q host %q got %d want %d", tc.method, tc.path, tc.ctype, tc.origin,
+				tc.host, resp.StatusCode, tc.want)
+		}
+	}
+
+	resp, err := http.Get(url + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if page := string(data); !strings.Contains(page, fname) || !strings.Contains(page, "filenotfound") {
+		t.Errorf("status page %s", page)
+	}
+}
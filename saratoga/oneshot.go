@@ -0,0 +1,312 @@
+// Oneshot - Commands that start a node, do one thing and exit, for cron and scripts
+// e.g. saratoga put -peer 10.0.0.2 afile
+// config check is here too as it also does one thing and exits
+// The exit status is the number of the errcode in saratoga.json, 0 for success
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/control"
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+// Exit status for bad arguments, as sysexits EX_USAGE so clear of the errcodes
+const exitUsage = 64
+
+// The one-shot commands, the first argument picks one
+var oneshots = map[string]func(args []string) int{
+	"put":    transfer("put", "put"),
+	"get":    transfer("get", "get"),
+	"delete": transfer("delete", "delete"),
+	"ls":     transfer("ls", "getdir"),
+	"peers":  peers,
+	"config": configcmd,
+}
+
+// Result - What a one-shot command prints with -json
+type Result struct {
+	Command  string            `json:"command"`
+	Peer     string            `json:"peer,omitempty"`
+	File     string            `json:"file,omitempty"`
+	Errcode  string            `json:"errcode"`
+	Error    string            `json:"error,omitempty"`
+	Transfer *control.Transfer `json:"transfer,omitempty"`
+	Peers    []control.Peer    `json:"peers,omitempty"`
+}
+
+// CheckResult - What config check prints with -json
+type CheckResult struct {
+	File     string   `json:"file"`
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Flags every one-shot command takes
+type shotflags struct {
+	config  *string
+	sardir  *string
+	port    *int
+	json    *bool
+	verbose *bool
+}
+
+// The flag set for command, args says what follows the flags in the usage line
+func newflags(command string, args string) (*flag.FlagSet, *shotflags) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	sf := &shotflags{
+		config:  fs.String("config", "saratoga.json", "saratoga config file"),
+		sardir:  fs.String("sardir", "", "saratoga directory, overrides sardir in the config"),
+		port:    fs.Int("port", 0, "saratoga udp port, overrides udpport in the config"),
+		json:    fs.Bool("json", false, "print the result as JSON"),
+		verbose: fs.Bool("v", false, "log progress to stderr"),
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage:saratoga", command, "[-config file] [-sardir dir] [-port port] [-json] [-v]", args)
+		fs.PrintDefaults()
+	}
+	return fs, sf
+}
+
+// Where the node logs to, errors always go to stderr and the rest only with -v
+func (sf *shotflags) logger() sarlog.Logger {
+	out := io.Discard
+	if *sf.verbose {
+		out = os.Stderr
+	}
+	sarwin.SetHeadless(out, os.Stderr, false)
+	return sarwin.Logger(nil)
+}
+
+// Print the result and give the exit status for it
+func (sf *shotflags) finish(r Result) int {
+	if *sf.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	} else {
+		switch {
+		case r.Error != "":
+			fmt.Println(r.Command, r.File, r.Peer+":", r.Errcode, "-", r.Error)
+		case r.Peers != nil:
+			for _, p := range r.Peers {
+				fmt.Println(p.Addr, p.Eid, p.Freespace, p.Canrx, p.Cantx, p.Age, p.State)
+			}
+		default:
+			fmt.Println(r.Command, r.File, r.Peer+":", r.Errcode)
+		}
+	}
+	return exitstatus(r.Errcode)
+}
+
+// Tell stderr about the keys in the config file we do not use
+func warn(fname string, c *sarflags.Cliflags) {
+	for _, w := range c.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", fname+":", w)
+	}
+}
+
+// The errcode for how a request went, timeouts waiting on the peer are rxtimeout
+func errcodeof(err error) string {
+	var se *node.StatusError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &se):
+		return se.Errcode
+	case errors.Is(err, context.DeadlineExceeded):
+		return "rxtimeout"
+	default:
+		return "unspecified"
+	}
+}
+
+// The exit status for errcode, its number in saratoga.json or 1 if it has none
+func exitstatus(errcode string) int {
+	if v := sarflags.Value("errcode", errcode); v >= 0 {
+		return v
+	}
+	return 1
+}
+
+// A one-shot command starting a transfer of ttype and waiting for it to complete
+// It only exits 0 once the whole file has been sent or received
+func transfer(command string, ttype string) func(args []string) int {
+	return func(args []string) int {
+		fs, sf := newflags(command, "-peer addr file")
+		peer := fs.String("peer", "", "peer address, name or alias")
+		timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for the transfer to complete")
+		if err := fs.Parse(args); err != nil {
+			return exitUsage
+		}
+		if *peer == "" || fs.NArg() != 1 {
+			fs.Usage()
+			return exitUsage
+		}
+		r := Result{Command: command, Peer: *peer, File: fs.Arg(0)}
+
+		c, err := configure(*sf.config, overrides{sardir: *sf.sardir, port: *sf.port})
+		if err != nil {
+			r.Errcode, r.Error = "unspecified", err.Error()
+			return sf.finish(r)
+		}
+		warn(*sf.config, c)
+		to, err := sarwin.PeerAddress(*peer)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		r.Peer = to.String()
+
+		n, err := node.New(node.Config{Flags: c, Addr: ":0", Logger: sf.logger()})
+		if err == nil {
+			err = n.Start(context.Background())
+		}
+		if err != nil {
+			r.Errcode, r.Error = "unspecified", err.Error()
+			return sf.finish(r)
+		}
+		defer n.Stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		var t *sarwin.Transfer
+		switch ttype {
+		case "put":
+			t, err = n.Put(ctx, to, r.File)
+		case "get":
+			t, err = n.Get(ctx, to, r.File)
+		case "delete":
+			t, err = n.Delete(ctx, to, r.File)
+		case "getdir":
+			t, err = n.ListDir(ctx, to, r.File)
+		}
+		if err == nil && t.Stats().State != sarwin.Completed { // Never report what did not happen
+			err = node.ErrCancelled
+		}
+		r.Errcode = errcodeof(err)
+		if err != nil {
+			r.Error = err.Error()
+		}
+		if t != nil {
+			tr := control.TransferOf(t)
+			r.Transfer = &tr
+		}
+		return sf.finish(r)
+	}
+}
+
+// Listen for beacons on the interface for a while and print who we heard
+func peers(args []string) int {
+	fs, sf := newflags("peers", "-iface name")
+	ifname := fs.String("iface", "", "interface to listen for multicast beacons on")
+	wait := fs.Duration("wait", 10*time.Second, "how long to listen for")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *ifname == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	r := Result{Command: "peers", Peers: []control.Peer{}}
+
+	c, err := configure(*sf.config, overrides{sardir: *sf.sardir, port: *sf.port})
+	if err != nil {
+		r.Errcode, r.Error = "unspecified", err.Error()
+		return sf.finish(r)
+	}
+	warn(*sf.config, c)
+	iface, err := net.InterfaceByName(*ifname)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	sarflags.MtuSet(iface.MTU)
+	v6, v4, err := multicast(iface, c)
+	if err != nil {
+		r.Errcode, r.Error = "unspecified", err.Error()
+		return sf.finish(r)
+	}
+	n, err := node.New(node.Config{Flags: c, Conns: []*net.UDPConn{v6, v4}, Logger: sf.logger()})
+	if err == nil {
+		err = n.Start(context.Background())
+	}
+	if err != nil {
+		r.Errcode, r.Error = "unspecified", err.Error()
+		return sf.finish(r)
+	}
+	time.Sleep(*wait)
+	n.Stop()
+	r.Errcode, r.Peers = "success", control.Peers(n.Engine())
+	return sf.finish(r)
+}
+
+// config check, check a config file as saratoga reads it at startup, exits 0 if it is valid and 1 if not
+func configcmd(args []string) int {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	sardir := fs.String("sardir", "", "saratoga directory, overrides sardir in the config")
+	port := fs.Int("port", 0, "saratoga udp port, overrides udpport in the config")
+	jsonout := fs.Bool("json", false, "print the result as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage:saratoga config check [-sardir dir] [-port port] [-json] file")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "check" {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	r := CheckResult{File: fs.Arg(0)}
+
+	c := new(sarflags.Cliflags)
+	err := c.Check(r.File, overrides{sardir: *sardir, port: *port}.set())
+	r.Valid, r.Warnings = err == nil, c.Warnings
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range j.Unwrap() {
+			r.Errors = append(r.Errors, e.Error())
+		}
+	} else if err != nil {
+		r.Errors = []string{err.Error()}
+	}
+
+	if *jsonout {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	} else {
+		for _, w := range r.Warnings {
+			fmt.Println("warning:", r.File+":", w)
+		}
+		for _, e := range r.Errors {
+			fmt.Println(e)
+		}
+		if r.Valid {
+			fmt.Println(r.File + ": ok")
+		} else {
+			fmt.Println(r.File+":", len(r.Errors), "errors")
+		}
+	}
+	if !r.Valid {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+func TestOneshot(t *testing.T) {
+	for _, tc := range []struct {
+		err     error
+		errcode string
+		status  int
+	}{
+		{nil, "success", 0},
+		{fmt.Errorf("delete: %w", &node.StatusError{Errcode: "filenotfound"}), "filenotfound", 4},
+		{&node.StatusError{Errcode: "accessdenied"}, "accessdenied", 5},
+		{context.DeadlineExceeded, "rxtimeout", 18},
+		{errors.New("no route"), "unspecified", 1},
+		{node.ErrCancelled, "unspecified", 1},
+	} {
+		errcode := errcodeof(tc.err)
+		if errcode != tc.errcode {
+			t.Errorf("errcodeof(%v) = %s want %s", tc.err, errcode, tc.errcode)
+		}
+		if status := exitstatus(errcode); status != tc.status {
+			t.Errorf("exitstatus(%s) = %d want %d", errcode, status, tc.status)
+		}
+	}
+	if status := exitstatus("nosucherrcode"); status != 1 {
+		t.Errorf("exitstatus(nosucherrcode) = %d want 1", status)
+	}
+
+	// Missing the peer or file is a usage error before anything is started
+	for _, args := range [][]string{{}, {"afile"}, {"-peer", "127.0.0.1"}, {"-nosuchflag"}} {
+		if status := oneshots["put"](args); status != exitUsage {
+			t.Errorf("put %v exit %d want %d", args, status, exitUsage)
+		}
+	}
+	if status := oneshots["peers"](nil); status != exitUsage {
+		t.Errorf("peers with no -iface exit %d want %d", status, exitUsage)
+	}
+
+	// config check exits 0 for a valid config, 1 for one that is not and exitUsage without a file
+	for _, tc := range []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"check", "-sardir", t.TempDir(), "saratoga.json"}, 0},
+		{[]string{"check", "-port", "70000", "saratoga.json"}, 1},
+		{[]string{"check", "nosuchfile.json"}, 1},
+		{[]string{"check"}, exitUsage},
+		{nil, exitUsage},
+	} {
+		if status := oneshots["config"](tc.args); status != tc.status {
+			t.Errorf("config %v exit %d want %d", tc.args, status, tc.status)
+		}
+	}
+}
+
+func TestOneshotTransfer(t *testing.T) {
+	config, err := filepath.Abs("saratoga.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd) // The command moves to its sardir
+
+	// The peer is a node of its own
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+	peer, err := node.New(node.Config{Flags: conf, Addr: "127.0.0.1:0"})
+	if err == nil {
+		err = peer.Start(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Stop()
+	addr := peer.Addrs()[0].String()
+
+	sardir := t.TempDir()
+	want := []byte("all of it or nothing")
+	if err := os.WriteFile(filepath.Join(sardir, "small.txt"), want, 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := func(fname string) []string {
+		return []string{"-config", config, "-sardir", sardir, "-timeout", "10s", "-peer", addr, fname}
+	}
+	// Success is only reported once the file is there
+	before := sarwin.Transfers.Len()
+	if status := oneshots["put"](args("small.txt")); status != 0 {
+		t.Fatalf("put exit %d", status)
+	}
+	if n := sarwin.Transfers.Len(); n != before { // The command runs on a node of its own
+		t.Errorf("put left %d process wide transfers want %d", n, before)
+	}
+	if got, err := os.ReadFile(filepath.Join(conf.Sardir, "small.txt")); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("put left %q %v", got, err)
+	}
+	if status := oneshots["get"](args("nothere.txt")); status != 4 {
+		t.Errorf("get of missing file exit %d want 4", status)
+	}
+}
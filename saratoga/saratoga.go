@@ -3,25 +3,27 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 
-	"github.com/charlesetsmith/saratoga/beacon"
-	"github.com/charlesetsmith/saratoga/data"
-	"github.com/charlesetsmith/saratoga/metadata"
-	"github.com/charlesetsmith/saratoga/request"
+	"github.com/charlesetsmith/saratoga/control"
+	"github.com/charlesetsmith/saratoga/metrics"
+	"github.com/charlesetsmith/saratoga/node"
+	"github.com/charlesetsmith/saratoga/rest"
 	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
 	"github.com/charlesetsmith/saratoga/sarnet"
 	"github.com/charlesetsmith/saratoga/sarwin" // Most of the cmd input and transfer logic is here
-	"github.com/charlesetsmith/saratoga/status"
-	"github.com/charlesetsmith/saratoga/timestamp"
-	"github.com/charlesetsmith/saratoga/trans"
 
 	"github.com/jroimartin/gocui"
 )
@@ -159,316 +161,73 @@ func starxhandler(g *gocui.Gui, s status.Status, conn *net.UDPConn, remoteAddr *
 }
 */
 
-// Listen -- Go routine for recieving IPv4 & IPv6 for incoming frames and shunt them off to the
-// correct frame handlers
-// incoming frames are via rx channel interface,
-// outgoing frames are to the tx channel interface -- These are status frames on a read error
-func listen(g *gocui.Gui, conn *net.UDPConn, rx chan interface{}, tx chan interface{}, quit chan error) {
-
-	// Investigate using conn.PathMTU()
-	maxframesize := sarflags.Mtu() - 60   // Handles IPv4 & IPv6 header
-	buf := make([]byte, maxframesize+100) // Just in case...
-	framelen := 0
-	err := error(nil)
-	// Create some space to hold the remote address in
-	var remoteAddr *net.UDPAddr = new(net.UDPAddr)
-
-	// THE MAIN LOOP HERE
-	for err == nil { // Loop forever grabbing frames
-		// Actually Read the frame into buf
-		framelen, remoteAddr, err = conn.ReadFromUDP(buf)
-		if err != nil {
-			// We failed to read.. That is pretty bad, so lets tell caller we have failed
-			sarwin.ErrPrintln(g, "red_black", "Sarataga listener failed:", err)
-			quit <- err
-			return
-		}
-
-		// sarwin.MsgPrintln(g, "green_black", "Listen read ", framelen, " bytes from ", remoteAddr.String())
-
-		// Very basic frame checks before we get into what it is
-		if framelen < 8 {
-			// Saratoga packet too small
-			sarwin.ErrPrintln(g, "red_black", "Rx Saratoga Frame too short from ",
-				sarnet.UDPinfo(remoteAddr))
-			// drop it and just continue reading
-			continue
-		}
-		if framelen > maxframesize {
-			sarwin.ErrPrintln(g, "red_black", "Rx Saratoga Frame too long ", framelen,
-				" from ", sarnet.UDPinfo(remoteAddr))
-			// drop it and just continue reading
-			continue
-		}
-
-		// OK so we might have a valid frame so copy it to to the frame byte slice
-		framebuf := make([]byte, framelen)
-		copy(framebuf, buf[:framelen])
-
-		// Grab the Saratoga Header which is the first 32 bits
-		header := binary.BigEndian.Uint32(framebuf[:4])
-		// Check the version
-		if sarflags.GetStr(header, "version") != "v1" { // Make sure we are Version 1
-			// If a bad version received then send back a Status errcode to the initiator
-			var st status.Status
-			sinfo := status.Sinfo{Session: 0, Progress: 0, Inrespto: 0, Holes: nil}
-			sarwin.ErrPrintln(g, "red_black", "Not Saratoga Version 1 Frame from ",
-				sarnet.UDPinfo(remoteAddr))
-			if st.New("errcode=badpacket", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create badpacket status")
-				continue // drop it
-			}
-			tx <- st.Val(remoteAddr)
-			continue
-		}
+// Main
+func main() {
 
-		// Process the received frame
-		switch sarflags.GetStr(header, "frametype") {
-		case "beacon":
-			// We have received a beacon on the connection
-			rxb := new(beacon.Beacon)
-			if rxerr := rxb.Decode(framebuf); rxerr != nil {
-				// We just drop bad beacons
-				sarwin.ErrPrintln(g, "red_black", "Bad Beacon:", rxerr, " from ",
-					sarnet.UDPinfo(remoteAddr))
-				// Ignore and throw it away, we dont send status frames for beacons
-				continue
-			}
-			rx <- rxb.Val(remoteAddr)
-			continue
-
-		case "request":
-			// Handle incoming request
-			var req request.Request
-			var rxerr error
-			if rxerr = req.Decode(framebuf); rxerr != nil {
-				// We just drop bad requests
-				sarwin.ErrPrintln(g, "red_black", "Bad Request:", rxerr, " from ",
-					sarnet.UDPinfo(remoteAddr))
-				continue
-			}
-			rx <- req.Val(remoteAddr)
-			continue
-			// sarwin.PacketPrintln(g, "green_black", "Listen Rx ", req.ShortPrint())
-			// sarwin.MsgPrintln(g, "white_black", "Decoded request ", req.Print())
-			// Pass it up to the rx channel for further handling
-			// Move this out of here
-			// errcode := reqrxhandler(g, req, remoteAddr) // process the request
-			// Create the status header with the errcode to send back
-			// stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") +
-			// 	",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=" + errcode
-			// if errcode != "success" {
-			// 	var st status.Status
-			// 	sinfo := status.Sinfo{Session: req.Session, Progress: 0, Inrespto: 0, Holes: nil}
-			// 	if st.New(stheader, &sinfo) != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", "Cannot create badpacket status")
-			// 		continue
-			// 	}
-			// Send it out the connecton
-			// Move the send out of here!!!
-			// 	if err := st.Send(conn, remoteAddr); err != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", err.Error())
-			// 	}
-			// Just send an error status back, dont create a transfer
-			// 	sarwin.ErrPrintln(g, "red_black", "Bad Status:", rxerr, " from ",
-			// 		sarnet.UDPinfo(remoteAddr), " session ", req.Session)
-			// 		tx <- st.Val(remoteAddr)
-			//	continue
-			//  }
-			// Send it out the connection
-			// move the Send and Lookup out of here!!!
-			// Lookup or create the Transfer and Acknowledge the request to the Initiator
-			// var t *sarwin.Transfer
-			// if t = sarwin.Lookup(sarwin.Responder, req.Session, remoteAddr.String()); t != nil {
-			// 	// Write off the status to the Initiator
-			// 	t.WriteStatus(g, stheader)
-
-		case "data":
-			// Handle incoming data
-			var d data.Data
-			var rxerr error
-			sarwin.MsgPrintln(g, "white_black", "Data frame length is:", len(framebuf))
-			if rxerr = d.Decode(framebuf); rxerr != nil {
-				// Bad Packet send back a Status to the sender with current offset and session
-				var st status.Status
-				stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") +
-					",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=badpacket"
-				sinfo := status.Sinfo{Session: d.Session, Progress: d.Offset, Inrespto: 0, Holes: nil}
-				if st.New(stheader, &sinfo) != nil {
-					sarwin.ErrPrintln(g, "red_black", "Cannot create badpacket status")
-					continue
-				}
-				sarwin.ErrPrintln(g, "red_black", "Bad Data:", rxerr, " from ",
-					sarnet.UDPinfo(remoteAddr), " session ", d.Session)
-				// Send a badpacket status back to the sender
-				tx <- st.Val(remoteAddr)
-				continue
-			}
-			rx <- d.Val(remoteAddr)
-			continue
-			// Move this out of here
-			// Ok we have received a good data frame, process it
-			// sarwin.PacketPrintln(g, "green_black", "Listen Rx ", d.ShortPrint())
-			// sarwin.MsgPrintln(g, "white_black", "Decoded data ", d.Print())
-			// Pass it up to the rx channel for further handling
-			// errcode := datrxhandler(g, d, conn, remoteAddr) // process the data
-			// if errcode != "success" {                       // If we have a error send back a status with it
-			// 	stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") + // echo the descriptor
-			// 		",metadatarecvd=no,allholes=yes,reqholes=requested," +
-			// 		"errcode=" + errcode
-			// 	// Send back a status to the client to tell it the error or that we have a success with creating the transfer
-			// 	var st status.Status
-			// 	sinfo := status.Sinfo{Session: d.Session, Progress: d.Offset, Inrespto: 0, Holes: nil}
-			// 	if st.New(stheader, &sinfo) != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", "Cannot asemble status")
-			// 	}
-			// 	if se := st.Send(conn, remoteAddr); se != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", se.Error())
-			// 	}
-
-		case "metadata":
-			// Handle incoming metadata
-			var m metadata.MetaData
-			var rxerr error
-			if rxerr = m.Decode(framebuf); rxerr != nil {
-				session := binary.BigEndian.Uint32(framebuf[4:8])
-				var st status.Status
-				// Bad Packet send back a Status to the client
-				stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") +
-					",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=badpacket"
-				sinfo := status.Sinfo{Session: session, Progress: 0, Inrespto: 0, Holes: nil}
-				if st.New(stheader, &sinfo) != nil {
-					sarwin.ErrPrintln(g, "red_black", "Cannot assemble status")
-					continue
-				}
-				sarwin.ErrPrintln(g, "red_black", "Bad MetaData:", rxerr, " from ",
-					sarnet.UDPinfo(remoteAddr), " session ", session)
-				// Pass it up to the tx channel for further handling
-				tx <- st.Val(remoteAddr)
-				continue
-			}
-			rx <- m.Val(remoteAddr)
-			continue
-
-			// Move this out of here
-			// sarwin.PacketPrintln(g, "green_black", "Listen Rx ", m.ShortPrint())
-			// sarwin.MsgPrintln(g, "white_black", "Decoded metadata ", m.Print())
-			// Pass it up to the rx channel for further handling
-			// errcode := metrxhandler(g, m, remoteAddr) // process the metadata
-			// if errcode != "success" {                 // If we have a error send back a status with it
-			// 	var st status.Status
-			// 	stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") +
-			// 		",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=" + errcode
-			// 	sinfo := status.Sinfo{Session: m.Session, Progress: 0, Inrespto: 0, Holes: nil}
-			// 	if st.New(stheader, &sinfo) != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", "Cannot assemble status")
-			// 	}
-			// 	if se := st.Send(conn, remoteAddr); se != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", se.Error())
-			// 	}
-			// 	sarwin.ErrPrintln(g, "red_black", "Bad Metadata:", rxerr, " from ",
-			// 		sarnet.UDPinfo(remoteAddr), " session ", m.Session)
-			// 	tx <- st.Val(remoteAddr)
-			// }
-
-		case "status":
-			// Handle incoming status
-			var s status.Status
-			if rxerr := s.Decode(framebuf); rxerr != nil {
-				session := binary.BigEndian.Uint32(framebuf[4:8])
-				var st status.Status
-				// Bad Packet send back a Status to the sender
-				stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") +
-					",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=badpacket"
-				sinfo := status.Sinfo{Session: session, Progress: 0, Inrespto: 0, Holes: nil}
-				if st.New(stheader, &sinfo) != nil {
-					sarwin.ErrPrintln(g, "red_black", "Cannot assemble status")
-					continue
-				}
-				sarwin.ErrPrintln(g, "red_black", "Bad Status:", rxerr, " from ",
-					sarnet.UDPinfo(remoteAddr), " session ", session)
-				// Pass it up to the tx channel for further handling
-				tx <- st.Val(remoteAddr)
-				continue
-			} // Handle the status
-			sarwin.PacketPrintln(g, "green_black", "Listen Rx ", s.ShortPrint())
-			sarwin.MsgPrintln(g, "white_black", "Decoded status ", s.Print())
-			// Pass it up to the rx channel for further handling
-			rx <- s.Val(remoteAddr)
-			continue
-
-			// Move rest of this off out of here
-			// errcode := starxhandler(g, s, conn, remoteAddr) // process the status
-			// if errcode != "success" {                       // If we have a error send back a status with it
-			// 	var st status.Status
-			// 	stheader := "descriptor=" + sarflags.GetStr(header, "descriptor") + // echo the descriptor
-			// 		",metadatarecvd=no,allholes=yes,reqholes=requested,errcode=" + errcode
-			// 	sinfo := status.Sinfo{Session: s.Session, Progress: s.Progress, Inrespto: s.Inrespto, Holes: nil}
-			// 	if st.New(stheader, &sinfo) != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", "Cannot assemble status")
-			// 	}
-			// 	if se := st.Send(conn, remoteAddr); se != nil {
-			// 		sarwin.ErrPrintln(g, "red_black", se.Error())
-			// 	}
-			// 	sarwin.ErrPrintln(g, "red_black", "Bad Status:", errcode, " from ",
-			// 		sarnet.UDPinfo(remoteAddr), " session ", s.Session)
-			// 	tx <- st.Val(remoteAddr)
-			// }
-
-		default:
-			// Bad Packet drop it
-			sarwin.ErrPrintln(g, "red_black", "Listen Invalid Saratoga Frame Recieved from ",
-				sarnet.UDPinfo(remoteAddr))
+	// put, get, delete, ls, peers and config check do just that and exit
+	if len(os.Args) > 1 {
+		if cmd, ok := oneshots[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
 		}
 	}
-}
-
-// Peer - beacon peer
-type Peer struct {
-	Addr      string              // The Peer IP Address. is format net.UDPAddr.IP.String()
-	Freespace uint64              // 0 if freespace not advertised
-	Eid       string              // Exactly who sent this and from what PID
-	Maxdesc   string              // The maximum descriptor size of the peer
-	Created   timestamp.Timestamp // When was this Peer created
-	Updated   timestamp.Timestamp // When was this Peer last updated
-}
 
-var Cmdptr *sarflags.Cliflags
-
-// Main
-func main() {
-
-	if len(os.Args) != 3 {
-		fmt.Println("usage:saratoga <config> <iface>")
-		fmt.Println("e.g.: go run saratoga.go saratoga.json en0 (Interface says where to listen for multicast joins")
+	var ifaces ifacelist
+	configfile := flag.String("config", "saratoga.json", "saratoga config file")
+	flag.Var(&ifaces, "iface", "interface to join the multicast groups on, repeat it for more than one")
+	nomulticast := flag.Bool("no-multicast", false, "listen for unicast frames on the saratoga port rather than joining the multicast groups")
+	sardir := flag.String("sardir", "", "saratoga directory, overrides sardir in the config")
+	port := flag.Int("port", 0, "saratoga udp port, overrides udpport in the config")
+	headless := flag.Bool("headless", false, "run without the gocui interface, output goes to stdout & stderr")
+	logfile := flag.String("log-file", "", "headless output goes to this file rather than stdout & stderr")
+	flag.StringVar(logfile, "log", "", "same as -log-file")
+	packets := flag.Bool("packets", false, "headless output includes packet traces")
+	ctlsock := flag.String("control", "", "unix domain socket to take JSON commands on")
+	httpaddr := flag.String("http", "", "address or port to serve the HTTP API and status page on, localhost if no host")
+	metricsaddr := flag.String("metrics", "", "address or port to serve prometheus metrics on, localhost if no host")
+	scriptfile := flag.String("script", "", "run the commands in this file once started, as the source command does")
+	flag.Usage = func() {
+		fmt.Println("usage:saratoga [-config file] [-iface name ...|-no-multicast] [-sardir dir] [-port port]")
+		fmt.Println("               [-headless [-log-file file] [-packets]] [-control socket] [-http addr] [-metrics addr] [-script file]")
+		fmt.Println("e.g.: saratoga -config saratoga.json -iface en0 (Interface says where to listen for multicast joins)")
+		fmt.Println("   or:saratoga <config> <iface> as before")
+		fmt.Println("   or:saratoga put|get|delete|ls [-config file] [-json] [-v] [-timeout d] -peer addr file")
+		fmt.Println("   or:saratoga peers [-config file] [-json] [-v] [-wait d] -iface name")
+		fmt.Println("   or:saratoga config check [-json] file (Check the config file and exit 0 if it is valid)")
+		fmt.Println("The one-shot commands exit with the number of the saratoga errcode, 0 is success")
+		fmt.Println("Any config key can be set by a SARATOGA_<KEY> environment variable, nested keys joined with _")
+		fmt.Println("e.g. SARATOGA_UDPPORT=7543 SARATOGA_TIMEOUT_SHUTDOWN=30")
+		fmt.Println("Flags come first, then the environment, then the config file, then built in defaults")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch flag.NArg() {
+	case 0:
+	case 2: // The old <config> <iface>
+		*configfile = flag.Arg(0)
+		ifaces = append(ifaces, flag.Arg(1))
+	default:
+		flag.Usage()
 		return
 	}
-
-	argnumb := 1
-
-	// The Command line interface commands, help & usage to be read from saratoga.json
-	Cmdptr := new(sarflags.Cliflags)
-	sarflags.Cliflag = Cmdptr
-
-	var err error
-	// Read in JSON config file and parse it into the Config structure.
-	if err = Cmdptr.ReadConfig(os.Args[argnumb]); err != nil {
-		fmt.Println("Cannot open saratoga config file we have a Readconf error ", os.Args[argnumb], " ", err)
+	if len(ifaces) == 0 && !*nomulticast {
+		fmt.Println("saratoga: give an -iface to join the multicast groups on or -no-multicast")
+		flag.Usage()
 		return
 	}
+	// We move to the saratoga directory so find the script from where we started
+	if *scriptfile != "" {
+		abs, err := filepath.Abs(*scriptfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*scriptfile = abs
+	}
 
-	// Grab my process ID
-	// Pid := os.Getpid()
-
-	// Set global variable pointing to sarflags.Cliflags structure
-	// We have to have a global pointer as we cannot pass c directly into gocui
-
-	sarwin.Cinfo.Prompt = Cmdptr.Prompt
-	sarwin.Cinfo.Ppad = Cmdptr.Ppad
-
-	// Move to saratoga working directory
-	if err = os.Chdir(Cmdptr.Sardir); err != nil {
-		log.Fatal(fmt.Errorf("no such directory SARDIR=%s", Cmdptr.Sardir))
+	// Read the config and move to the saratoga directory
+	Cmdptr, err := configure(*configfile, overrides{sardir: *sardir, port: *port})
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
 
 	var fs syscall.Statfs_t
@@ -476,90 +235,97 @@ func main() {
 		log.Fatal(errors.New("cannot stat saratoga working directory"))
 	}
 
-	// What Interface are we receiving Multicasts on
-	var iface *net.Interface
-
-	argnumb++
-	iface, err = net.InterfaceByName(os.Args[argnumb])
-	if err != nil {
-		fmt.Println("Saratoga Unable to lookup interfacebyname:", os.Args[argnumb-1])
-		log.Fatal(err)
-	}
-	// Set the Mtu to Interface we are using
-	sarflags.MtuSet(iface.MTU)
-
-	// Set up the gocui interface and start the mainloop
-	g, gerr := gocui.NewGui(gocui.OutputNormal)
-	if gerr != nil {
-		fmt.Printf("Cannot run gocui user interface")
-		log.Fatal(err)
-	}
-	defer g.Close()
-
-	// Get Host Interfaces & Address's
-	ifis, ierr := net.Interfaces()
-	if ierr != nil {
-		fmt.Println(ierr.Error())
-		log.Fatal(ierr)
-	}
-	g.SetManagerFunc(sarwin.Layout)
-	if err := sarwin.Keybindings(g); err != nil {
-		log.Panicln(err)
+	// What Interfaces are we receiving Multicasts on
+	var ifis []*net.Interface
+	for _, name := range ifaces {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			fmt.Println("Saratoga Unable to lookup interfacebyname:", name)
+			log.Fatal(err)
+		}
+		ifis = append(ifis, iface)
+		// Set the Mtu to the smallest of the Interfaces we are using
+		if sarflags.Mtu() == 0 || iface.MTU < sarflags.Mtu() {
+			sarflags.MtuSet(iface.MTU)
+		}
 	}
 
-	// Show Host Interfaces & Address's
-	for _, ifi := range ifis {
-		if ifi.Name == os.Args[argnumb] {
-			sarwin.MsgPrintln(g, "green_black", ifi.Name, " MTU ", ifi.MTU, " ", ifi.Flags.String(), ":")
-			adrs, _ := ifi.Addrs()
-			for _, adr := range adrs {
-				if strings.Contains(adr.Network(), "ip") {
-					sarwin.MsgPrintln(g, "green_black", "\t Unicast ", adr.String(), " ", adr.Network())
-				}
-			}
-			//
-			madrs, _ := ifi.MulticastAddrs()
-			for _, madr := range madrs {
-				if strings.Contains(madr.Network(), "ip") {
-					sarwin.MsgPrintln(g, "green_black", "Multicast ", madr.String(),
-						" Net:", madr.Network())
-				}
+	// Set up the gocui interface and start the mainloop, headless there is no gui and g is nil
+	var g *gocui.Gui
+	var lf *os.File
+	if *headless {
+		out, errout := io.Writer(os.Stdout), io.Writer(os.Stderr)
+		if *logfile != "" {
+			if lf, err = os.OpenFile(*logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+				log.Fatal(err)
 			}
-			//
+			out, errout = lf, lf
+		}
+		sarwin.SetHeadless(out, errout, *packets)
+	} else {
+		var gerr error
+		if g, gerr = gocui.NewGui(gocui.OutputNormal); gerr != nil {
+			fmt.Printf("Cannot run gocui user interface")
+			log.Fatal(gerr)
 		}
 	}
-	// Open up V6 sockets for listening on the Saratoga Port
-	v6mcastaddr := net.UDPAddr{
-		Port: Cmdptr.Port,
-		IP:   net.ParseIP(Cmdptr.V6Multicast),
-	}
-	// Set up for Listen to Multicast v6
-	v6mcastcon, err := net.ListenMulticastUDP("udp6", iface, &v6mcastaddr)
-	if err != nil {
-		log.Println("Saratoga Unable to Listen on IPv6 Multicast ", v6mcastaddr.IP, " ", v6mcastaddr.Port)
-		log.Fatal(err)
+	// Deferred closes never run as we leave by os.Exit, so fatal and the exit below close them
+	closeall := func() {
+		if g != nil {
+			g.Close()
+		}
+		if lf != nil {
+			lf.Close()
+		}
 	}
-	if err := sarnet.SetMulticastLoop(v6mcastcon); err != nil {
-		log.Fatal(err)
+	fatal := func(v ...any) {
+		closeall()
+		log.Fatal(v...)
 	}
 
-	// Open up V4 sockets for listening on the Saratoga Port
-	v4mcastaddr := net.UDPAddr{
-		Port: Cmdptr.Port,
-		IP:   net.ParseIP(Cmdptr.V4Multicast),
+	lg := sarwin.Logger(g) // Where the transfer and beacon engines log to
+	if g != nil {
+		g.SetManagerFunc(sarwin.Layout)
+		if err := sarwin.Keybindings(g); err != nil {
+			fatal(err)
+		}
 	}
 
-	// Set up for Listen to Multicast v4
-	v4mcastcon, err := net.ListenMulticastUDP("udp4", iface, &v4mcastaddr)
-	if err != nil {
-		log.Println("Saratoga Unable to Listen on IPv4 Multicast ", v4mcastaddr.IP, " ", v4mcastaddr.Port)
-		log.Fatal(err)
+	// Show Host Interfaces & Address's and join the saratoga multicast groups on them
+	var conns []*net.UDPConn
+	for _, ifi := range ifis {
+		sarwin.MsgPrintln(g, "green_black", ifi.Name, " MTU ", ifi.MTU, " ", ifi.Flags.String(), ":")
+		adrs, _ := ifi.Addrs()
+		for _, adr := range adrs {
+			if strings.Contains(adr.Network(), "ip") {
+				sarwin.MsgPrintln(g, "green_black", "\t Unicast ", adr.String(), " ", adr.Network())
+			}
+		}
+		//
+		madrs, _ := ifi.MulticastAddrs()
+		for _, madr := range madrs {
+			if strings.Contains(madr.Network(), "ip") {
+				sarwin.MsgPrintln(g, "green_black", "Multicast ", madr.String(),
+					" Net:", madr.Network())
+			}
+		}
+		//
+		v6mcastcon, v4mcastcon, err := multicast(ifi, Cmdptr)
+		if err != nil {
+			fatal(err)
+		}
+		conns = append(conns, v6mcastcon, v4mcastcon)
 	}
-	if err := sarnet.SetMulticastLoop(v4mcastcon); err != nil {
-		log.Fatal(err)
+	// Without multicast we listen on the saratoga port for frames sent to us
+	var unicast string
+	if *nomulticast {
+		unicast = fmt.Sprintf(":%d", Cmdptr.Port)
 	}
 
 	// v4unicastcon, err := net.ListenUDP("udp4", iface, &v4addr)
+	for _, w := range Cmdptr.Warnings {
+		sarwin.ErrPrintln(g, "yellow_black", *configfile, ": ", w)
+	}
 	sarwin.MsgPrintf(g, "green_black", "Saratoga Directory is %s\n", Cmdptr.Sardir)
 	sarwin.MsgPrintf(g, "green_black", "Available space is %d MB\n",
 		(uint64(fs.Bsize)*fs.Bavail)/1024/1024)
@@ -575,159 +341,120 @@ func main() {
 	sarwin.MsgPrintln(g, "green_black", "MaxUint64=", sarflags.MaxUint64)
 
 	sarwin.MsgPrintln(g, "green_black", "Maximum Descriptor is:", sarflags.MaxDescriptor)
+	sarwin.MsgPrintln(g, "green_black", "Our EID is:", Cmdptr.Eid)
 
-	sarwin.MsgPrintln(g, "white_black", "^P - Toggle Packet View")
-	sarwin.MsgPrintln(g, "white_black", "^Space - Rotate/Change View")
-
-	// Listen for incoming v6 frames
-	v6listenquit := make(chan error)    // When will we return from listening for v6 frames
-	rxv6frame := make(chan interface{}) // We receive v6 decoded frames on this channel
-	txv6frame := make(chan interface{}) // We transmit v6 encoded frames on this channel
-	go listen(g, v6mcastcon, rxv6frame, txv6frame, v6listenquit)
-	sarwin.MsgPrintln(g, "green_black", "Saratoga IPv6 Multicast Listener started on ",
-		sarnet.UDPinfo(&v6mcastaddr))
-
-	// Listen for incoming v4 frames
-	v4listenquit := make(chan error)    // When will we return from listening for v4 frames
-	rxv4frame := make(chan interface{}) // Wee receive decoded v4 frames on this channel
-	txv4frame := make(chan interface{}) // We transmit encoded v4 frames on this channel
-	go listen(g, v4mcastcon, rxv4frame, txv4frame, v4listenquit)
-	sarwin.MsgPrintln(g, "green_black", "Saratoga IPv4 Multicast Listener started on ",
-		sarnet.UDPinfo(&v4mcastaddr))
+	if g != nil {
+		sarwin.MsgPrintln(g, "white_black", "^P - Toggle Packet View")
+		sarwin.MsgPrintln(g, "white_black", "^Space - Rotate/Change View")
+	}
+
+	// Listen for incoming v4 & v6 frames, age out peers and report them coming and going
+	n, err := node.New(node.Config{Flags: Cmdptr, Addr: unicast, Conns: conns, Logger: lg, Engine: sarwin.Global()})
+	if err != nil {
+		fatal(err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		fatal(err)
+	}
+	// Events go to the node so its subscribers see them too
+	sarwin.Transfers.OnRemove(func(t *sarwin.Transfer) {
+		sarlog.Emit(n.Logger(), sarlog.TransferRemoved, t.Fields()...)
+	})
+	for _, addr := range n.Addrs() {
+		sarwin.MsgPrintln(g, "green_black", "Saratoga Listener started on ", sarnet.UDPinfo(addr))
+	}
+
+	// Let scripts drive us through the control socket
+	var ctl *control.Server
+	if *ctlsock != "" {
+		ctl = control.New(*ctlsock, n, lg)
+		if err := ctl.Start(); err != nil {
+			fatal(err)
+		}
+	}
+
+	// Let dashboards see what is going on
+	var web *rest.Server
+	if *httpaddr != "" {
+		web = rest.New(*httpaddr, n, lg)
+		if err := web.Start(); err != nil {
+			fatal(err)
+		}
+	}
+
+	// Counters for prometheus to scrape from /metrics
+	var mets *metrics.Server
+	if *metricsaddr != "" {
+		mets = metrics.New(*metricsaddr)
+		if err := mets.Start(); err != nil {
+			fatal(err)
+		}
+		sarwin.MsgPrintln(g, "green_black", "Metrics on http://", mets.Addr(), "/metrics")
+	}
 
 	// The Base calling functions for Saratoga live in cli.go so look there first!
-	errflag := make(chan error, 1)
-	go gocuimainloop(g, errflag)
+	var errflag chan error // Never ready headless
+	if g != nil {
+		errflag = make(chan error, 1)
+		go gocuimainloop(g, errflag)
+	}
 
-	// This is the major handler of incoming frames. First decode what type they are
-	// and where they came from then handle them
-	// sarwin.MsgPrintln(g, "red_black", "OK We got to the main select loop")
+	// Run the startup script alongside the gui, its commands one after another
+	if *scriptfile != "" {
+		go func() {
+			if err := sarwin.Source(g, *scriptfile); err != nil {
+				sarwin.ErrPrintln(g, "red_black", "Script stopped: ", err)
+				return
+			}
+			sarwin.MsgPrintln(g, "green_black", "Script ", *scriptfile, " done")
+		}()
+	}
+
+	// SIGINT & SIGTERM exit like ^C does, headless this is how we are stopped
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range sigs {
+			sarwin.RequestExit(sarwin.ExitOK, sarwin.ShuttingDown())
+		}
+	}()
+
+	// exit/quit and ^C ask us to stop here, frames keep being handled while transfers drain
+	exitcode := make(chan int, 1)
+
+	// The node handles incoming frames, we wait here for it or the gui to stop or to be told to exit
 	for {
 		select {
-		case rxv4 := <-rxv4frame:
-			switch rxv4.(type) {
-			case beacon.Packet:
-				// sarwin.MsgPrintln(g, "white_black", "Received v4 Saratoga BEACON Frame")
-				pkt := rxv4.(beacon.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-				// Add a new or update an existing peer information from the beacon
-				if beacon.NewPeer(&pkt.Info, &pkt.Addr) {
-					sarwin.MsgPrintln(g, "yellow_black", "Added New Peer ", pkt.Addr.String())
-				}
-			case data.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v4 Saratoga DATA Frame")
-				pkt := rxv4.(data.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-			case metadata.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v4 Saratoga METADATA Frame")
-				pkt := rxv4.(metadata.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-			case request.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v4 Saratoga REQUEST Frame")
-				pkt := rxv4.(request.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-				// We have received a request to send or receive a file or dir
-				if trans.AddRxTran(g, &pkt.Info, &pkt.Addr, txv4frame) {
-					sarwin.MsgPrintln(g, "yellow_black", "New transfer request from ", pkt.Addr.String())
-				}
-			case status.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v4 Saratoga STATUS Frame")
-				pkt := rxv4.(status.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-			default:
-				sarwin.ErrPrintln(g, "white_black", "Received v4 Saratoga INVALID Frame")
+		case <-n.Done():
+			fatal("Saratoga listener has quit with error:", n.Err())
+		case err := <-errflag:
+			if err != nil {
+				fatal("Mainloop has quit with error:", err.Error())
 			}
-		case rxv6 := <-rxv6frame:
-			switch rxv6.(type) {
-			case beacon.Packet:
-				// sarwin.MsgPrintln(g, "white_black", "Received v6 Saratoga BEACON Frame")
-				pkt := rxv6.(beacon.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-				if beacon.NewPeer(&pkt.Info, &pkt.Addr) {
-					sarwin.MsgPrintln(g, "yellow_black", "Added New Peer ", pkt.Addr.String())
-				}
-			case data.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v6 Saratoga DATA Frame")
-				pkt := rxv6.(data.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-			case metadata.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v6 Saratoga METADATA Frame")
-				pkt := rxv6.(metadata.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-			case request.Packet:
-				// We have received a request to send or receive a file or dir
-				sarwin.MsgPrintln(g, "white_black", "Received v6 Saratoga REQUEST Frame")
-				pkt := rxv6.(request.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-				if trans.AddRxTran(g, &pkt.Info, &pkt.Addr, txv6frame) {
-					sarwin.MsgPrintln(g, "yellow_black", "New transfer request from ", pkt.Addr.String())
-				}
-			case status.Packet:
-				sarwin.MsgPrintln(g, "white_black", "Received v6 Saratoga STATUS Frame")
-				pkt := rxv6.(status.Packet)
-				sarwin.PacketPrintln(g, "white_black", "Rx", pkt.Info.ShortPrint())
-			default:
-				sarwin.ErrPrintln(g, "white_black", "Received v6 Saratoga INVALID Frame")
+			fatal("Saratoga has quit")
+		case code := <-sarwin.Exit:
+			go func() {
+				exitcode <- sarwin.Shutdown(lg, sarwin.ShutdownTimeout(), code)
+			}()
+		case code := <-exitcode:
+			if ctl != nil {
+				ctl.Close()
 			}
-		case txv4 := <-txv4frame:
-			switch txv4.(type) {
-			case beacon.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v4 Saratoga BEACON Frame")
-				pkt := txv4.(beacon.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case data.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v4 Saratoga DATA Frame")
-				pkt := txv4.(data.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case metadata.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v4 Saratoga METADATA Frame")
-				pkt := txv4.(metadata.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case request.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v4 Saratoga REQUEST Frame")
-				pkt := txv4.(request.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case status.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v4 Saratoga STATUS Frame")
-				pkt := txv4.(status.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			default:
-				sarwin.ErrPrintln(g, "red_black", "Need to send v4 Saratoga INVALID Frame")
+			if web != nil {
+				web.Close()
 			}
-		case txv6 := <-txv6frame:
-			switch txv6.(type) {
-			case beacon.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v6 Saratoga BEACON Frame")
-				pkt := txv6.(beacon.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case data.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v6 Saratoga DATA Frame")
-				pkt := txv6.(data.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case metadata.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v6 Saratoga METADATA Frame")
-				pkt := txv6.(metadata.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case request.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v6 Saratoga REQUEST Frame")
-				pkt := txv6.(request.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			case status.Packet:
-				sarwin.MsgPrintln(g, "blue_black", "Need to send v6 Saratoga STATUS Frame")
-				pkt := txv6.(status.Packet)
-				sarwin.MsgPrintln(g, "blue_black", pkt.Info.ShortPrint())
-			default:
-				sarwin.ErrPrintln(g, "blue_black", "Need to send v6 Saratoga INVALID Frame")
+			if mets != nil {
+				mets.Close()
 			}
-		case v4err := <-v4listenquit:
-			log.Fatal("Saratoga v4 listener has quit with error:", v4err)
-		case v6err := <-v6listenquit:
-			log.Fatal("Saratoga v6 listener has quit with error:", v6err)
-		case err := <-errflag:
-			if err != nil {
-				log.Fatal("Mainloop has quit with error:", err.Error())
+			n.Stop()
+			if g != nil {
+				closeall()
+				fmt.Println("Saratoga exit", code, "Bye!")
+			} else {
+				sarwin.MsgPrintln(g, "green_black", "Saratoga exit ", code, " Bye!")
+				closeall()
 			}
-			log.Fatal("Saratoga has quit")
+			os.Exit(code)
 		}
 	}
 }
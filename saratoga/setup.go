@@ -0,0 +1,114 @@
+// Setup - Reading the config and opening the sockets, shared by the interface and the one-shot commands
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarnet"
+	"github.com/charlesetsmith/saratoga/sarwin"
+)
+
+// Settings from the command line, they come before the environment and the config file
+type overrides struct {
+	sardir string // Saratoga directory
+	port   int    // Saratoga udp port
+}
+
+// The config keys the overrides set, for sarflags Load
+func (o overrides) set() map[string]string {
+	set := make(map[string]string)
+	if o.sardir != "" {
+		set["sardir"] = o.sardir
+	}
+	if o.port != 0 {
+		set["udpport"] = strconv.Itoa(o.port)
+	}
+	return set
+}
+
+// ifacelist - The interfaces given by repeated -iface flags
+type ifacelist []string
+
+func (l *ifacelist) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *ifacelist) Set(name string) error {
+	*l = append(*l, name)
+	return nil
+}
+
+// Read the config file, set up access control, quotas and keys from it and move to the saratoga directory
+func configure(fname string, o overrides) (*sarflags.Cliflags, error) {
+	// The Command line interface commands, help & usage to be read from saratoga.json
+	c := new(sarflags.Cliflags)
+	sarflags.Cliflag = c
+
+	var err error
+	// Read in JSON config file, check it and parse it into the Config structure.
+	if err = c.Check(fname, o.set()); err != nil {
+		return nil, err
+	}
+
+	// The engine finds files from sardir wherever we are so it must not be relative
+	if abs, err := filepath.Abs(c.Sardir); err == nil {
+		c.Sardir = abs
+	}
+
+	// The access control list, pre-shared keys and space checks for incoming requests
+	e, err := sarwin.NewEngine(c)
+	if err != nil {
+		return nil, fmt.Errorf("saratoga config file %s: %w", fname, err)
+	}
+	sarwin.SetGlobal(e)
+
+	sarwin.Cinfo.Prompt = c.Prompt
+	sarwin.Cinfo.Ppad = c.Ppad
+
+	// Move to saratoga working directory
+	if err = os.Chdir(c.Sardir); err != nil {
+		return nil, fmt.Errorf("no such directory SARDIR=%s", c.Sardir)
+	}
+	return c, nil
+}
+
+// Join the v6 and v4 saratoga multicast groups on iface
+func multicast(iface *net.Interface, c *sarflags.Cliflags) (v6 *net.UDPConn, v4 *net.UDPConn, err error) {
+	// Open up V6 sockets for listening on the Saratoga Port
+	v6mcastaddr := net.UDPAddr{
+		Port: c.Port,
+		IP:   net.ParseIP(c.V6Multicast),
+	}
+	// Set up for Listen to Multicast v6
+	if v6, err = net.ListenMulticastUDP("udp6", iface, &v6mcastaddr); err != nil {
+		return nil, nil, fmt.Errorf("unable to listen on IPv6 multicast %s: %w", sarnet.UDPinfo(&v6mcastaddr), err)
+	}
+	if err = sarnet.SetMulticastLoop(v6); err != nil {
+		v6.Close()
+		return nil, nil, err
+	}
+
+	// Open up V4 sockets for listening on the Saratoga Port
+	v4mcastaddr := net.UDPAddr{
+		Port: c.Port,
+		IP:   net.ParseIP(c.V4Multicast),
+	}
+	// Set up for Listen to Multicast v4
+	if v4, err = net.ListenMulticastUDP("udp4", iface, &v4mcastaddr); err != nil {
+		v6.Close()
+		return nil, nil, fmt.Errorf("unable to listen on IPv4 multicast %s: %w", sarnet.UDPinfo(&v4mcastaddr), err)
+	}
+	if err = sarnet.SetMulticastLoop(v4); err != nil {
+		v6.Close()
+		v4.Close()
+		return nil, nil, err
+	}
+	return v6, v4, nil
+}
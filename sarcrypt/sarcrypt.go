@@ -0,0 +1,138 @@
+// Optional AES-GCM encryption of data payloads and metadata path names
+
+package sarcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+// Overhead - Bytes the GCM tag adds to every sealed payload
+const Overhead = 16
+
+// Errors from negotiation and opening
+var (
+	ErrNoKey        = errors.New("sarcrypt: no key for peer")
+	ErrNoSalt       = errors.New("sarcrypt: no salt from the request")
+	ErrNotSupported = errors.New("sarcrypt: peer did not agree to encrypt")
+	ErrUnexpected   = errors.New("sarcrypt: encrypted frame for unencrypted transfer")
+)
+
+// Session - The ciphers for a single transfer
+// Keys are derived from the peers pre-shared key, the session and a random salt the initiator
+// sends in its request so no two transfers share a key even when a session number comes round again
+// The data nonce is session | offset so a retransmitted frame always encrypts to the same bytes.
+// This only holds while retransmissions keep the same frame boundaries
+type Session struct {
+	Session uint32
+	data    cipher.AEAD // Data payloads
+	meta    cipher.AEAD // Metadata path names
+}
+
+// Derive a key for a purpose from the peer key, session and salt
+func derive(key []byte, label string, session uint32, salt []byte) []byte {
+	var s [4]byte
+
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(label))
+	binary.BigEndian.PutUint32(s[:], session)
+	m.Write(s[:])
+	m.Write(salt)
+	return m.Sum(nil) // 32 bytes so AES-256
+}
+
+func newgcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// New - Set up the ciphers for the session from the peers pre-shared key and the requests salt
+func New(key []byte, session uint32, salt []byte) (*Session, error) {
+	var err error
+
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	if len(salt) == 0 {
+		return nil, ErrNoSalt
+	}
+	s := &Session{Session: session}
+	if s.data, err = newgcm(derive(key, "saratoga data", session, salt)); err != nil {
+		return nil, err
+	}
+	if s.meta, err = newgcm(derive(key, "saratoga metadata", session, salt)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// 12 byte nonce session | offset
+func (s *Session) nonce(offset uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint32(n[:4], s.Session)
+	binary.BigEndian.PutUint64(n[4:], offset)
+	return n
+}
+
+// Seal - Encrypt the data payload at offset
+func (s *Session) Seal(offset uint64, payload []byte) []byte {
+	return s.data.Seal(nil, s.nonce(offset), payload, nil)
+}
+
+// Open - Decrypt and authenticate the data payload at offset
+func (s *Session) Open(offset uint64, payload []byte) ([]byte, error) {
+	return s.data.Open(nil, s.nonce(offset), payload, nil)
+}
+
+// SealPath - Encrypt a metadata path name
+// The result is base64 so it can still be carried as a null terminated string
+// The header of the metadata frame is authenticated with it so its progress cannot be changed,
+// and picks the nonce so the same header never seals different text
+func (s *Session) SealPath(header uint32, path string) string {
+	return base64.RawURLEncoding.EncodeToString(s.meta.Seal(nil, s.nonce(uint64(header)), []byte(path), aad(header)))
+}
+
+// OpenPath - Decrypt a metadata path name and authenticate it and the header it came with
+func (s *Session) OpenPath(header uint32, path string) (string, error) {
+	ct, err := base64.RawURLEncoding.DecodeString(path)
+	if err != nil {
+		return "", err
+	}
+	pt, err := s.meta.Open(nil, s.nonce(uint64(header)), ct, aad(header))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// The header as the additional data sealed with a path
+func aad(header uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, header)
+}
+
+// Encrypted - Is the encrypt flag set in a request, metadata, data or status header
+func Encrypted(header uint32) bool {
+	return sarflags.GetStr(header, "encrypt") == "yes"
+}
+
+// Check - Does the header of a frame received for a transfer agree with what was negotiated
+// A peer without the capability never sets the flag so its status frames fail here
+func Check(s *Session, header uint32) error {
+	switch {
+	case s != nil && !Encrypted(header):
+		return ErrNotSupported
+	case s == nil && Encrypted(header):
+		return ErrUnexpected
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package sarcrypt
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/data"
+	"github.com/charlesetsmith/saratoga/dirent"
+	"github.com/charlesetsmith/saratoga/metadata"
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+// Two nodes on loopback, one sends an encrypted file the other writes it to disk
+func TestSarcrypt(t *testing.T) {
+	plaintext := []byte(strings.Repeat("saratoga plaintext ", 50))
+	srcdir := t.TempDir()
+	dstdir := t.TempDir()
+	fname := filepath.Join(srcdir, "secret.txt")
+	if err := os.WriteFile(fname, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	const session = 4321
+	salt := []byte("0123456789abcdef")
+	tx, err := New(key, session, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rx, err := New(key, session, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, _ := net.ResolveUDPAddr("udp4", "127.0.0.1:0")
+	rxconn, err := net.ListenUDP("udp4", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rxconn.Close()
+	txconn, err := net.ListenUDP("udp4", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txconn.Close()
+	to := rxconn.LocalAddr().(*net.UDPAddr)
+
+	// Sender - metadata with the path sealed then data frames with sealed payloads
+	var m metadata.MetaData
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d dirent.DirEnt
+	if err := d.Info("secret.txt", fi); err != nil {
+		t.Fatal(err)
+	}
+	minfo := metadata.Minfo{Session: session, Dir: &d}
+	if err := m.New("descriptor=d32,transfer=file,progress=inprogress,reliability=udponly,csumtype=none,encrypt=yes", &minfo); err != nil {
+		t.Fatal(err)
+	}
+	m.Dir.Path = tx.SealPath(m.Header, m.Dir.Path)
+	if err := m.Send(txconn, to); err != nil {
+		t.Fatal(err)
+	}
+	const chunk = 128
+	frames := 1
+	for off := 0; off < len(plaintext); off += chunk {
+		end := off + chunk
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+		var d data.Data
+		dinfo := data.Dinfo{Session: session, Offset: uint64(off), Payload: tx.Seal(uint64(off), plaintext[off:end])}
+		if err := d.New("descriptor=d32,reqtstamp=no,reqstatus=no,eod=no,encrypt=yes", &dinfo); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Send(txconn, to); err != nil {
+			t.Fatal(err)
+		}
+		frames++
+	}
+
+	// Receiver - nothing on the wire may be in the clear
+	var fp *os.File
+	buf := make([]byte, 2048)
+	for i := 0; i < frames; i++ {
+		rxconn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := rxconn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := buf[:n]
+		if bytes.Contains(frame, []byte("plaintext")) || bytes.Contains(frame, []byte("secret.txt")) {
+			t.Fatalf("frame %d carries plaintext: %q", i, frame)
+		}
+		switch sarflags.GetStr(uint32(frame[0])<<24|uint32(frame[1])<<16|uint32(frame[2])<<8|uint32(frame[3]), "frametype") {
+		case "metadata":
+			var rm metadata.MetaData
+			if err := rm.Decode(frame); err != nil {
+				t.Fatal(err)
+			}
+			if err := Check(rx, rm.Header); err != nil {
+				t.Fatal(err)
+			}
+			path, err := rx.OpenPath(rm.Header, rm.Dir.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A path cannot be replayed with another progress
+			h := sarflags.MetadataHeader(rm.Header)
+			h.SetProgress(sarflags.ProgressTerminated)
+			if _, err := rx.OpenPath(uint32(h), rm.Dir.Path); err == nil {
+				t.Error("path opened with the progress changed")
+			}
+			if filepath.Base(path) != "secret.txt" {
+				t.Fatalf("metadata path %s", path)
+			}
+			if fp, err = os.Create(filepath.Join(dstdir, filepath.Base(path))); err != nil {
+				t.Fatal(err)
+			}
+			defer fp.Close()
+		case "data":
+			var rd data.Data
+			if err := rd.Decode(frame); err != nil {
+				t.Fatal(err)
+			}
+			if err := Check(rx, rd.Header); err != nil {
+				t.Fatal(err)
+			}
+			payload, err := rx.Open(rd.Offset, rd.Payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fp == nil {
+				t.Fatal("data before metadata")
+			}
+			if _, err := fp.WriteAt(payload, int64(rd.Offset)); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			t.Fatal("unexpected frame")
+		}
+	}
+	got, err := os.ReadFile(filepath.Join(dstdir, "secret.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatal("file on disk differs from the file sent")
+	}
+
+	// Retransmissions encrypt the same, tampering and the wrong offset or session fail
+	if !bytes.Equal(tx.Seal(0, plaintext[:chunk]), tx.Seal(0, plaintext[:chunk])) {
+		t.Error("retransmission not idempotent")
+	}
+	sealed := tx.Seal(chunk, plaintext[chunk:2*chunk])
+	if _, err := rx.Open(0, sealed); err == nil {
+		t.Error("opened payload at the wrong offset")
+	}
+	sealed[0] ^= 1
+	if _, err := rx.Open(chunk, sealed); err == nil {
+		t.Error("opened tampered payload")
+	}
+	other, _ := New(key, session+1, salt)
+	if _, err := other.Open(0, tx.Seal(0, plaintext[:chunk])); err == nil {
+		t.Error("opened payload from another session")
+	}
+	// The same session number with another salt is another key
+	resalted, _ := New(key, session, []byte("fedcba9876543210"))
+	if bytes.Equal(resalted.Seal(0, plaintext[:chunk]), tx.Seal(0, plaintext[:chunk])) {
+		t.Error("salt does not change the key")
+	}
+	if _, err := resalted.Open(0, tx.Seal(0, plaintext[:chunk])); err == nil {
+		t.Error("opened payload with another salt")
+	}
+
+	// A peer without the capability never sets encrypt in its status
+	clear, _ := sarflags.Set(0, "encrypt", "no")
+	if err := Check(tx, clear); err != ErrNotSupported {
+		t.Error("unencrypted status accepted for encrypted transfer:", err)
+	}
+	enc, _ := sarflags.Set(0, "encrypt", "yes")
+	if err := Check(nil, enc); err != ErrUnexpected {
+		t.Error("encrypted frame accepted for unencrypted transfer:", err)
+	}
+	if _, err := New(nil, session, salt); err != ErrNoKey {
+		t.Error("session created without a key")
+	}
+	if _, err := New(key, session, nil); err != ErrNoSalt {
+		t.Error("session created without a salt")
+	}
+}
@@ -0,0 +1,159 @@
+// Commands - Usage and help for the cli commands
+
+package sarflags
+
+// Commands - The usage and help of each cli command, shown by ? and usage
+var Commands = map[string]Cmdtype{
+	"?": {
+		Usage: "?",
+		Help:  "show valid commands. cmd ? shows the individual commands usage",
+	},
+	"acl": {
+		Usage: "acl",
+		Help:  "show the access control rules applied to incoming requests",
+	},
+	"alias": {
+		Usage: "alias [<name> [<eid>|<ip>|off]]",
+		Help:  "show, set or remove names that can be used wherever a peer is given",
+	},
+	"beacon": {
+		Usage: "beacon [off] [v4|v6|<peer>...]",
+		Help:  "send bcount beacons every interval secs in the background. off stops them all or those to each destination",
+	},
+	"bcount": {
+		Usage: "bcount [numb]",
+		Help:  "set number of beacons to send, 0 is until beacon off",
+	},
+	"cancel": {
+		Usage: "cancel [<peer> <session>|all] [rm]",
+		Help:  "cancel transfers in progress and tell the peer, rm removes a partially received file",
+	},
+	"checksum": {
+		Usage: "checksum [off|none|crc32|md5|sha1]",
+		Help:  "set checksums required and type",
+	},
+	"clear": {
+		Usage: "clear [msg|err|packet]...",
+		Help:  "clear display window view",
+	},
+	"delete": {
+		Usage: "delete <peer> <filename>",
+		Help:  "remove a file from a peer",
+	},
+	"descriptor": {
+		Usage: "descriptor [auto|d16|d32|d64|d128]",
+		Help:  "advertise & set default descriptor size",
+	},
+	"encrypt": {
+		Usage: "encrypt [yes|no]",
+		Help:  "encrypt data and path names of transfers with the peers key, yes also refuses unencrypted requests",
+	},
+	"exit": {
+		Usage: "exit [0|1] [now]",
+		Help:  "exit saratoga once transfers finish or the shutdown timeout passes, now cancels them straight away",
+	},
+	"files": {
+		Usage: "files",
+		Help:  "list local files currently open and mode",
+	},
+	"freespace": {
+		Usage: "freespace [yes|no]",
+		Help:  "advertise freespace or show amount left",
+	},
+	"get": {
+		Usage: "get [<peer> <filename>]",
+		Help:  "get a file from a peer",
+	},
+	"getdir": {
+		Usage: "getdir [<peer> <dirname>]",
+		Help:  "get a directory listing from a peer",
+	},
+	"give": {
+		Usage: "give <peer> <filename>",
+		Help:  "send a file to a peer and then remove it when successful",
+	},
+	"help": {
+		Usage: "help",
+		Help:  "show commands",
+	},
+	"history": {
+		Usage: "history",
+		Help:  "show command history",
+	},
+	"home": {
+		Usage: "home [<dirname>]",
+		Help:  "set home directory for transfers",
+	},
+	"interval": {
+		Usage: "interval [seconds]",
+		Help:  "set interval between sending beacons",
+	},
+	"ls": {
+		Usage: "ls [<peer> [<dirname>>]]",
+		Help:  "show local or a peers directory contents",
+	},
+	"peers": {
+		Usage: "peers",
+		Help:  "list peers found, with time since their last beacon and whether they are active or lost",
+	},
+	"put": {
+		Usage: "put <peer> <filename>",
+		Help:  "send a file to a peer",
+	},
+	"putblind": {
+		Usage: "putblind <peer> <filename>",
+		Help:  "send a file to a peer with no initial request/status exchange",
+	},
+	"quit": {
+		Usage: "quit [0|1] [now]",
+		Help:  "exit saratoga once transfers finish or the shutdown timeout passes, now cancels them straight away",
+	},
+	"reqtstamp": {
+		Usage: "reqtstamp [no|yes]",
+		Help:  "request timestamps",
+	},
+	"rmtran": {
+		Usage: "rmtran <peer> <session>",
+		Help:  "remove a current transfer",
+	},
+	"rxwilling": {
+		Usage: "rxwilling [on|off|capable]",
+		Help:  "current receive status or turn receive on/off/capable",
+	},
+	"source": {
+		Usage: "source <filename>",
+		Help:  "run the commands in a script file in order, # comments, set <name> <value>, $SARDIR $EID and $<name> expanded, sleep <secs>, wait [<secs>] for its transfers, stops at the first error",
+	},
+	"stream": {
+		Usage: "stream [yes|no]",
+		Help:  "current stream status or can/cannot handle stream",
+	},
+	"take": {
+		Usage: "take [<peer> <filename>]",
+		Help:  "get a file from a peer and remove it from peer when successfully transferred",
+	},
+	"timeout": {
+		Usage: "timeout [metadata|request|transfer|status|datacounter|peerexpiry|shutdown] <secs|off>",
+		Help:  "timeouts in secs for metadata, request frames, status receipts, transfer completion, status sent every datacounter frames, peer lost after peerexpiry beacon intervals, wait on exit for transfers to finish",
+	},
+	"timestamp": {
+		Usage: "timestamp [off|32|64|32_32|64_32|32_y2k]",
+		Help:  "timestamp type to send",
+	},
+	"timezone": {
+		Usage: "timezone [utc|local]",
+		Help:  "show current or set to use local or universal time",
+	},
+	"tran": {
+		Usage: "tran [get|take|getdir|put|give|putblind|rm|rmdir]",
+		Help:  "list current transfers of specific type or all, with their session and whether running or cancelled",
+	},
+	"txwilling": {
+		Usage: "txwilling [on|off|capable]",
+		Help:  "show current transfer capability or set on/off/capable",
+	},
+	"usage": {
+		Usage: "usage",
+		Help:  "show usage of commands",
+	},
+}
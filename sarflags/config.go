@@ -0,0 +1,316 @@
+// Config - Checking the saratoga.json config file
+// Values are decoded by type into the config struct, a wrong type or value is a ConfigError
+// naming the key path e.g. timeout.shutdown or acl.rules[1].peer
+// Keys the config struct has no field for are warnings, they are most likely typos
+
+package sarflags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/charlesetsmith/saratoga/eid"
+)
+
+// ConfigError - A key in the config file with a value we cannot use
+type ConfigError struct {
+	File string
+	Key  string // Path to the key e.g. timeout.shutdown
+	Msg  string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.File, e.Key, e.Msg)
+}
+
+// Check - Load the config file and make sure the directories and files it names are there
+// These are left out of Load so a config can be read on a host without them
+func (c *Cliflags) Check(fname string, set map[string]string) error {
+	if err := c.Load(fname, set); err != nil {
+		return err
+	}
+	var errs []error
+	if fi, err := os.Stat(c.Sardir); err != nil {
+		errs = append(errs, &ConfigError{File: fname, Key: "sardir", Msg: err.Error()})
+	} else if !fi.IsDir() {
+		errs = append(errs, &ConfigError{File: fname, Key: "sardir", Msg: c.Sardir + " is not a directory"})
+	}
+	if keyfile := c.Auth.Keyfile; keyfile != "" {
+		if !filepath.IsAbs(keyfile) { // Relative to the config file
+			keyfile = filepath.Join(filepath.Dir(fname), keyfile)
+		}
+		if _, err := os.Stat(keyfile); err != nil {
+			errs = append(errs, &ConfigError{File: fname, Key: "auth.keyfile", Msg: err.Error()})
+		}
+		c.Auth.Keyfile = keyfile // So it is found wherever we load it from
+	}
+	return errors.Join(errs...)
+}
+
+// Take out the _comment keys, and any other starting with _, at every level
+func nocomments(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, mv := range v {
+			if strings.HasPrefix(k, "_") {
+				delete(v, k)
+				continue
+			}
+			nocomments(mv)
+		}
+	case []interface{}:
+		for _, sv := range v {
+			nocomments(sv)
+		}
+	}
+}
+
+// The key path of k within path
+func keypath(path string, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+// The paths of the keys in v that t has no field for, sorted
+func unknownkeys(path string, v interface{}, t reflect.Type) []string {
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok { // decode says what is wrong with it
+			return nil
+		}
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" {
+				name = f.Name
+			}
+			fields[strings.ToLower(name)] = f.Type // encoding/json matches keys without case
+		}
+		for _, k := range sortedkeys(m) {
+			ft, ok := fields[strings.ToLower(k)]
+			if !ok {
+				unknown = append(unknown, keypath(path, k))
+				continue
+			}
+			unknown = append(unknown, unknownkeys(keypath(path, k), m[k], ft)...)
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			for _, k := range sortedkeys(m) {
+				unknown = append(unknown, unknownkeys(keypath(path, k), m[k], t.Elem())...)
+			}
+		}
+	case reflect.Slice:
+		if l, ok := v.([]interface{}); ok {
+			for i, e := range l {
+				unknown = append(unknown, unknownkeys(fmt.Sprintf("%s[%d]", path, i), e, t.Elem())...)
+			}
+		}
+	}
+	return unknown
+}
+
+func sortedkeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decode the config file keys in m into conf, a value of the wrong type is a ConfigError
+func decode(fname string, m map[string]interface{}, conf *config) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, conf); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			return &ConfigError{File: fname, Key: te.Field, Msg: "want " + typename(te.Type) + " got " + te.Value}
+		}
+		return fmt.Errorf("cannot decode saratoga config file %s: %w", fname, err)
+	}
+	return nil
+}
+
+// What a value of type t looks like in the config file
+func typename(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "a whole number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number 0 or more"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "a map of keys"
+	}
+	return t.String()
+}
+
+// The options of flag, sorted for messages
+func options(flag string) string {
+	var opts []string
+	for o := range flagtab[flag].Options {
+		opts = append(opts, o)
+	}
+	sort.Strings(opts)
+	return strings.Join(opts, ",")
+}
+
+// Check every value in conf is one we can use
+func validate(fname string, conf *config) error {
+	var errs []error
+	bad := func(key string, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{File: fname, Key: key, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if ip := net.ParseIP(conf.V4multicast); ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+		bad("v4multicast", "%q is not an IPv4 multicast address", conf.V4multicast)
+	}
+	if ip := net.ParseIP(conf.V6multicast); ip == nil || ip.To4() != nil || !ip.IsMulticast() {
+		bad("v6multicast", "%q is not an IPv6 multicast address", conf.V6multicast)
+	}
+	if conf.Port < 1 || conf.Port > 65535 {
+		bad("udpport", "%d is not a port 1 to 65535", conf.Port)
+	}
+
+	// The global header flags must be options in the flag tables
+	for _, f := range []struct{ flag, val string }{
+		{"descriptor", conf.Descriptor},
+		{"csumtype", conf.Csumtype},
+		{"freespace", conf.Freespace},
+		{"txwilling", conf.Txwilling},
+		{"rxwilling", conf.Rxwilling},
+		{"stream", conf.Stream},
+		{"reqtstamp", conf.Reqtstamp},
+		{"reqstatus", conf.Reqstatus},
+		{"udplite", conf.Udplite},
+		{"encrypt", conf.Encrypt},
+	} {
+		if !Valid(f.flag, f.val) {
+			bad(f.flag, "%q is not one of %s", f.val, options(f.flag))
+		}
+	}
+	if Valid("descriptor", conf.Descriptor) && Valid("descriptor", MaxDescriptor) &&
+		flagtab["descriptor"].Options[conf.Descriptor] > flagtab["descriptor"].Options[MaxDescriptor] {
+		bad("descriptor", "%s is bigger than %s the largest on this platform", conf.Descriptor, MaxDescriptor)
+	}
+	if _, ok := tstamptab.Options[conf.Timestamp]; !ok {
+		var opts []string
+		for o := range tstamptab.Options {
+			opts = append(opts, o)
+		}
+		sort.Strings(opts)
+		bad("timestamp", "%q is not one of %s", conf.Timestamp, strings.Join(opts, ","))
+	}
+	if conf.Timezone != "utc" && conf.Timezone != "local" {
+		bad("timezone", "%q is not one of utc,local", conf.Timezone)
+	}
+
+	if conf.Sardir == "" {
+		bad("sardir", "no directory given")
+	}
+	if conf.Ppad < 0 {
+		bad("ppad", "%d is less than 0", conf.Ppad)
+	}
+	if conf.Buffersize < 1 {
+		bad("buffersize", "%d is less than 1", conf.Buffersize)
+	}
+	for _, t := range []struct {
+		key string
+		val int
+	}{
+		{"metadata", conf.Timeout.Metadata},
+		{"request", conf.Timeout.Request},
+		{"status", conf.Timeout.Status},
+		{"transfer", conf.Timeout.Transfer},
+		{"datacounter", conf.Timeout.Datacounter},
+		{"peerexpiry", conf.Timeout.Peerexpiry},
+		{"shutdown", conf.Timeout.Shutdown},
+	} {
+		if t.val < 0 {
+			bad("timeout."+t.key, "%d is less than 0", t.val)
+		}
+	}
+
+	if conf.Eid != "" {
+		if err := eid.Valid(conf.Eid); err != nil {
+			bad("eid", "%s: %v", conf.Eid, err)
+		}
+	}
+	aliases := make([]string, 0, len(conf.Aliases))
+	for alias := range conf.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		peer := conf.Aliases[alias]
+		if eid.Is(peer) {
+			if err := eid.Valid(peer); err != nil {
+				bad("aliases."+alias, "%s: %v", peer, err)
+			}
+		} else if net.ParseIP(peer) == nil {
+			bad("aliases."+alias, "%q is not an EID or IP address", peer)
+		}
+	}
+
+	switch conf.Acl.Default {
+	case "", "allow", "deny":
+	default:
+		bad("acl.default", "%q is not one of allow,deny", conf.Acl.Default)
+	}
+	for i, r := range conf.Acl.Rules {
+		key := fmt.Sprintf("acl.rules[%d]", i)
+		if r.Peer != "" {
+			if _, _, err := net.ParseCIDR(r.Peer); err != nil && net.ParseIP(r.Peer) == nil {
+				bad(key+".peer", "%q is not an IP address or CIDR", r.Peer)
+			}
+		}
+		if r.Eid != "" {
+			if err := eid.Valid(r.Eid); err != nil {
+				bad(key+".eid", "%s: %v", r.Eid, err)
+			}
+		}
+		if len(r.Ops) == 0 {
+			bad(key+".ops", "no ops given")
+		}
+		for _, op := range r.Ops {
+			switch op {
+			case "get", "put", "delete", "getdir":
+			default:
+				bad(key+".ops", "%q is not one of get,put,delete,getdir", op)
+			}
+		}
+	}
+
+	switch conf.Auth.Required {
+	case "", "yes", "no":
+	default:
+		bad("auth.required", "%q is not one of yes,no", conf.Auth.Required)
+	}
+	if conf.Auth.Window < 0 {
+		bad("auth.window", "%d is less than 0", conf.Auth.Window)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,125 @@
+package sarflags
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Write the repo saratoga.json with change applied to it and give its name
+func writeconfig(t *testing.T, change func(m map[string]interface{})) string {
+	t.Helper()
+	b, err := os.ReadFile("../saratoga/saratoga.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	change(m)
+	if b, err = json.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(t.TempDir(), "saratoga.json")
+	if err = os.WriteFile(fname, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+// The keys of the ConfigErrors in err
+func errkeys(err error) []string {
+	var keys []string
+	var errs []error
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = j.Unwrap()
+	} else if err != nil {
+		errs = []error{err}
+	}
+	for _, e := range errs {
+		var ce *ConfigError
+		if errors.As(e, &ce) {
+			keys = append(keys, ce.Key)
+		}
+	}
+	return keys
+}
+
+func TestConfig(t *testing.T) {
+	var c Cliflags
+	if err := c.ReadConfig("../saratoga/saratoga.json"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Warnings) != 0 {
+		t.Errorf("warnings %v for saratoga.json", c.Warnings)
+	}
+
+	// A value of the wrong type names its key rather than panicing
+	fname := writeconfig(t, func(m map[string]interface{}) {
+		m["timeout"].(map[string]interface{})["shutdown"] = "ten"
+	})
+	err := c.ReadConfig(fname)
+	if keys := errkeys(err); len(keys) != 1 || keys[0] != "timeout.shutdown" {
+		t.Errorf("wrong type got %v", err)
+	}
+
+	// Unknown keys are warnings
+	fname = writeconfig(t, func(m map[string]interface{}) {
+		m["udport"] = 7542
+		m["timeout"].(map[string]interface{})["shutdwn"] = 10
+		m["acl"].(map[string]interface{})["rules"].([]interface{})[0].(map[string]interface{})["op"] = "get"
+	})
+	if err = c.ReadConfig(fname); err != nil {
+		t.Fatal(err)
+	}
+	if w := strings.Join(c.Warnings, ","); w != "unknown key acl.rules[0].op,unknown key timeout.shutdwn,unknown key udport" {
+		t.Errorf("warnings %s", w)
+	}
+
+	// The protocol tables are built in so older config files with them still load
+	fname = writeconfig(t, func(m map[string]interface{}) {
+		m["flags"] = map[string]interface{}{"version": map[string]interface{}{"len": 2}}
+	})
+	if err = c.ReadConfig(fname); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Warnings) != 1 || c.Warnings[0] != "flags is built in, the key is ignored" || Value("version", "v1") != 1 {
+		t.Errorf("warnings %v", c.Warnings)
+	}
+
+	// Every bad value is given
+	fname = writeconfig(t, func(m map[string]interface{}) {
+		m["v4multicast"] = "10.0.0.1"
+		m["v6multicast"] = "224.0.0.108"
+		m["udpport"] = 70000
+		m["csumtype"] = "crc64"
+		m["timezone"] = "gmt"
+		m["aliases"].(map[string]interface{})["bad"] = "nowhere"
+		m["acl"].(map[string]interface{})["rules"].([]interface{})[0].(map[string]interface{})["ops"] = []string{"rename"}
+	})
+	err = c.ReadConfig(fname)
+	want := "v4multicast v6multicast udpport csumtype timezone aliases.bad acl.rules[0].ops"
+	if keys := strings.Join(errkeys(err), " "); keys != want {
+		t.Errorf("bad values %s want %s", keys, want)
+	}
+	if err != nil && !strings.Contains(err.Error(), "crc32") {
+		t.Errorf("%v does not give the csumtype options", err)
+	}
+
+	// Check also wants the directories and files it names
+	dir := t.TempDir()
+	if err = c.Check("../saratoga/saratoga.json", map[string]string{"sardir": dir}); err != nil {
+		t.Error(err)
+	}
+	fname = writeconfig(t, func(m map[string]interface{}) {
+		m["sardir"] = filepath.Join(dir, "missing")
+		m["auth"].(map[string]interface{})["keyfile"] = "keys"
+	})
+	if keys := strings.Join(errkeys(c.Check(fname, nil)), " "); keys != "sardir auth.keyfile" {
+		t.Errorf("check %s", keys)
+	}
+}
@@ -0,0 +1,221 @@
+//go:build ignore
+
+// Genheader - Write header_gen.go, the typed frame headers, from the built in flag table
+// Run by go generate in sarflags
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+// The option type of the flags that are not yes and no, flags sharing a type must have the same options
+var types = map[string]string{
+	"version":     "Version",
+	"frametype":   "Frametype",
+	"descriptor":  "Descriptor",
+	"freespaced":  "Descriptor",
+	"transfer":    "Transfer",
+	"progress":    "Progress",
+	"txwilling":   "Willing",
+	"rxwilling":   "Willing",
+	"reqtype":     "Reqtype",
+	"reqholes":    "Reqholes",
+	"csumlen":     "Csumlen",
+	"csumtype":    "Csumtype",
+	"reliability": "Reliability",
+	"errcode":     "Errcode",
+}
+
+// Method names that are not just the flag name with a capital
+var methods = map[string]string{
+	"eod": "EOD",
+}
+
+// A flag of a header
+type field struct {
+	name   string
+	method string
+	typ    string // "" for yes/no flags
+	shift  uint32
+	mask   uint32
+	yes    uint32 // The values of yes and no for yes/no flags
+	no     uint32
+}
+
+func title(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// The options of fl sorted by value
+func options(fl sarflags.Flagtype) []string {
+	opts := make([]string, 0, len(fl.Options))
+	for o := range fl.Options {
+		opts = append(opts, o)
+	}
+	sort.Slice(opts, func(i, j int) bool { return fl.Options[opts[i]] < fl.Options[opts[j]] })
+	return opts
+}
+
+func layout(name string) sarflags.Flagtype {
+	fl, ok := sarflags.Layout(name)
+	if !ok {
+		log.Fatalln("genheader: no flag", name)
+	}
+	return fl
+}
+
+// The fields of a frame header with the flags in names, those with no layout are skipped and returned
+func fields(names []string) (fs []field, skipped []string) {
+	for _, name := range names {
+		fl, ok := sarflags.Layout(name)
+		if !ok {
+			skipped = append(skipped, name)
+			continue
+		}
+		f := field{name: name, method: title(name), typ: types[name],
+			shift: 32 - fl.Len - fl.Msb, mask: 1<<fl.Len - 1}
+		if m, ok := methods[name]; ok {
+			f.method = m
+		}
+		if f.typ == "" {
+			yes, yok := fl.Options["yes"]
+			no, nok := fl.Options["no"]
+			if !yok || !nok || len(fl.Options) != 2 {
+				log.Fatalln("genheader: flag", name, "needs a type in types")
+			}
+			f.yes, f.no = yes, no
+		}
+		fs = append(fs, f)
+	}
+	return fs, skipped
+}
+
+func main() {
+	var b bytes.Buffer
+	p := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+	}
+	p("// Code generated by genheader.go from the flag table in tables.go; DO NOT EDIT.\n\n")
+	p("package sarflags\n\nimport \"strconv\"\n")
+
+	// The option types, from the first flag using each
+	var flags []string
+	for name := range types {
+		flags = append(flags, name)
+	}
+	sort.Strings(flags)
+	done := make(map[string]string)
+	var typenames []string
+	for _, name := range flags {
+		typ := types[name]
+		opts := strings.Join(options(layout(name)), ",")
+		if prev, ok := done[typ]; ok {
+			if prev != opts {
+				log.Fatalln("genheader: flags of type", typ, "have different options", prev, opts)
+			}
+			continue
+		}
+		done[typ] = opts
+		typenames = append(typenames, typ)
+	}
+	sort.Strings(typenames)
+	for _, typ := range typenames {
+		var fl sarflags.Flagtype
+		for _, name := range flags {
+			if types[name] == typ {
+				fl = layout(name)
+				break
+			}
+		}
+		p("\n// %s - The %s options\ntype %s uint32\n\nconst (\n", typ, strings.ToLower(typ), typ)
+		for _, o := range options(fl) {
+			p("\t%s%s %s = %d\n", typ, title(o), typ, fl.Options[o])
+		}
+		p(")\n\nvar %snames = [...]string{\n", strings.ToLower(typ))
+		for _, o := range options(fl) {
+			p("\t%d: %q,\n", fl.Options[o], o)
+		}
+		p("}\n\n")
+		p("// String - The option name as the string API has it\n")
+		p("func (v %s) String() string {\n", typ)
+		p("\tif int(v) < len(%snames) && %snames[v] != \"\" {\n", strings.ToLower(typ), strings.ToLower(typ))
+		p("\t\treturn %snames[v]\n\t}\n", strings.ToLower(typ))
+		p("\treturn \"%s(\" + strconv.Itoa(int(v)) + \")\"\n}\n", typ)
+	}
+
+	// The headers, Header for the flags common to every frame then one for each frame type
+	frametypes := options(layout("frametype"))
+	count := make(map[string]int)
+	for _, ft := range frametypes {
+		for _, name := range sarflags.Fields(ft) {
+			count[name]++
+		}
+	}
+	var common []string
+	for _, name := range sarflags.Fields(frametypes[0]) {
+		if count[name] == len(frametypes) {
+			common = append(common, name)
+		}
+	}
+	header("Header", "The flags common to every frame header", common, "", p)
+	for _, ft := range frametypes {
+		header(title(ft)+"Header", "The header of a "+ft+" frame", sarflags.Fields(ft), ft, p)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalln("genheader:", err)
+	}
+	if err = os.WriteFile("header_gen.go", src, 0644); err != nil {
+		log.Fatalln("genheader:", err)
+	}
+}
+
+// Write the type name for a header with the flags in names, a frame header if frametype is given
+func header(name string, doc string, names []string, frametype string, p func(string, ...interface{})) {
+	fs, skipped := fields(names)
+	p("\n// %s - %s\n", name, doc)
+	for _, s := range skipped {
+		p("// %s has no bits in the flag table so no methods\n", s)
+	}
+	p("type %s uint32\n", name)
+	if frametype != "" {
+		v1 := layout("version").Options["v1"]
+		ftv := layout("frametype").Options[frametype]
+		var h uint32
+		for _, f := range fs {
+			switch f.name {
+			case "version":
+				h |= v1 << f.shift
+			case "frametype":
+				h |= ftv << f.shift
+			}
+		}
+		p("\n// New%s - A version 1 %s frame header with its other flags 0\n", name, frametype)
+		p("func New%s() %s {\n\treturn %s(%#08x)\n}\n", name, name, name, h)
+	}
+	for _, f := range fs {
+		if f.typ != "" {
+			p("\n// %s - The %s flag\n", f.method, f.name)
+			p("func (h %s) %s() %s {\n\treturn %s(uint32(h) >> %d & %#x)\n}\n", name, f.method, f.typ, f.typ, f.shift, f.mask)
+			p("\n// Set%s - Set the %s flag\n", f.method, f.name)
+			p("func (h *%s) Set%s(v %s) {\n", name, f.method, f.typ)
+			p("\t*h = %s(uint32(*h)&^(%#x<<%d) | uint32(v)&%#x<<%d)\n}\n", name, f.mask, f.shift, f.mask, f.shift)
+			continue
+		}
+		p("\n// %s - Is the %s flag yes\n", f.method, f.name)
+		p("func (h %s) %s() bool {\n\treturn uint32(h)>>%d&%#x == %d\n}\n", name, f.method, f.shift, f.mask, f.yes)
+		p("\n// Set%s - Set the %s flag to yes or no\n", f.method, f.name)
+		p("func (h *%s) Set%s(b bool) {\n\tv := uint32(%d)\n\tif b {\n\t\tv = %d\n\t}\n", name, f.method, f.no, f.yes)
+		p("\t*h = %s(uint32(*h)&^(%#x<<%d) | v<<%d)\n}\n", name, f.mask, f.shift, f.shift)
+	}
+}
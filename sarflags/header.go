@@ -0,0 +1,39 @@
+// Header - Typed access to the flags in a frame header
+// The string API of Get, GetStr and Set looks each flag up by name, fine for the cli and commands
+// but not for every data and status frame. header_gen.go has a type for the header of each frame,
+// BeaconHeader, RequestHeader, MetadataHeader, DataHeader and StatusHeader, with a method to get
+// and to set each of the flags in it e.g. h.Descriptor() and h.SetEOD(true). Header has the flags
+// common to all frames. Options are typed, Descriptor, Errcode etc. and their String is the
+// option name the string API uses. Flags whose options are yes and no are bool
+// header_gen.go is generated from flagtab, go generate it after changing tables.go
+
+package sarflags
+
+//go:generate go run genheader.go
+
+// Layout - Where field is in the frame header and its options, a copy from the built in table
+func Layout(field string) (Flagtype, bool) {
+	fl, ok := flagtab[field]
+	if !ok {
+		return Flagtype{}, false
+	}
+	l := Flagtype{Len: fl.Len, Msb: fl.Msb, Options: make(map[string]uint32, len(fl.Options))}
+	l.Frametypes = append(l.Frametypes, fl.Frametypes...)
+	for k, v := range fl.Options {
+		l.Options[k] = v
+	}
+	return l, true
+}
+
+// The option names of each flag indexed by value, for GetStr
+var flagnames = func() map[string][]string {
+	names := make(map[string][]string, len(flagtab))
+	for field, fl := range flagtab {
+		n := make([]string, 1<<fl.Len)
+		for name, v := range fl.Options {
+			n[v] = name
+		}
+		names[field] = n
+	}
+	return names
+}()
@@ -0,0 +1,905 @@
+// Code generated by genheader.go from the flag table in tables.go; DO NOT EDIT.
+
+package sarflags
+
+import "strconv"
+
+// Csumlen - The csumlen options
+type Csumlen uint32
+
+const (
+	CsumlenNone     Csumlen = 0
+	CsumlenCrc32    Csumlen = 1
+	CsumlenInvalid2 Csumlen = 2
+	CsumlenInvalid3 Csumlen = 3
+	CsumlenMd5      Csumlen = 4
+	CsumlenSha1     Csumlen = 5
+)
+
+var csumlennames = [...]string{
+	0: "none",
+	1: "crc32",
+	2: "invalid2",
+	3: "invalid3",
+	4: "md5",
+	5: "sha1",
+}
+
+// String - The option name as the string API has it
+func (v Csumlen) String() string {
+	if int(v) < len(csumlennames) && csumlennames[v] != "" {
+		return csumlennames[v]
+	}
+	return "Csumlen(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Csumtype - The csumtype options
+type Csumtype uint32
+
+const (
+	CsumtypeNone  Csumtype = 0
+	CsumtypeCrc32 Csumtype = 1
+	CsumtypeMd5   Csumtype = 2
+	CsumtypeSha1  Csumtype = 3
+)
+
+var csumtypenames = [...]string{
+	0: "none",
+	1: "crc32",
+	2: "md5",
+	3: "sha1",
+}
+
+// String - The option name as the string API has it
+func (v Csumtype) String() string {
+	if int(v) < len(csumtypenames) && csumtypenames[v] != "" {
+		return csumtypenames[v]
+	}
+	return "Csumtype(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Descriptor - The descriptor options
+type Descriptor uint32
+
+const (
+	DescriptorD16  Descriptor = 0
+	DescriptorD32  Descriptor = 1
+	DescriptorD64  Descriptor = 2
+	DescriptorD128 Descriptor = 3
+)
+
+var descriptornames = [...]string{
+	0: "d16",
+	1: "d32",
+	2: "d64",
+	3: "d128",
+}
+
+// String - The option name as the string API has it
+func (v Descriptor) String() string {
+	if int(v) < len(descriptornames) && descriptornames[v] != "" {
+		return descriptornames[v]
+	}
+	return "Descriptor(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Errcode - The errcode options
+type Errcode uint32
+
+const (
+	ErrcodeSuccess          Errcode = 0
+	ErrcodeUnspecified      Errcode = 1
+	ErrcodeCantsend         Errcode = 2
+	ErrcodeCantreceive      Errcode = 3
+	ErrcodeFilenotfound     Errcode = 4
+	ErrcodeAccessdenied     Errcode = 5
+	ErrcodeUnknownid        Errcode = 6
+	ErrcodeDidnotdelete     Errcode = 7
+	ErrcodeFiletobig        Errcode = 8
+	ErrcodeBadoffset        Errcode = 9
+	ErrcodeBadpacket        Errcode = 10
+	ErrcodeBadrequest       Errcode = 11
+	ErrcodeInternaltimeout  Errcode = 12
+	ErrcodeBaddataflag      Errcode = 13
+	ErrcodeRxnotinterested  Errcode = 14
+	ErrcodeFileinuse        Errcode = 15
+	ErrcodeMetadatarequired Errcode = 16
+	ErrcodeBadstatus        Errcode = 17
+	ErrcodeRxtimeout        Errcode = 18
+)
+
+var errcodenames = [...]string{
+	0:  "success",
+	1:  "unspecified",
+	2:  "cantsend",
+	3:  "cantreceive",
+	4:  "filenotfound",
+	5:  "accessdenied",
+	6:  "unknownid",
+	7:  "didnotdelete",
+	8:  "filetobig",
+	9:  "badoffset",
+	10: "badpacket",
+	11: "badrequest",
+	12: "internaltimeout",
+	13: "baddataflag",
+	14: "rxnotinterested",
+	15: "fileinuse",
+	16: "metadatarequired",
+	17: "badstatus",
+	18: "rxtimeout",
+}
+
+// String - The option name as the string API has it
+func (v Errcode) String() string {
+	if int(v) < len(errcodenames) && errcodenames[v] != "" {
+		return errcodenames[v]
+	}
+	return "Errcode(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Frametype - The frametype options
+type Frametype uint32
+
+const (
+	FrametypeBeacon   Frametype = 0
+	FrametypeRequest  Frametype = 1
+	FrametypeMetadata Frametype = 2
+	FrametypeData     Frametype = 3
+	FrametypeStatus   Frametype = 4
+)
+
+var frametypenames = [...]string{
+	0: "beacon",
+	1: "request",
+	2: "metadata",
+	3: "data",
+	4: "status",
+}
+
+// String - The option name as the string API has it
+func (v Frametype) String() string {
+	if int(v) < len(frametypenames) && frametypenames[v] != "" {
+		return frametypenames[v]
+	}
+	return "Frametype(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Progress - The progress options
+type Progress uint32
+
+const (
+	ProgressInprogress Progress = 0
+	ProgressTerminated Progress = 1
+)
+
+var progressnames = [...]string{
+	0: "inprogress",
+	1: "terminated",
+}
+
+// String - The option name as the string API has it
+func (v Progress) String() string {
+	if int(v) < len(progressnames) && progressnames[v] != "" {
+		return progressnames[v]
+	}
+	return "Progress(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Reliability - The reliability options
+type Reliability uint32
+
+const (
+	ReliabilityUdponly Reliability = 0
+	ReliabilityUdplite Reliability = 1
+)
+
+var reliabilitynames = [...]string{
+	0: "udponly",
+	1: "udplite",
+}
+
+// String - The option name as the string API has it
+func (v Reliability) String() string {
+	if int(v) < len(reliabilitynames) && reliabilitynames[v] != "" {
+		return reliabilitynames[v]
+	}
+	return "Reliability(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Reqholes - The reqholes options
+type Reqholes uint32
+
+const (
+	ReqholesRequested   Reqholes = 0
+	ReqholesVoluntarily Reqholes = 1
+)
+
+var reqholesnames = [...]string{
+	0: "requested",
+	1: "voluntarily",
+}
+
+// String - The option name as the string API has it
+func (v Reqholes) String() string {
+	if int(v) < len(reqholesnames) && reqholesnames[v] != "" {
+		return reqholesnames[v]
+	}
+	return "Reqholes(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Reqtype - The reqtype options
+type Reqtype uint32
+
+const (
+	ReqtypeNoaction Reqtype = 0
+	ReqtypeGet      Reqtype = 1
+	ReqtypePut      Reqtype = 2
+	ReqtypeTake     Reqtype = 3
+	ReqtypeGive     Reqtype = 4
+	ReqtypeDelete   Reqtype = 5
+	ReqtypeGetdir   Reqtype = 6
+)
+
+var reqtypenames = [...]string{
+	0: "noaction",
+	1: "get",
+	2: "put",
+	3: "take",
+	4: "give",
+	5: "delete",
+	6: "getdir",
+}
+
+// String - The option name as the string API has it
+func (v Reqtype) String() string {
+	if int(v) < len(reqtypenames) && reqtypenames[v] != "" {
+		return reqtypenames[v]
+	}
+	return "Reqtype(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Transfer - The transfer options
+type Transfer uint32
+
+const (
+	TransferFile      Transfer = 0
+	TransferDirectory Transfer = 1
+	TransferBundle    Transfer = 2
+	TransferStream    Transfer = 3
+)
+
+var transfernames = [...]string{
+	0: "file",
+	1: "directory",
+	2: "bundle",
+	3: "stream",
+}
+
+// String - The option name as the string API has it
+func (v Transfer) String() string {
+	if int(v) < len(transfernames) && transfernames[v] != "" {
+		return transfernames[v]
+	}
+	return "Transfer(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Version - The version options
+type Version uint32
+
+const (
+	VersionV0 Version = 0
+	VersionV1 Version = 1
+)
+
+var versionnames = [...]string{
+	0: "v0",
+	1: "v1",
+}
+
+// String - The option name as the string API has it
+func (v Version) String() string {
+	if int(v) < len(versionnames) && versionnames[v] != "" {
+		return versionnames[v]
+	}
+	return "Version(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Willing - The willing options
+type Willing uint32
+
+const (
+	WillingNo      Willing = 0
+	WillingInvalid Willing = 1
+	WillingCapable Willing = 2
+	WillingYes     Willing = 3
+)
+
+var willingnames = [...]string{
+	0: "no",
+	1: "invalid",
+	2: "capable",
+	3: "yes",
+}
+
+// String - The option name as the string API has it
+func (v Willing) String() string {
+	if int(v) < len(willingnames) && willingnames[v] != "" {
+		return willingnames[v]
+	}
+	return "Willing(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Header - The flags common to every frame header
+type Header uint32
+
+// Version - The version flag
+func (h Header) Version() Version {
+	return Version(uint32(h) >> 29 & 0x7)
+}
+
+// SetVersion - Set the version flag
+func (h *Header) SetVersion(v Version) {
+	*h = Header(uint32(*h)&^(0x7<<29) | uint32(v)&0x7<<29)
+}
+
+// Frametype - The frametype flag
+func (h Header) Frametype() Frametype {
+	return Frametype(uint32(h) >> 24 & 0x1f)
+}
+
+// SetFrametype - Set the frametype flag
+func (h *Header) SetFrametype(v Frametype) {
+	*h = Header(uint32(*h)&^(0x1f<<24) | uint32(v)&0x1f<<24)
+}
+
+// Descriptor - The descriptor flag
+func (h Header) Descriptor() Descriptor {
+	return Descriptor(uint32(h) >> 22 & 0x3)
+}
+
+// SetDescriptor - Set the descriptor flag
+func (h *Header) SetDescriptor(v Descriptor) {
+	*h = Header(uint32(*h)&^(0x3<<22) | uint32(v)&0x3<<22)
+}
+
+// BeaconHeader - The header of a beacon frame
+type BeaconHeader uint32
+
+// NewBeaconHeader - A version 1 beacon frame header with its other flags 0
+func NewBeaconHeader() BeaconHeader {
+	return BeaconHeader(0x20000000)
+}
+
+// Version - The version flag
+func (h BeaconHeader) Version() Version {
+	return Version(uint32(h) >> 29 & 0x7)
+}
+
+// SetVersion - Set the version flag
+func (h *BeaconHeader) SetVersion(v Version) {
+	*h = BeaconHeader(uint32(*h)&^(0x7<<29) | uint32(v)&0x7<<29)
+}
+
+// Frametype - The frametype flag
+func (h BeaconHeader) Frametype() Frametype {
+	return Frametype(uint32(h) >> 24 & 0x1f)
+}
+
+// SetFrametype - Set the frametype flag
+func (h *BeaconHeader) SetFrametype(v Frametype) {
+	*h = BeaconHeader(uint32(*h)&^(0x1f<<24) | uint32(v)&0x1f<<24)
+}
+
+// Descriptor - The descriptor flag
+func (h BeaconHeader) Descriptor() Descriptor {
+	return Descriptor(uint32(h) >> 22 & 0x3)
+}
+
+// SetDescriptor - Set the descriptor flag
+func (h *BeaconHeader) SetDescriptor(v Descriptor) {
+	*h = BeaconHeader(uint32(*h)&^(0x3<<22) | uint32(v)&0x3<<22)
+}
+
+// Stream - Is the stream flag yes
+func (h BeaconHeader) Stream() bool {
+	return uint32(h)>>20&0x1 == 1
+}
+
+// SetStream - Set the stream flag to yes or no
+func (h *BeaconHeader) SetStream(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = BeaconHeader(uint32(*h)&^(0x1<<20) | v<<20)
+}
+
+// Txwilling - The txwilling flag
+func (h BeaconHeader) Txwilling() Willing {
+	return Willing(uint32(h) >> 18 & 0x3)
+}
+
+// SetTxwilling - Set the txwilling flag
+func (h *BeaconHeader) SetTxwilling(v Willing) {
+	*h = BeaconHeader(uint32(*h)&^(0x3<<18) | uint32(v)&0x3<<18)
+}
+
+// Rxwilling - The rxwilling flag
+func (h BeaconHeader) Rxwilling() Willing {
+	return Willing(uint32(h) >> 16 & 0x3)
+}
+
+// SetRxwilling - Set the rxwilling flag
+func (h *BeaconHeader) SetRxwilling(v Willing) {
+	*h = BeaconHeader(uint32(*h)&^(0x3<<16) | uint32(v)&0x3<<16)
+}
+
+// Udplite - Is the udplite flag yes
+func (h BeaconHeader) Udplite() bool {
+	return uint32(h)>>15&0x1 == 1
+}
+
+// SetUdplite - Set the udplite flag to yes or no
+func (h *BeaconHeader) SetUdplite(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = BeaconHeader(uint32(*h)&^(0x1<<15) | v<<15)
+}
+
+// Freespace - Is the freespace flag yes
+func (h BeaconHeader) Freespace() bool {
+	return uint32(h)>>14&0x1 == 1
+}
+
+// SetFreespace - Set the freespace flag to yes or no
+func (h *BeaconHeader) SetFreespace(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = BeaconHeader(uint32(*h)&^(0x1<<14) | v<<14)
+}
+
+// Freespaced - The freespaced flag
+func (h BeaconHeader) Freespaced() Descriptor {
+	return Descriptor(uint32(h) >> 12 & 0x3)
+}
+
+// SetFreespaced - Set the freespaced flag
+func (h *BeaconHeader) SetFreespaced(v Descriptor) {
+	*h = BeaconHeader(uint32(*h)&^(0x3<<12) | uint32(v)&0x3<<12)
+}
+
+// RequestHeader - The header of a request frame
+// fileordir has no bits in the flag table so no methods
+type RequestHeader uint32
+
+// NewRequestHeader - A version 1 request frame header with its other flags 0
+func NewRequestHeader() RequestHeader {
+	return RequestHeader(0x21000000)
+}
+
+// Version - The version flag
+func (h RequestHeader) Version() Version {
+	return Version(uint32(h) >> 29 & 0x7)
+}
+
+// SetVersion - Set the version flag
+func (h *RequestHeader) SetVersion(v Version) {
+	*h = RequestHeader(uint32(*h)&^(0x7<<29) | uint32(v)&0x7<<29)
+}
+
+// Frametype - The frametype flag
+func (h RequestHeader) Frametype() Frametype {
+	return Frametype(uint32(h) >> 24 & 0x1f)
+}
+
+// SetFrametype - Set the frametype flag
+func (h *RequestHeader) SetFrametype(v Frametype) {
+	*h = RequestHeader(uint32(*h)&^(0x1f<<24) | uint32(v)&0x1f<<24)
+}
+
+// Descriptor - The descriptor flag
+func (h RequestHeader) Descriptor() Descriptor {
+	return Descriptor(uint32(h) >> 22 & 0x3)
+}
+
+// SetDescriptor - Set the descriptor flag
+func (h *RequestHeader) SetDescriptor(v Descriptor) {
+	*h = RequestHeader(uint32(*h)&^(0x3<<22) | uint32(v)&0x3<<22)
+}
+
+// Stream - Is the stream flag yes
+func (h RequestHeader) Stream() bool {
+	return uint32(h)>>20&0x1 == 1
+}
+
+// SetStream - Set the stream flag to yes or no
+func (h *RequestHeader) SetStream(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = RequestHeader(uint32(*h)&^(0x1<<20) | v<<20)
+}
+
+// Txwilling - The txwilling flag
+func (h RequestHeader) Txwilling() Willing {
+	return Willing(uint32(h) >> 18 & 0x3)
+}
+
+// SetTxwilling - Set the txwilling flag
+func (h *RequestHeader) SetTxwilling(v Willing) {
+	*h = RequestHeader(uint32(*h)&^(0x3<<18) | uint32(v)&0x3<<18)
+}
+
+// Rxwilling - The rxwilling flag
+func (h RequestHeader) Rxwilling() Willing {
+	return Willing(uint32(h) >> 16 & 0x3)
+}
+
+// SetRxwilling - Set the rxwilling flag
+func (h *RequestHeader) SetRxwilling(v Willing) {
+	*h = RequestHeader(uint32(*h)&^(0x3<<16) | uint32(v)&0x3<<16)
+}
+
+// Reqtype - The reqtype flag
+func (h RequestHeader) Reqtype() Reqtype {
+	return Reqtype(uint32(h) >> 0 & 0xff)
+}
+
+// SetReqtype - Set the reqtype flag
+func (h *RequestHeader) SetReqtype(v Reqtype) {
+	*h = RequestHeader(uint32(*h)&^(0xff<<0) | uint32(v)&0xff<<0)
+}
+
+// Udplite - Is the udplite flag yes
+func (h RequestHeader) Udplite() bool {
+	return uint32(h)>>15&0x1 == 1
+}
+
+// SetUdplite - Set the udplite flag to yes or no
+func (h *RequestHeader) SetUdplite(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = RequestHeader(uint32(*h)&^(0x1<<15) | v<<15)
+}
+
+// Encrypt - Is the encrypt flag yes
+func (h RequestHeader) Encrypt() bool {
+	return uint32(h)>>14&0x1 == 1
+}
+
+// SetEncrypt - Set the encrypt flag to yes or no
+func (h *RequestHeader) SetEncrypt(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = RequestHeader(uint32(*h)&^(0x1<<14) | v<<14)
+}
+
+// MetadataHeader - The header of a metadata frame
+type MetadataHeader uint32
+
+// NewMetadataHeader - A version 1 metadata frame header with its other flags 0
+func NewMetadataHeader() MetadataHeader {
+	return MetadataHeader(0x22000000)
+}
+
+// Version - The version flag
+func (h MetadataHeader) Version() Version {
+	return Version(uint32(h) >> 29 & 0x7)
+}
+
+// SetVersion - Set the version flag
+func (h *MetadataHeader) SetVersion(v Version) {
+	*h = MetadataHeader(uint32(*h)&^(0x7<<29) | uint32(v)&0x7<<29)
+}
+
+// Frametype - The frametype flag
+func (h MetadataHeader) Frametype() Frametype {
+	return Frametype(uint32(h) >> 24 & 0x1f)
+}
+
+// SetFrametype - Set the frametype flag
+func (h *MetadataHeader) SetFrametype(v Frametype) {
+	*h = MetadataHeader(uint32(*h)&^(0x1f<<24) | uint32(v)&0x1f<<24)
+}
+
+// Descriptor - The descriptor flag
+func (h MetadataHeader) Descriptor() Descriptor {
+	return Descriptor(uint32(h) >> 22 & 0x3)
+}
+
+// SetDescriptor - Set the descriptor flag
+func (h *MetadataHeader) SetDescriptor(v Descriptor) {
+	*h = MetadataHeader(uint32(*h)&^(0x3<<22) | uint32(v)&0x3<<22)
+}
+
+// Transfer - The transfer flag
+func (h MetadataHeader) Transfer() Transfer {
+	return Transfer(uint32(h) >> 20 & 0x3)
+}
+
+// SetTransfer - Set the transfer flag
+func (h *MetadataHeader) SetTransfer(v Transfer) {
+	*h = MetadataHeader(uint32(*h)&^(0x3<<20) | uint32(v)&0x3<<20)
+}
+
+// Progress - The progress flag
+func (h MetadataHeader) Progress() Progress {
+	return Progress(uint32(h) >> 19 & 0x1)
+}
+
+// SetProgress - Set the progress flag
+func (h *MetadataHeader) SetProgress(v Progress) {
+	*h = MetadataHeader(uint32(*h)&^(0x1<<19) | uint32(v)&0x1<<19)
+}
+
+// Reliability - The reliability flag
+func (h MetadataHeader) Reliability() Reliability {
+	return Reliability(uint32(h) >> 18 & 0x1)
+}
+
+// SetReliability - Set the reliability flag
+func (h *MetadataHeader) SetReliability(v Reliability) {
+	*h = MetadataHeader(uint32(*h)&^(0x1<<18) | uint32(v)&0x1<<18)
+}
+
+// Csumlen - The csumlen flag
+func (h MetadataHeader) Csumlen() Csumlen {
+	return Csumlen(uint32(h) >> 4 & 0xf)
+}
+
+// SetCsumlen - Set the csumlen flag
+func (h *MetadataHeader) SetCsumlen(v Csumlen) {
+	*h = MetadataHeader(uint32(*h)&^(0xf<<4) | uint32(v)&0xf<<4)
+}
+
+// Csumtype - The csumtype flag
+func (h MetadataHeader) Csumtype() Csumtype {
+	return Csumtype(uint32(h) >> 0 & 0xf)
+}
+
+// SetCsumtype - Set the csumtype flag
+func (h *MetadataHeader) SetCsumtype(v Csumtype) {
+	*h = MetadataHeader(uint32(*h)&^(0xf<<0) | uint32(v)&0xf<<0)
+}
+
+// Encrypt - Is the encrypt flag yes
+func (h MetadataHeader) Encrypt() bool {
+	return uint32(h)>>14&0x1 == 1
+}
+
+// SetEncrypt - Set the encrypt flag to yes or no
+func (h *MetadataHeader) SetEncrypt(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = MetadataHeader(uint32(*h)&^(0x1<<14) | v<<14)
+}
+
+// DataHeader - The header of a data frame
+type DataHeader uint32
+
+// NewDataHeader - A version 1 data frame header with its other flags 0
+func NewDataHeader() DataHeader {
+	return DataHeader(0x23000000)
+}
+
+// Version - The version flag
+func (h DataHeader) Version() Version {
+	return Version(uint32(h) >> 29 & 0x7)
+}
+
+// SetVersion - Set the version flag
+func (h *DataHeader) SetVersion(v Version) {
+	*h = DataHeader(uint32(*h)&^(0x7<<29) | uint32(v)&0x7<<29)
+}
+
+// Frametype - The frametype flag
+func (h DataHeader) Frametype() Frametype {
+	return Frametype(uint32(h) >> 24 & 0x1f)
+}
+
+// SetFrametype - Set the frametype flag
+func (h *DataHeader) SetFrametype(v Frametype) {
+	*h = DataHeader(uint32(*h)&^(0x1f<<24) | uint32(v)&0x1f<<24)
+}
+
+// Descriptor - The descriptor flag
+func (h DataHeader) Descriptor() Descriptor {
+	return Descriptor(uint32(h) >> 22 & 0x3)
+}
+
+// SetDescriptor - Set the descriptor flag
+func (h *DataHeader) SetDescriptor(v Descriptor) {
+	*h = DataHeader(uint32(*h)&^(0x3<<22) | uint32(v)&0x3<<22)
+}
+
+// Transfer - The transfer flag
+func (h DataHeader) Transfer() Transfer {
+	return Transfer(uint32(h) >> 20 & 0x3)
+}
+
+// SetTransfer - Set the transfer flag
+func (h *DataHeader) SetTransfer(v Transfer) {
+	*h = DataHeader(uint32(*h)&^(0x3<<20) | uint32(v)&0x3<<20)
+}
+
+// Reqtstamp - Is the reqtstamp flag yes
+func (h DataHeader) Reqtstamp() bool {
+	return uint32(h)>>19&0x1 == 1
+}
+
+// SetReqtstamp - Set the reqtstamp flag to yes or no
+func (h *DataHeader) SetReqtstamp(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = DataHeader(uint32(*h)&^(0x1<<19) | v<<19)
+}
+
+// Reqstatus - Is the reqstatus flag yes
+func (h DataHeader) Reqstatus() bool {
+	return uint32(h)>>16&0x1 == 1
+}
+
+// SetReqstatus - Set the reqstatus flag to yes or no
+func (h *DataHeader) SetReqstatus(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = DataHeader(uint32(*h)&^(0x1<<16) | v<<16)
+}
+
+// EOD - Is the eod flag yes
+func (h DataHeader) EOD() bool {
+	return uint32(h)>>15&0x1 == 1
+}
+
+// SetEOD - Set the eod flag to yes or no
+func (h *DataHeader) SetEOD(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = DataHeader(uint32(*h)&^(0x1<<15) | v<<15)
+}
+
+// Encrypt - Is the encrypt flag yes
+func (h DataHeader) Encrypt() bool {
+	return uint32(h)>>14&0x1 == 1
+}
+
+// SetEncrypt - Set the encrypt flag to yes or no
+func (h *DataHeader) SetEncrypt(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = DataHeader(uint32(*h)&^(0x1<<14) | v<<14)
+}
+
+// StatusHeader - The header of a status frame
+type StatusHeader uint32
+
+// NewStatusHeader - A version 1 status frame header with its other flags 0
+func NewStatusHeader() StatusHeader {
+	return StatusHeader(0x24000000)
+}
+
+// Version - The version flag
+func (h StatusHeader) Version() Version {
+	return Version(uint32(h) >> 29 & 0x7)
+}
+
+// SetVersion - Set the version flag
+func (h *StatusHeader) SetVersion(v Version) {
+	*h = StatusHeader(uint32(*h)&^(0x7<<29) | uint32(v)&0x7<<29)
+}
+
+// Frametype - The frametype flag
+func (h StatusHeader) Frametype() Frametype {
+	return Frametype(uint32(h) >> 24 & 0x1f)
+}
+
+// SetFrametype - Set the frametype flag
+func (h *StatusHeader) SetFrametype(v Frametype) {
+	*h = StatusHeader(uint32(*h)&^(0x1f<<24) | uint32(v)&0x1f<<24)
+}
+
+// Descriptor - The descriptor flag
+func (h StatusHeader) Descriptor() Descriptor {
+	return Descriptor(uint32(h) >> 22 & 0x3)
+}
+
+// SetDescriptor - Set the descriptor flag
+func (h *StatusHeader) SetDescriptor(v Descriptor) {
+	*h = StatusHeader(uint32(*h)&^(0x3<<22) | uint32(v)&0x3<<22)
+}
+
+// Reqtstamp - Is the reqtstamp flag yes
+func (h StatusHeader) Reqtstamp() bool {
+	return uint32(h)>>19&0x1 == 1
+}
+
+// SetReqtstamp - Set the reqtstamp flag to yes or no
+func (h *StatusHeader) SetReqtstamp(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = StatusHeader(uint32(*h)&^(0x1<<19) | v<<19)
+}
+
+// Metadatarecvd - Is the metadatarecvd flag yes
+func (h StatusHeader) Metadatarecvd() bool {
+	return uint32(h)>>18&0x1 == 0
+}
+
+// SetMetadatarecvd - Set the metadatarecvd flag to yes or no
+func (h *StatusHeader) SetMetadatarecvd(b bool) {
+	v := uint32(1)
+	if b {
+		v = 0
+	}
+	*h = StatusHeader(uint32(*h)&^(0x1<<18) | v<<18)
+}
+
+// Allholes - Is the allholes flag yes
+func (h StatusHeader) Allholes() bool {
+	return uint32(h)>>17&0x1 == 0
+}
+
+// SetAllholes - Set the allholes flag to yes or no
+func (h *StatusHeader) SetAllholes(b bool) {
+	v := uint32(1)
+	if b {
+		v = 0
+	}
+	*h = StatusHeader(uint32(*h)&^(0x1<<17) | v<<17)
+}
+
+// Reqholes - The reqholes flag
+func (h StatusHeader) Reqholes() Reqholes {
+	return Reqholes(uint32(h) >> 16 & 0x1)
+}
+
+// SetReqholes - Set the reqholes flag
+func (h *StatusHeader) SetReqholes(v Reqholes) {
+	*h = StatusHeader(uint32(*h)&^(0x1<<16) | uint32(v)&0x1<<16)
+}
+
+// Errcode - The errcode flag
+func (h StatusHeader) Errcode() Errcode {
+	return Errcode(uint32(h) >> 0 & 0xff)
+}
+
+// SetErrcode - Set the errcode flag
+func (h *StatusHeader) SetErrcode(v Errcode) {
+	*h = StatusHeader(uint32(*h)&^(0xff<<0) | uint32(v)&0xff<<0)
+}
+
+// Encrypt - Is the encrypt flag yes
+func (h StatusHeader) Encrypt() bool {
+	return uint32(h)>>14&0x1 == 1
+}
+
+// SetEncrypt - Set the encrypt flag to yes or no
+func (h *StatusHeader) SetEncrypt(b bool) {
+	v := uint32(0)
+	if b {
+		v = 1
+	}
+	*h = StatusHeader(uint32(*h)&^(0x1<<14) | v<<14)
+}
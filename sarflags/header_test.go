@@ -0,0 +1,144 @@
+package sarflags
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The generated headers must agree with the string API on every flag and option
+func TestHeader(t *testing.T) {
+	headers := map[string]reflect.Type{
+		"beacon":   reflect.TypeOf(BeaconHeader(0)),
+		"request":  reflect.TypeOf(RequestHeader(0)),
+		"metadata": reflect.TypeOf(MetadataHeader(0)),
+		"data":     reflect.TypeOf(DataHeader(0)),
+		"status":   reflect.TypeOf(StatusHeader(0)),
+		"":         reflect.TypeOf(Header(0)),
+	}
+	for ft, typ := range headers {
+		names := Fields(ft)
+		if ft == "" {
+			names = []string{"version", "frametype", "descriptor"}
+		}
+		for _, field := range names {
+			fl, ok := Layout(field)
+			if !ok { // fileordir
+				continue
+			}
+			method := "Set" + title(field)
+			get, ok := reflect.PointerTo(typ).MethodByName(title(field))
+			set, sok := reflect.PointerTo(typ).MethodByName(method)
+			if !ok || !sok {
+				t.Errorf("%s has no %s or %s", typ, title(field), method)
+				continue
+			}
+			for option := range fl.Options {
+				// Every other bit set so setting must clear them as well
+				want, _ := Set(0xaaaaaaaa, field, option)
+				h := reflect.New(typ)
+				h.Elem().SetUint(0xaaaaaaaa)
+				arg := reflect.New(set.Type.In(1)).Elem()
+				if arg.Kind() == reflect.Bool {
+					if option != "yes" && option != "no" {
+						t.Fatalf("%s %s is bool with option %s", typ, field, option)
+					}
+					arg.SetBool(option == "yes")
+				} else {
+					arg.SetUint(uint64(fl.Options[option]))
+				}
+				set.Func.Call([]reflect.Value{h, arg})
+				if got := uint32(h.Elem().Uint()); got != want {
+					t.Errorf("%s.%s(%s) = %#x want %#x", typ, method, option, got, want)
+				}
+
+				v := get.Func.Call([]reflect.Value{h})[0]
+				var got string
+				if v.Kind() == reflect.Bool {
+					got = map[bool]string{true: "yes", false: "no"}[v.Bool()]
+				} else {
+					got = v.Interface().(interface{ String() string }).String()
+				}
+				if got != option || got != GetStr(want, field) {
+					t.Errorf("%s.%s() = %s want %s", typ, title(field), got, option)
+				}
+			}
+		}
+	}
+
+	for ft, h := range map[string]uint32{
+		"beacon":   uint32(NewBeaconHeader()),
+		"request":  uint32(NewRequestHeader()),
+		"metadata": uint32(NewMetadataHeader()),
+		"data":     uint32(NewDataHeader()),
+		"status":   uint32(NewStatusHeader()),
+	} {
+		want, _ := Set(0, "version", "v1")
+		want, _ = Set(want, "frametype", ft)
+		if h != want {
+			t.Errorf("New %s header %#x want %#x", ft, h, want)
+		}
+	}
+}
+
+// Values with no name and unknown flags are errors for the caller, not the end of the process
+func TestUnnamed(t *testing.T) {
+	h := NewRequestHeader()
+	h.SetReqtype(Reqtype(7))
+	if got := GetStr(uint32(h), "reqtype"); got != "reqtype(7)" || !Unnamed(got) {
+		t.Errorf("GetStr of reqtype 7 = %s", got)
+	}
+	if !Unnamed(h.Reqtype().String()) || Unnamed(ReqtypeGet.String()) {
+		t.Errorf("Unnamed wrong for %s or %s", h.Reqtype(), ReqtypeGet)
+	}
+	if _, err := Set(0, "nosuchflag", "yes"); err == nil {
+		t.Error("Set of an unknown flag did not fail")
+	}
+	if _, err := Set(0, "reqtype", "nosuchoption"); err == nil {
+		t.Error("Set of an unknown option did not fail")
+	}
+	if _, err := SetT(0, "epoch_2000_32"); err == nil {
+		t.Error("SetT of an unknown timestamp did not fail")
+	}
+	if _, err := SetT(0, "epoch2000_32"); err != nil {
+		t.Error("SetT epoch2000_32:", err)
+	}
+}
+
+func title(s string) string {
+	if s == "eod" {
+		return "EOD"
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
+
+// Kept so the benchmarks are not optimised away
+var sink uint32
+
+// What a data frame does with its header, by name
+func BenchmarkStringHeader(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h, _ := Set(0, "version", "v1")
+		h, _ = Set(h, "frametype", "data")
+		h, _ = Set(h, "descriptor", "d32")
+		h, _ = Set(h, "eod", "yes")
+		if GetStr(h, "descriptor") != "d32" || GetStr(h, "reqtstamp") != "no" || GetStr(h, "eod") != "yes" {
+			b.Fatal("wrong header")
+		}
+		sink = h
+	}
+}
+
+// What a data frame does with its header, typed
+func BenchmarkTypedHeader(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := NewDataHeader()
+		h.SetDescriptor(DescriptorD32)
+		h.SetEOD(true)
+		if h.Descriptor() != DescriptorD32 || h.Reqtstamp() || !h.EOD() {
+			b.Fatal("wrong header")
+		}
+		sink = uint32(h)
+	}
+}
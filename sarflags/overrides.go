@@ -0,0 +1,202 @@
+// Overrides - Where the config comes from, highest first
+//	command line flags - given to Load as key paths and values
+//	SARATOGA_* environment variables
+//	the saratoga.json config file
+//	built in defaults
+// SARATOGA_<KEY> sets saratoga.json key <key>, nested keys are joined with _
+// e.g. SARATOGA_UDPPORT=7543 SARATOGA_TIMEOUT_SHUTDOWN=30 SARATOGA_ALIASES_GW=10.0.0.1
+// Numbers, maps and lists are given as JSON, strings as they are
+
+package sarflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Envprefix - Environment variables starting with this override saratoga.json keys
+const Envprefix = "SARATOGA_"
+
+// Defaults - Built in values for the saratoga.json keys a config file leaves out
+var Defaults = map[string]interface{}{
+	"v4multicast": "224.0.0.108",
+	"v6multicast": "ff02::6c",
+	"udpport":     float64(7542),
+	"descriptor":  "d64",
+	"csumtype":    "none",
+	"freespace":   "yes",
+	"txwilling":   "yes",
+	"rxwilling":   "yes",
+	"stream":      "no",
+	"reqtstamp":   "yes",
+	"reqstatus":   "no",
+	"udplite":     "no",
+	"encrypt":     "no",
+	"timestamp":   "posix64",
+	"timezone":    "utc",
+	"sardir":      ".",
+	"prompt":      "saratoga",
+	"ppad":        float64(3),
+	"buffersize":  float64(1024),
+	"bcount":      float64(3),
+	"timeout": map[string]interface{}{
+		"metadata":    float64(55),
+		"request":     float64(56),
+		"status":      float64(57),
+		"transfer":    float64(58),
+		"binterval":   float64(3),
+		"datacounter": float64(100),
+		"peerexpiry":  float64(5),
+		"shutdown":    float64(10),
+	},
+	"acl": map[string]interface{}{
+		"default": "allow",
+	},
+	"auth": map[string]interface{}{
+		"window":   float64(300),
+		"required": "no",
+	},
+}
+
+// Copy the keys of src missing from dst into it, maps in both are merged the same way
+func merge(dst map[string]interface{}, src map[string]interface{}) {
+	for k, sv := range src {
+		dv, ok := dst[k]
+		if !ok {
+			dst[k] = clone(sv)
+			continue
+		}
+		dm, dok := dv.(map[string]interface{})
+		sm, sok := sv.(map[string]interface{})
+		if dok && sok {
+			merge(dm, sm)
+		}
+	}
+}
+
+// A copy of v that changes to do not reach v
+func clone(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, mv := range v {
+			m[k] = clone(mv)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, sv := range v {
+			s[i] = clone(sv)
+		}
+		return s
+	}
+	return v
+}
+
+// Set the keys in conf given by SARATOGA_ variables in environ, as from os.Environ
+// SARDIR is still taken for sardir as it always was, SARATOGA_SARDIR comes before it
+func envoverride(conf map[string]interface{}, environ []string) error {
+	// Sorted so a whole map is set before a key in it
+	vars := append([]string(nil), environ...)
+	sort.Strings(vars)
+	for _, kv := range vars {
+		if v, ok := strings.CutPrefix(kv, "SARDIR="); ok && v != "" {
+			conf["sardir"] = v // Overridden by SARATOGA_SARDIR below
+		}
+	}
+	for _, kv := range vars {
+		name, v, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, Envprefix) || len(name) == len(Envprefix) {
+			continue
+		}
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, Envprefix)), "_")
+		if err := setkey(conf, path, v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Set the keys in conf given on the command line, set is keyed by paths such as timeout.shutdown
+func override(conf map[string]interface{}, set map[string]string) error {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := setkey(conf, strings.Split(k, "."), set[k]); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// Set the key at path in conf to v, maps on the way are made if they are not there
+func setkey(conf map[string]interface{}, path []string, v string) error {
+	m := conf
+	for _, k := range path[:len(path)-1] {
+		if m[k] == nil {
+			m[k] = make(map[string]interface{})
+		}
+		sub, ok := m[k].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not a map of keys", k)
+		}
+		m = sub
+	}
+	key := path[len(path)-1]
+	val, err := envvalue(m[key], v)
+	if err != nil {
+		return err
+	}
+	m[key] = val
+	return nil
+}
+
+// The value s gives a key whose current value is cur, of the same kind as cur
+func envvalue(cur interface{}, s string) (interface{}, error) {
+	switch cur.(type) {
+	case string:
+		return s, nil
+	case float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", s)
+		}
+		return f, nil
+	case map[string]interface{}, []interface{}:
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON %s: %w", s, err)
+		}
+		if kind(v) != kind(cur) {
+			return nil, fmt.Errorf("%s is not a %s", s, kind(cur))
+		}
+		return v, nil
+	}
+	// A key we have no value for, JSON if it is otherwise a string
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v, nil
+	}
+	return s, nil
+}
+
+// What sort of JSON value v is
+func kind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	}
+	return "value"
+}
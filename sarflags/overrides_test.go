@@ -0,0 +1,69 @@
+package sarflags
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	fname := "../saratoga/saratoga.json"
+	t.Setenv("SARDIR", "/from/sardir")
+	t.Setenv("SARATOGA_UDPPORT", "7543")
+	t.Setenv("SARATOGA_TIMEOUT_SHUTDOWN", "30")
+	t.Setenv("SARATOGA_ALIASES_GW", "10.0.0.1")
+	t.Setenv("SARATOGA_ACL", `{"default":"deny"}`)
+	t.Setenv("SARATOGA_ACL_RULES", `[{"peer":"10.0.0.0/8","ops":["get"]}]`)
+	var c Cliflags
+	if err := c.ReadConfig(fname); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 7543 || c.Timeout.Shutdown != 30 || c.Timeout.Request != 56 {
+		t.Errorf("port %d shutdown %d request %d", c.Port, c.Timeout.Shutdown, c.Timeout.Request)
+	}
+	if c.Aliases["gw"] != "10.0.0.1" || c.Aliases["localhost"] != "127.0.0.1" {
+		t.Errorf("aliases %v", c.Aliases)
+	}
+	if c.Acldefault != "deny" || len(c.Acl) != 1 || c.Acl[0].Peer != "10.0.0.0/8" {
+		t.Errorf("acl %s %v", c.Acldefault, c.Acl)
+	}
+	if c.Sardir != "/from/sardir" {
+		t.Errorf("sardir %s want SARDIR", c.Sardir)
+	}
+	t.Setenv("SARATOGA_SARDIR", "/from/saratoga_sardir")
+	if err := c.ReadConfig(fname); err != nil || c.Sardir != "/from/saratoga_sardir" {
+		t.Errorf("sardir %s %v want SARATOGA_SARDIR", c.Sardir, err)
+	}
+
+	// Values of the wrong kind are errors naming the variable
+	t.Setenv("SARATOGA_UDPPORT", "seven")
+	if err := c.ReadConfig(fname); err == nil {
+		t.Error("udpport of seven read")
+	}
+	t.Setenv("SARATOGA_UDPPORT", "7543")
+	t.Setenv("SARATOGA_TIMEOUT", "[]")
+	if err := c.ReadConfig(fname); err == nil {
+		t.Error("timeout of [] read")
+	}
+	os.Unsetenv("SARATOGA_TIMEOUT")
+
+	// Keys the file leaves out get the defaults
+	m := map[string]interface{}{"udpport": float64(1), "timeout": map[string]interface{}{"status": float64(2)}}
+	merge(m, Defaults)
+	if m["udpport"] != float64(1) || m["sardir"] != "." {
+		t.Errorf("merged %v", m)
+	}
+	timeout := m["timeout"].(map[string]interface{})
+	if timeout["status"] != float64(2) || timeout["shutdown"] != float64(10) {
+		t.Errorf("merged timeout %v", timeout)
+	}
+	timeout["shutdown"] = float64(99)
+	if Defaults["timeout"].(map[string]interface{})["shutdown"] != float64(10) {
+		t.Error("changing the merged config changed the defaults")
+	}
+
+	// Missing files name the file not os.Args
+	if err := c.ReadConfig("nosuch.json"); err == nil || !strings.Contains(err.Error(), "nosuch.json") {
+		t.Errorf("missing file error %v", err)
+	}
+}
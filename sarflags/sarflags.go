@@ -10,8 +10,11 @@ import (
 	"log"
 	"math"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
+
+	"github.com/charlesetsmith/saratoga/eid"
 )
 
 // Saratoga Sflag Header Field Format - 32 bit unsigned integer (uint32)
@@ -187,21 +190,44 @@ type Timeouts struct {
 	Transfer    int  `json:"transfer"`    // Secs to wait before cancelling transfer when nothing recieved
 	Binterval   uint `json:"binterval"`   // Secs between sending beacon frames
 	Datacounter int  `json:"datacounter"` // How many data frames received before a status is requested
+	Peerexpiry  int  `json:"peerexpiry"`  // Beacon intervals without a beacon before a peer is lost
+	Shutdown    int  `json:"shutdown"`    // Secs to wait on exit for transfers to finish
 }
 
 // GTimeout - timeouts for responses 0 means no timeout
 var GTimeout = Timeouts{}
 
-// Cmds - JSON Config for command usage & help
+// Cmds - Usage & help of a cli command
 type Cmdtype struct {
-	Usage string `json:"usage"`
-	Help  string `json:"help"`
+	Usage string
+	Help  string
+}
+
+// Aclrule - JSON Config access control rule for incoming requests
+// A peer matches the rule when its address is within Peer and it authenticated as Eid
+// An empty Peer or Eid matches any peer
+type Aclrule struct {
+	Peer  string   `json:"peer"`  // IP address or CIDR e.g. 10.1.0.0/16
+	Eid   string   `json:"eid"`   // EID the peer authenticates as with that EIDs key
+	Ops   []string `json:"ops"`   // Operations allowed: get,put,delete,getdir
+	Paths []string `json:"paths"` // Path prefixes within sardir allowed, none is any path
+}
+
+// Authinfo - JSON Config pre-shared key authentication of requests
+type Authinfo struct {
+	Keyfile  string `json:"keyfile"`  // File holding the per peer keys, relative to the config file until Check
+	Window   int    `json:"window"`   // Secs either side of now a request timestamp is accepted
+	Required string `json:"required"` // Must every request be authenticated: yes,no
 }
 
-// Global map of commands
-var Commands map[string]Cmdtype
+// Quotainfo - JSON Config space checks for incoming files, all sizes in MB
+type Quotainfo struct {
+	Reserve uint64            `json:"reserve"` // Space always kept free on the sardir file system
+	Default uint64            `json:"default"` // Per peer quota for peers not listed, 0 is unlimited
+	Peers   map[string]uint64 `json:"peers"`   // Quota for a peer IP address or EID, 0 is unlimited
+}
 
-// Flag Information
+// Flag Information, the frame header flags are in flagtab
 type Flagtype struct {
 	Frametypes []string          // What Frametypes are applicable to this Flag
 	Len        uint32            // Bit Length of the flag within the header
@@ -209,41 +235,32 @@ type Flagtype struct {
 	Options    map[string]uint32 // What are the Options for the Flag
 }
 
-// Global map of flag decode info
-// These DO NOT Change
-var Flags map[string]Flagtype
-
-// DirentFlag Information
+// DirentFlag Information, the directory entry flags are in direntflagtab
 type DirentFlagtype struct {
 	Len     uint16            // Bit Length of the flag within the header
 	Msb     uint16            // Mosost significant bit within the header
 	Options map[string]uint16 // What are the Options for the DateFlag
 }
 
-// Global map of directory decode info
-// These DO NOT Change
-var DirentFlags map[string]DirentFlagtype
-
-// TimeStamp Information
+// TimeStamp Information, the timestamp types are in tstamptab
 type TimeStamptype struct {
 	Len     uint8
 	Msb     uint8
 	Options map[string]uint8
 }
 
-// Global var of time decode info
-// These DO NOT Change
-var TimeStamps TimeStamptype
-
-// Global map of what flags are applicable to which frame types
-// These DO NOT Change
-var Frameflags map[string][]string
+// Aclinfo - JSON Config access control of incoming requests
+type Aclinfo struct {
+	Default string    `json:"default"` // What to do with requests from peers matching no rule: allow,deny
+	Rules   []Aclrule `json:"rules"`   // The acl rules
+}
 
 // Config - JSON Config Default Global Settings & Commands
+// Keys are decoded by their json tags, keys with no field are warned of
 type config struct {
 	V4multicast string   `json:"v4multicast"` // IPv4 Muluticast address
 	V6multicast string   `json:"v6multicast"` // IPv6 Multicast address
-	Port        int      `json:"port"`        // Deefault Saratoga Port to listen and send on
+	Port        int      `json:"udpport"`     // Deefault Saratoga Port to listen and send on
 	Descriptor  string   `json:"descriptor"`  // Default Descriptor: d16,d32,d64
 	Csumtype    string   `json:"csumtype"`    // Default Checksum type: none
 	Freespace   string   `json:"freespace"`   // Is freespace tp be advertised: yes,no
@@ -253,6 +270,7 @@ type config struct {
 	Reqtstamp   string   `json:"reqtstamp"`   // Request timestamps: yes,no
 	Reqstatus   string   `json:"reqstatus"`   // Request status frame to be sent/received: yes,no
 	Udplite     string   `json:"udplite"`     // Is UDP Lite supported: yes,no
+	Encrypt     string   `json:"encrypt"`     // Encrypt transfers and refuse unencrypted requests: yes,no
 	Timestamp   string   `json:"timestamp"`   // What is the default timestamp format: anything for local,posix32,posix32_323,posix64,posix64_32,epoch2000_32,
 	Timezone    string   `json:"timezone"`    // What timezone is to be used in timestamps: utc
 	Sardir      string   `json:"sardir"`      // What is the default directory for saratoga files
@@ -260,7 +278,15 @@ type config struct {
 	Ppad        int      `json:"ppad"`        // Padding length in prompt for []:
 	Buffersize  int      `json:"buffersize"`  // Size in bytes of fileio read and write buffers
 	Bcount      uint     `json:"bcount"`      // Default number of beacon frames to send
-	Timeout     Timeouts // Various Timers
+	Eid         string   `json:"eid"`         // Our node identity dtn://node/service or ipn:node.service, "" is dtn://<hostname>/saratoga
+	Timeout     Timeouts `json:"timeout"`     // Various Timers
+
+	Aliases map[string]string `json:"aliases"` // Names for peers given as an EID or IP address
+
+	// Access control of incoming requests
+	Acl   Aclinfo   `json:"acl"`   // Policy for peers matching no rule and the rules
+	Auth  Authinfo  `json:"auth"`  // Authentication of incoming requests
+	Quota Quotainfo `json:"quota"` // Space checks for incoming files
 }
 
 // Climu - Protect CLI input flags
@@ -282,6 +308,15 @@ type Cliflags struct {
 	Sardir     string // Saratoga working directory
 	Buffersize int    // Size in bytes of file read/write buffer
 	Bcount     uint   // Default # of Beaeacon frames to send
+	Eid        string // Our node EID sent in beacons
+	// Names usable wherever a peer is given, the value is an EID or IP address
+	Aliases map[string]string
+	// Access control of incoming requests
+	Acldefault string    // Policy for peers not matching any Acl rule: allow,deny
+	Acl        []Aclrule // Access control rules
+	Auth       Authinfo  // Request authentication
+	Quota      Quotainfo // Freespace reserve and per peer quotas
+	Warnings   []string  // Keys in the config file we do not use, most likely typos
 }
 
 // Glabal Variable holding the Command line interface flags
@@ -319,209 +354,75 @@ func getmaxdesc() (desc string, err error) {
 
 // Read  in the JSON Config data
 // Set values to the filled out Cliflags structure in *Cliflags
+// SARATOGA_ environment variables override the file, see overrides.go
 func (c *Cliflags) ReadConfig(fname string) error {
-	var err error
+	return c.Load(fname, nil)
+}
 
-	// var cmu sync.Mutex
-	Flags = make(map[string]Flagtype)             // Setup the Flags global map
-	Frameflags = make(map[string][]string)        // Setup Frameflags global map
-	DirentFlags = make(map[string]DirentFlagtype) // Setup Direntflags global map
-	Commands = make(map[string]Cmdtype)           // Setup Commands global map
+// Load - Read in the JSON Config data with the keys in set overriding it
+// set is keyed by paths such as timeout.shutdown, as given on the command line
+// Every key is checked, the error is a ConfigError for each that is wrong
+// Keys we do not use are left in Warnings
+func (c *Cliflags) Load(fname string, set map[string]string) error {
+	confdata, err := os.ReadFile(fname)
+	if err != nil {
+		return fmt.Errorf("cannot open the saratoga config file %s: %w", fname, err)
+	}
 
-	// Find the maximum descriptor on this platform
-	if MaxDescriptor, err = getmaxdesc(); err != nil {
+	var sarconfdata map[string]interface{}
+	if err = json.Unmarshal(confdata, &sarconfdata); err != nil {
+		return fmt.Errorf("cannot unmarshal json from saratoga config file %s: %w", fname, err)
+	}
+	// The file comes before our defaults, the environment before the file and set before all
+	merge(sarconfdata, Defaults)
+	if err = envoverride(sarconfdata, os.Environ()); err != nil {
 		return err
 	}
-
-	var confdata []byte
-	if confdata, err = os.ReadFile(fname); err != nil {
-		fmt.Println("Cannot open the saratoga config file", os.Args[1], ":", err)
+	if err = override(sarconfdata, set); err != nil {
 		return err
 	}
+	return c.load(fname, sarconfdata)
+}
 
-	var sarconfdata map[string]interface{}
-	if err = json.Unmarshal([]byte(confdata), &sarconfdata); err != nil {
-		fmt.Println("Cannot Unmarshal json from saratoga config file", os.Args[1], ":", err)
+// New - Cliflags from the built in defaults alone, for tests and tools without a config file
+func New() *Cliflags {
+	c := new(Cliflags)
+	if err := c.load("defaults", clone(Defaults).(map[string]interface{})); err != nil {
+		log.Fatalln("Invalid built in defaults:", err)
+	}
+	return c
+}
+
+// Set the Cliflags from the config keys in sarconfdata, fname says where they came from
+func (c *Cliflags) load(fname string, sarconfdata map[string]interface{}) error {
+	var err error
+
+	// Find the maximum descriptor on this platform
+	if MaxDescriptor, err = getmaxdesc(); err != nil {
 		return err
 	}
-	// Lock them up while we are changing the values
-	Climu.Lock()
-	defer Climu.Unlock()
+	nocomments(sarconfdata)
+
 	// Now decode all of those variables, arrays & maps in the json into the config struct's
 	var conf config
-
-	for key, value := range sarconfdata {
-		// fmt.Println(key, "=", value)
-		switch key {
-		case "v4multicast":
-			conf.V4multicast = value.(string)
-		case "v6multicast":
-			conf.V6multicast = value.(string)
-		case "udpport":
-			conf.Port = int(value.(float64))
-		case "descriptor":
-			conf.Descriptor = value.(string)
-		case "csumtype":
-			conf.Csumtype = value.(string)
-		case "freespace":
-			conf.Freespace = value.(string)
-		case "txwilling":
-			conf.Txwilling = value.(string)
-		case "rxwilling":
-			conf.Rxwilling = value.(string)
-		case "stream":
-			conf.Stream = value.(string)
-		case "reqtstamp":
-			conf.Reqtstamp = value.(string)
-		case "reqstatus":
-			conf.Reqstatus = value.(string)
-		case "udplite":
-			conf.Udplite = value.(string)
-		case "timestamp":
-			conf.Timestamp = value.(string)
-		case "timezone":
-			conf.Timezone = value.(string)
-		case "sardir":
-			conf.Sardir = value.(string)
-		case "prompt":
-			conf.Prompt = value.(string)
-		case "ppad":
-			conf.Ppad = int(value.(float64))
-		case "buffersize":
-			conf.Buffersize = int(value.(float64))
-		case "bcount":
-			conf.Bcount = uint(value.(float64))
-		case "timeout": // This is a map in json so copy it to the Timeout structure vars
-			// fmt.Println(key, "=", value)
-			timers := value.(map[string]interface{})
-			for keyt, valuet := range timers {
-				// fmt.Println("  keyt=", keyt, "= valuet=", valuet)
-				switch keyt {
-				case "metadata": // If we dont receive a metadata then send status
-					conf.Timeout.Metadata = int(valuet.(float64))
-				case "request": // If we dont receive a metadata then send status
-					conf.Timeout.Request = int(valuet.(float64))
-				case "status": // Request a status every n seconds
-					conf.Timeout.Status = int(valuet.(float64))
-				case "transfer":
-					conf.Timeout.Transfer = int(valuet.(float64))
-				case "binterval":
-					conf.Timeout.Binterval = uint(valuet.(float64))
-				case "datacounter": // Default number of data frames before a status is requested
-					conf.Timeout.Datacounter = int(valuet.(float64))
-				}
-			}
-		case "commands": //This is a map in json so copy it to the Commands array
-			cmds := value.(map[string]interface{})
-			for cmd, value := range cmds {
-				var ct Cmdtype
-				info := value.(map[string]interface{})
-				for keyx, valx := range info {
-					switch keyx {
-					case "help":
-						ct.Help = valx.(string)
-					case "usage":
-						ct.Usage = valx.(string)
-					}
-				}
-				Commands[cmd] = ct
-			}
-			// for _, v := range conf.Commands {
-			// fmt.Println(v.Cmd, " | ", v.Usage, " | ", v.Help)
-			// }
-		case "frameflags": // Map of what Flags are applicable to what Frame types
-			frameflags := value.(map[string]interface{})
-			for key, val := range frameflags {
-				// fmt.Println("THE KEY IS:", key, "THE VAL IS ", val)
-				info := val.([]interface{})
-				for _, v := range info {
-					Frameflags[key] = append(Frameflags[key], v.(string))
-				}
-			}
-			//for k, r := range conf.Frameflags {
-			//	fmt.Println(k, "=", r)
-			//}
-		case "flags": // The flags within the header
-			flags := value.(map[string]interface{})
-			// Flags := make(map[string][]Flagtype)
-			// conf.Flaginfo = make(map[string][]Flagtype)
-			for key, val := range flags {
-				var tmp Flagtype
-				// fmt.Println("FLAG=", key, "INFO=", val)
-				info := val.(map[string]interface{})
-				for ikey, ival := range info {
-					switch ikey {
-					case "frametypes":
-						ftinfo := ival.([]interface{})
-						for _, v := range ftinfo {
-							tmp.Frametypes = append(tmp.Frametypes, v.(string))
-						}
-						// fmt.Println("Frametypes for", key, "=", tmp.Frametypes)
-					case "len":
-						tmp.Len = uint32(ival.(float64))
-					case "msb":
-						tmp.Msb = uint32(ival.(float64))
-					case "options":
-						oinfo := ival.(map[string]interface{})
-						tmp.Options = make(map[string]uint32)
-						// fmt.Println("Options for", key, "=", oinfo)
-						for okey, oval := range oinfo {
-							tmp.Options[okey] = uint32(oval.(float64))
-						}
-						// fmt.Println("Options for", key, tmp.Options)
-					}
-				}
-				Flags[key] = tmp
-				// conf.Flaginfo[key] = append(conf.Flaginfo[key], tmp)
-			}
-			// for f, v := range Flags {
-			// fmt.Println("FLAG=", f, v)
-			//	fmt.Println(f, "=", v.Options)
-			// }
-		case "direntflags": // Now this is the HARD ONE!!!!!
-			direntflags := value.(map[string]interface{})
-			for dkey, val := range direntflags {
-				var tmp DirentFlagtype
-				// fmt.Println("FLAG=", key, "INFO=", val)
-				dainfo := val.(map[string]interface{})
-				for dikey, info := range dainfo {
-					switch dikey {
-					case "len":
-						tmp.Len = uint16(info.(float64))
-					case "msb":
-						tmp.Msb = uint16(info.(float64))
-					case "options":
-						oinfo := info.(map[string]interface{})
-						tmp.Options = make(map[string]uint16)
-						// fmt.Println("DateFlag Options for", key, "=", oinfo)
-						for okey, oval := range oinfo {
-							tmp.Options[okey] = uint16(oval.(float64))
-						}
-					}
-				}
-				DirentFlags[dkey] = tmp
-			}
-		case "timestamps":
-			timestamps := value
-			// timestamps := value.(interface{})
-			info := timestamps.(map[string]interface{})
-			for ikey, ival := range info {
-				switch ikey {
-				case "len":
-					TimeStamps.Len = uint8(ival.(float64))
-				case "msb":
-					TimeStamps.Msb = uint8(ival.(float64))
-				case "options":
-					oinfo := ival.(map[string]interface{})
-					TimeStamps.Options = make(map[string]uint8)
-					for okey, oval := range oinfo {
-						TimeStamps.Options[okey] = uint8(oval.(float64))
-					}
-				}
-			}
+	c.Warnings = nil
+	// The protocol tables and command help were in the config file, they are built in now
+	for _, k := range []string{"commands", "frameflags", "flags", "direntflags", "timestamps"} {
+		if _, ok := sarconfdata[k]; ok {
+			delete(sarconfdata, k)
+			c.Warnings = append(c.Warnings, k+" is built in, the key is ignored")
 		}
-
 	}
+	for _, k := range unknownkeys("", sarconfdata, reflect.TypeOf(conf)) {
+		c.Warnings = append(c.Warnings, "unknown key "+k)
+	}
+	if err = decode(fname, sarconfdata, &conf); err != nil {
+		return err
+	}
+
+	// Lock them up while we are changing the values
+	Climu.Lock()
+	defer Climu.Unlock()
 
 	// Give default values to flags from saratoga JSON config
 	c.Global = make(map[string]string, 10)
@@ -533,48 +434,46 @@ func (c *Cliflags) ReadConfig(fname string) error {
 	c.Global["reqtstamp"] = conf.Reqtstamp
 	c.Global["reqstatus"] = conf.Reqstatus
 	c.Global["udplite"] = conf.Udplite
+	c.Global["encrypt"] = conf.Encrypt
 	c.Global["descriptor"] = conf.Descriptor
-	c.V4Multicast = conf.V4multicast                 // The v4 Multicast address
-	c.V6Multicast = conf.V6multicast                 // THe v6 Multicast address
-	c.Port = conf.Port                               // The Saratoga Port #
-	c.Buffersize = conf.Buffersize                   // Buffersize for file i/o
-	c.Timestamp = conf.Timestamp                     // Default timestamp type to use
-	c.Bcount = conf.Bcount                           // Default number of Beacons to send
-	c.Timeout.Metadata = conf.Timeout.Metadata       // Seconds
-	c.Timeout.Request = conf.Timeout.Request         // Seconds
-	c.Timeout.Status = conf.Timeout.Status           // Seconds
-	c.Timeout.Transfer = conf.Timeout.Transfer       // Seconds
-	c.Timeout.Binterval = conf.Timeout.Binterval     // Seconds between beacons
-	c.Timeout.Datacounter = conf.Timeout.Datacounter // # Data frames between request for status
-	c.Timezone = conf.Timezone                       // TImezone to use for logs
-	c.Prompt = conf.Prompt                           // Prompt Prefix in cmd
-	c.Ppad = conf.Ppad                               // For []: in prompt = 3
-
-	// Get the default directory for sarotaga transfers from environment
-	// We default to what is in the environment variable otherwise what is in saratoga.json
-	if c.Sardir = os.Getenv("SARDIR"); c.Sardir == "" {
-		c.Sardir = conf.Sardir // If no env variable set then set it to conf file value
-	}
-
-	for f, v := range c.Global {
-		if !Valid(f, c.Global[f]) {
-			ps := "Invalid Flag:" + f + "=" + v
-			return errors.New(ps)
-		}
-	}
-	return nil
+	c.V4Multicast = conf.V4multicast // The v4 Multicast address
+	c.V6Multicast = conf.V6multicast // THe v6 Multicast address
+	c.Port = conf.Port               // The Saratoga Port #
+	c.Buffersize = conf.Buffersize   // Buffersize for file i/o
+	c.Timestamp = conf.Timestamp     // Default timestamp type to use
+	c.Bcount = conf.Bcount           // Default number of Beacons to send
+	c.Timeout = conf.Timeout         // Seconds and counters
+	c.Timezone = conf.Timezone       // TImezone to use for logs
+	c.Prompt = conf.Prompt           // Prompt Prefix in cmd
+	c.Ppad = conf.Ppad               // For []: in prompt = 3
+	c.Acldefault = conf.Acl.Default  // Policy when no acl rule matches
+	c.Acl = conf.Acl.Rules           // Access control rules
+	c.Auth = conf.Auth               // Request authentication
+	c.Quota = conf.Quota             // Freespace reserve and per peer quotas
+	c.Aliases = conf.Aliases         // Peer aliases
+	c.Sardir = conf.Sardir           // The environment has already had its say
+	if c.Aliases == nil {
+		c.Aliases = make(map[string]string)
+	}
+
+	// Our EID must be stable across restarts so default to one from the hostname
+	if c.Eid = conf.Eid; c.Eid == "" {
+		c.Eid = eid.Default(c.Port)
+	}
+	return validate(fname, &conf)
 }
 
 // Valid - Check for valid flag and value
 func Valid(flag string, option string) bool {
 	if Good(flag) {
-		_, ok := Flags[flag].Options[option]
+		_, ok := flagtab[flag].Options[option]
 		return ok
 	}
 	return false
 }
 
 // Add - CHeck for and Add a flag to a current list of flags
+// An invalid flag or option is not added and the current list is returned
 func AddFlag(curflag string, flag string, option string) string {
 	var newflags string
 	if Valid(flag, option) {
@@ -585,7 +484,6 @@ func AddFlag(curflag string, flag string, option string) string {
 		}
 		return newflags
 	}
-	log.Fatalln("Invalid Flag:", flag, "or option:", option)
 	return curflag
 }
 
@@ -638,6 +536,11 @@ func (s *Cliflags) CopyCliflags() (*Cliflags, error) {
 	d.Ppad = s.Ppad
 	d.Sardir = s.Sardir
 	d.Buffersize = s.Buffersize
+	d.Eid = s.Eid
+	d.Aliases = make(map[string]string, len(s.Aliases))
+	for a, p := range s.Aliases {
+		d.Aliases[a] = p
+	}
 	// Copy the various Timeouts
 	d.Timeout.Binterval = s.Timeout.Binterval
 	d.Timeout.Metadata = s.Timeout.Metadata
@@ -645,6 +548,16 @@ func (s *Cliflags) CopyCliflags() (*Cliflags, error) {
 	d.Timeout.Status = s.Timeout.Status
 	d.Timeout.Transfer = s.Timeout.Transfer
 	d.Timeout.Datacounter = s.Timeout.Datacounter
+	d.Timeout.Peerexpiry = s.Timeout.Peerexpiry
+	// Copy the access control rules
+	d.Acldefault = s.Acldefault
+	d.Acl = append([]Aclrule(nil), s.Acl...)
+	d.Auth = s.Auth
+	d.Quota = s.Quota
+	d.Quota.Peers = make(map[string]uint64, len(s.Quota.Peers))
+	for p, v := range s.Quota.Peers {
+		d.Quota.Peers[p] = v
+	}
 	// Copy the Global flag defaults
 	if len(s.Global) == 0 {
 		return nil, errors.New("no global flags defined in copyflags")
@@ -658,12 +571,12 @@ func (s *Cliflags) CopyCliflags() (*Cliflags, error) {
 
 // Values - Return slice of flags applicable to frame type (field)
 func Values(ftype string) []string {
-	return Frameflags[ftype]
+	return append([]string(nil), frameflagtab[ftype]...)
 }
 
 // Value - Return the integer value of the flag option
 func Value(flag string, option string) int {
-	opt, ok := Flags[flag].Options[option]
+	opt, ok := flagtab[flag].Options[option]
 	if !ok {
 		return -1
 	}
@@ -672,7 +585,7 @@ func Value(flag string, option string) int {
 
 // Get - Given a current flag and bitfield name return the integer value of the bitfield
 func Get(curflag uint32, field string) uint32 {
-	fl := Flags[field]
+	fl := flagtab[field]
 	shiftbits := uint32(flagsize - fl.Len - fl.Msb)
 	maskbits := uint32((1 << fl.Len) - 1)
 	setbits := uint32(maskbits << shiftbits)
@@ -681,37 +594,41 @@ func Get(curflag uint32, field string) uint32 {
 
 // GetStr - Given a current flag and bitfield name return the string name of the bitfield set in curflag
 func GetStr(curflag uint32, field string) string {
-	ff := Get(curflag, field)
-	for k, f := range Flags[field].Options {
-		// fmt.Printf("GetStr Curflag %0x Looking for %x val in %x=%s\n", curflag, val, f, k)
-		if ff == f {
-			return k
+	if names := flagnames[field]; names != nil {
+		if name := names[Get(curflag, field)]; name != "" {
+			return name
 		}
 	}
-	log.Fatalln("GetStr fail Invalid field", field, "in Flag", curflag)
-	return ""
+	return unnamed(field, uint64(Get(curflag, field)))
+}
+
+// unnamed - the name given to a field value that has no flag name, e.g. reqtype(7)
+func unnamed(field string, val uint64) string {
+	return fmt.Sprintf("%s(%d)", field, val)
+}
+
+// Unnamed - true if name is not a flag name but the value of an unnamed field
+func Unnamed(name string) bool {
+	return strings.HasSuffix(name, ")")
 }
 
 // Set - Given a current header and bitfield name with a new value return the revised header
 // If invalid return the current flag and error
 func Set(curflag uint32, field string, flagname string) (uint32, error) {
-	fl, ok := Flags[field]
+	fl, ok := flagtab[field]
 	if !ok {
-		log.Fatalln("Set lookup fail Invalid Flag", field)
 		e := "invalid Flag: " + field
 		return curflag, errors.New(e)
 	}
 
 	if !Good(field) {
 		e := "Set - Invalid Field:" + field + ":"
-		log.Fatalln(e)
 		return curflag, errors.New(e)
 	}
 	// Get the value of the flag
-	newval, ok := Flags[field].Options[flagname]
+	newval, ok := flagtab[field].Options[flagname]
 	if !ok {
-		e := "Set lookup fail Invalid flagname" + flagname + "in DFlag" + field
-		log.Fatalln(e)
+		e := "Set lookup fail Invalid flagname " + flagname + " in Flag " + field
 		return curflag, errors.New(e)
 	}
 
@@ -741,7 +658,7 @@ func Test(curflag uint32, field, string, flagname string) bool {
 
 // Name - return the name of the flag for field in curflag
 func Name(curflag uint32, field string) string {
-	fl := Flags[field]
+	fl := flagtab[field]
 	x := Get(curflag, field)
 	for k, f := range fl.Options {
 		// log.Println("Flags for field ", field, fi.name, fi.val)
@@ -749,8 +666,7 @@ func Name(curflag uint32, field string) string {
 			return k
 		}
 	}
-	log.Fatalln("Name out of range")
-	return ""
+	return unnamed(field, uint64(x))
 }
 
 // Fields - return a slice of flag fields that are used by frametype
@@ -760,7 +676,7 @@ func Fields(frametype string) []string {
 
 // Good - Is this a valid flagname
 func Good(field string) bool {
-	_, ok := Flags[field]
+	_, ok := flagtab[field]
 	return ok
 }
 
@@ -790,8 +706,8 @@ func GetD(curflag uint16, field string) uint16 {
 
 	var shiftbits, maskbits, setbits uint16
 
-	shiftbits = dflagsize - DirentFlags[field].Len - DirentFlags[field].Msb
-	maskbits = (1 << DirentFlags[field].Len) - 1
+	shiftbits = dflagsize - direntflagtab[field].Len - direntflagtab[field].Msb
+	maskbits = (1 << direntflagtab[field].Len) - 1
 	setbits = maskbits << shiftbits
 	return (curflag & setbits) >> shiftbits
 }
@@ -800,17 +716,16 @@ func GetD(curflag uint16, field string) uint16 {
 func GetDStr(curflag uint16, field string) string {
 	var shiftbits, maskbits, setbits, val uint16
 
-	shiftbits = dflagsize - DirentFlags[field].Len - DirentFlags[field].Msb
-	maskbits = (1 << DirentFlags[field].Len) - 1
+	shiftbits = dflagsize - direntflagtab[field].Len - direntflagtab[field].Msb
+	maskbits = (1 << direntflagtab[field].Len) - 1
 	setbits = maskbits << shiftbits
 	val = (curflag & setbits) >> shiftbits
-	for ki, fi := range DirentFlags[field].Options {
+	for ki, fi := range direntflagtab[field].Options {
 		if fi == val {
 			return ki
 		}
 	}
-	log.Fatalln("GetDStr fail Invalid field", field, "in DFlag:", curflag)
-	return ""
+	return unnamed(field, uint64(val))
 }
 
 // SetD - Given a current header and bitfield name with a new value return the revised header
@@ -818,19 +733,17 @@ func SetD(curflag uint16, field string, flagname string) (uint16, error) {
 
 	if !GoodD(field) {
 		e := "Invalid Date Field:" + field + ":"
-		log.Fatalln(e)
 		return curflag, errors.New(e)
 	}
 	// Get the value of the flag
-	newval, ok := DirentFlags[field].Options[flagname]
+	newval, ok := direntflagtab[field].Options[flagname]
 	if !ok {
-		e := "SetD lookup fail Invalid flagname" + flagname + "in DFlag" + field
-		log.Fatalln(e)
+		e := "SetD lookup fail Invalid flagname " + flagname + " in DFlag " + field
 		return curflag, errors.New(e)
 	}
 
-	shiftbits := uint16(dflagsize - DirentFlags[field].Len - DirentFlags[field].Msb)
-	maskbits := uint16((1 << DirentFlags[field].Len) - 1)
+	shiftbits := uint16(dflagsize - direntflagtab[field].Len - direntflagtab[field].Msb)
+	maskbits := uint16((1 << direntflagtab[field].Len) - 1)
 	setbits := uint16(maskbits << shiftbits)
 	// log.Printf("Shiftbits=%d Maskbits=%b Setbits=%b\n", shiftbits, maskbits, setbits)
 	result := uint16(((curflag) & (^setbits)))
@@ -842,7 +755,7 @@ func SetD(curflag uint16, field string, flagname string) (uint16, error) {
 // FrameD - return a slice of flag names matching field
 func FrameD(field string) []string {
 	var s []string
-	for k := range DirentFlags[field].Options {
+	for k := range direntflagtab[field].Options {
 		s = append(s, k)
 	}
 	return s
@@ -851,7 +764,7 @@ func FrameD(field string) []string {
 // FlagD - return a slice of flag names that are used by Dirent
 func FlagD() []string {
 	var s []string
-	for k := range DirentFlags {
+	for k := range direntflagtab {
 		s = append(s, k)
 	}
 	return s
@@ -867,32 +780,32 @@ func TestD(curdflag uint16, field string, flagname string) bool {
 func NameD(curdflag uint16, field string) string {
 
 	x := GetD(curdflag, field)
-	for ki, fi := range DirentFlags[field].Options {
+	for ki, fi := range direntflagtab[field].Options {
 		// log.Println("Flags for field ", field, fi.name, fi.val)
 		if fi == x {
 			return ki
 		}
 	}
-	log.Fatalln("NameD out of range")
-	return ""
+	return unnamed(field, uint64(x))
 }
 
 // GoodD -- Is this a valid Descriptor Flag
 func GoodD(field string) bool {
-	_, ok := DirentFlags[field]
+	_, ok := direntflagtab[field]
 	return ok
 }
 
 // Valid - Check for valid flag and value
 func ValidD(flag string, option string) bool {
 	if GoodD(flag) {
-		_, ok := DirentFlags[flag].Options[option]
+		_, ok := direntflagtab[flag].Options[option]
 		return ok
 	}
 	return false
 }
 
 // Add - CHeck for and Add a flag to a current list of flags
+// An invalid flag or option is not added and the current list is returned
 func AddFlagD(curflag string, flag string, option string) string {
 	var newflags string
 	if ValidD(flag, option) {
@@ -903,7 +816,6 @@ func AddFlagD(curflag string, flag string, option string) string {
 		}
 		return newflags
 	}
-	log.Fatalln("Invalid Directory Flag:", flag, "or option:", option)
 	return curflag
 }
 
@@ -935,8 +847,8 @@ const tflagsize uint8 = 8
 func GetT(curflag uint8) uint8 {
 	var shiftbits, maskbits, setbits uint8
 
-	shiftbits = tflagsize - TimeStamps.Len - TimeStamps.Msb
-	maskbits = (1 << TimeStamps.Len) - 1
+	shiftbits = tflagsize - tstamptab.Len - tstamptab.Msb
+	maskbits = (1 << tstamptab.Len) - 1
 	setbits = maskbits << shiftbits
 	return (curflag & setbits) >> shiftbits
 }
@@ -945,31 +857,29 @@ func GetT(curflag uint8) uint8 {
 func GetTStr(curflag uint8) string {
 	var shiftbits, maskbits, setbits, val uint8
 
-	shiftbits = tflagsize - TimeStamps.Len - TimeStamps.Msb
-	maskbits = (1 << TimeStamps.Len) - 1
+	shiftbits = tflagsize - tstamptab.Len - tstamptab.Msb
+	maskbits = (1 << tstamptab.Len) - 1
 	setbits = maskbits << shiftbits
 	val = (curflag & setbits) >> shiftbits
-	for ki, fi := range TimeStamps.Options {
+	for ki, fi := range tstamptab.Options {
 		if fi == val {
 			return ki
 		}
 	}
-	log.Fatalln("GetTStr fail Invalid Tflag:", curflag)
-	return ""
+	return unnamed("timestamp", uint64(val))
 }
 
 // SetT Given a current header and bitfield name with a new value return the revised header
 func SetT(curflag uint8, flagname string) (uint8, error) {
 
-	newval, found := TimeStamps.Options[flagname]
+	newval, found := tstamptab.Options[flagname]
 	if !found {
-		log.Fatalln("SetT lookup fail Invalid flagname", flagname, "in TFlag")
 		e := "invalid TFlag: " + flagname
 		return curflag, errors.New(e)
 	}
 
-	shiftbits := uint8(tflagsize - TimeStamps.Len - TimeStamps.Msb)
-	maskbits := uint8((1 << TimeStamps.Len) - 1)
+	shiftbits := uint8(tflagsize - tstamptab.Len - tstamptab.Msb)
+	maskbits := uint8((1 << tstamptab.Len) - 1)
 	setbits := maskbits << shiftbits
 	// log.Printf("Shiftbits=%d Maskbits=%b Setbits=%b\n", shiftbits, maskbits, setbits)
 	result := ((curflag) & (^setbits))
@@ -981,20 +891,19 @@ func SetT(curflag uint8, flagname string) (uint8, error) {
 // NameT - return the name of the flag for field in curtflag
 func NameT(curtflag uint8) string {
 	x := GetT(curtflag)
-	for ki, fi := range TimeStamps.Options {
+	for ki, fi := range tstamptab.Options {
 		// log.Println("Flags for field ", field, ki, val)
 		if fi == x {
 			return ki
 		}
 	}
-	log.Fatalln("NameT out of range")
-	return ""
+	return unnamed("timestamp", uint64(x))
 }
 
 // FrameT - return a slice of flag names that are used by Timeinfo
 func FrameT() []string {
 	var s []string
-	for ki := range TimeStamps.Options {
+	for ki := range tstamptab.Options {
 		s = append(s, ki)
 	}
 	return s
@@ -1002,7 +911,7 @@ func FrameT() []string {
 
 // GoodT - Is this a valid time flag
 func GoodT(field string) bool {
-	_, ok := TimeStamps.Options[field]
+	_, ok := tstamptab.Options[field]
 	return ok
 }
 
@@ -0,0 +1,318 @@
+// Tables - The saratoga header bit layouts
+// These are the wire format so are built in rather than read from saratoga.json and DO NOT Change
+// Bit numbers count from the most significant bit of the field, msb 0 is its top bit
+
+package sarflags
+
+// What flags are applicable to which frame types
+var frameflagtab = map[string][]string{
+	"beacon":   {"version", "frametype", "descriptor", "stream", "txwilling", "rxwilling", "udplite", "freespace", "freespaced"},
+	"request":  {"version", "frametype", "descriptor", "stream", "txwilling", "rxwilling", "reqtype", "fileordir", "udplite", "encrypt"},
+	"metadata": {"version", "frametype", "descriptor", "transfer", "progress", "reliability", "csumlen", "csumtype", "encrypt"},
+	"data":     {"version", "frametype", "descriptor", "transfer", "reqtstamp", "reqstatus", "eod", "encrypt"},
+	"status":   {"version", "frametype", "descriptor", "reqtstamp", "metadatarecvd", "allholes", "reqholes", "errcode", "encrypt"},
+}
+
+// Where each flag is in the 32 bit frame header and the values of its options
+var flagtab = map[string]Flagtype{
+	"version": {
+		Frametypes: []string{"beacon", "request", "metadata", "data", "status"},
+		Len:        3,
+		Msb:        0,
+		Options: map[string]uint32{
+			"v0": 0,
+			"v1": 1,
+		},
+	},
+	"frametype": {
+		Frametypes: []string{"beacon", "request", "metadata", "data", "status"},
+		Len:        5,
+		Msb:        3,
+		Options: map[string]uint32{
+			"beacon":   0,
+			"request":  1,
+			"metadata": 2,
+			"data":     3,
+			"status":   4,
+		},
+	},
+	"descriptor": {
+		Frametypes: []string{"beacon", "request", "metadata", "data", "status"},
+		Len:        2,
+		Msb:        8,
+		Options: map[string]uint32{
+			"d16":  0,
+			"d32":  1,
+			"d64":  2,
+			"d128": 3,
+		},
+	},
+	"stream": {
+		Frametypes: []string{"beacon", "request"},
+		Len:        1,
+		Msb:        11,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"transfer": {
+		Frametypes: []string{"metadata", "data"},
+		Len:        2,
+		Msb:        10,
+		Options: map[string]uint32{
+			"file":      0,
+			"directory": 1,
+			"bundle":    2,
+			"stream":    3,
+		},
+	},
+	"reqtstamp": {
+		Frametypes: []string{"data", "status"},
+		Len:        1,
+		Msb:        12,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"progress": {
+		Frametypes: []string{"metadata"},
+		Len:        1,
+		Msb:        12,
+		Options: map[string]uint32{
+			"inprogress": 0,
+			"terminated": 1,
+		},
+	},
+	"txwilling": {
+		Frametypes: []string{"beacon", "request"},
+		Len:        2,
+		Msb:        12,
+		Options: map[string]uint32{
+			"no":      0,
+			"invalid": 1,
+			"capable": 2,
+			"yes":     3,
+		},
+	},
+	"metadatarecvd": {
+		Frametypes: []string{"status"},
+		Len:        1,
+		Msb:        13,
+		Options: map[string]uint32{
+			"yes": 0,
+			"no":  1,
+		},
+	},
+	"allholes": {
+		Frametypes: []string{"status"},
+		Len:        1,
+		Msb:        14,
+		Options: map[string]uint32{
+			"yes": 0,
+			"no":  1,
+		},
+	},
+	"reqtype": {
+		Frametypes: []string{"request"},
+		Len:        8,
+		Msb:        24,
+		Options: map[string]uint32{
+			"noaction": 0,
+			"get":      1,
+			"put":      2,
+			"take":     3,
+			"give":     4,
+			"delete":   5,
+			"getdir":   6,
+		},
+	},
+	"rxwilling": {
+		Frametypes: []string{"beacon", "request"},
+		Len:        2,
+		Msb:        14,
+		Options: map[string]uint32{
+			"no":      0,
+			"invalid": 1,
+			"capable": 2,
+			"yes":     3,
+		},
+	},
+	"reqholes": {
+		Frametypes: []string{"status"},
+		Len:        1,
+		Msb:        15,
+		Options: map[string]uint32{
+			"requested":   0,
+			"voluntarily": 1,
+		},
+	},
+	"reqstatus": {
+		Frametypes: []string{"data"},
+		Len:        1,
+		Msb:        15,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"udplite": {
+		Frametypes: []string{"beacon", "request"},
+		Len:        1,
+		Msb:        16,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"eod": {
+		Frametypes: []string{"data"},
+		Len:        1,
+		Msb:        16,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"freespace": {
+		Frametypes: []string{"beacon"},
+		Len:        1,
+		Msb:        17,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"encrypt": {
+		Frametypes: []string{"request", "metadata", "data", "status"},
+		Len:        1,
+		Msb:        17,
+		Options: map[string]uint32{
+			"no":  0,
+			"yes": 1,
+		},
+	},
+	"freespaced": {
+		Frametypes: []string{"beacon"},
+		Len:        2,
+		Msb:        18,
+		Options: map[string]uint32{
+			"d16":  0,
+			"d32":  1,
+			"d64":  2,
+			"d128": 3,
+		},
+	},
+	"csumlen": {
+		Frametypes: []string{"metadata"},
+		Len:        4,
+		Msb:        24,
+		Options: map[string]uint32{
+			"none":     0,
+			"crc32":    1,
+			"invalid2": 2,
+			"invalid3": 3,
+			"md5":      4,
+			"sha1":     5,
+		},
+	},
+	"csumtype": {
+		Frametypes: []string{"metadata"},
+		Len:        4,
+		Msb:        28,
+		Options: map[string]uint32{
+			"none":  0,
+			"crc32": 1,
+			"md5":   2,
+			"sha1":  3,
+		},
+	},
+	"reliability": {
+		Frametypes: []string{"metadata"},
+		Len:        1,
+		Msb:        13,
+		Options: map[string]uint32{
+			"udponly": 0,
+			"udplite": 1,
+		},
+	},
+	"errcode": {
+		Frametypes: []string{"status"},
+		Len:        8,
+		Msb:        24,
+		Options: map[string]uint32{
+			"success":          0,
+			"unspecified":      1,
+			"cantsend":         2,
+			"cantreceive":      3,
+			"filenotfound":     4,
+			"accessdenied":     5,
+			"unknownid":        6,
+			"didnotdelete":     7,
+			"filetobig":        8,
+			"badoffset":        9,
+			"badpacket":        10,
+			"badrequest":       11,
+			"internaltimeout":  12,
+			"baddataflag":      13,
+			"rxnotinterested":  14,
+			"fileinuse":        15,
+			"metadatarequired": 16,
+			"badstatus":        17,
+			"rxtimeout":        18,
+		},
+	},
+}
+
+// Where each flag is in the 16 bit directory entry properties and the values of its options
+var direntflagtab = map[string]DirentFlagtype{
+	"sod": {
+		Len: 1,
+		Msb: 0,
+		Options: map[string]uint16{
+			"sod": 1,
+		},
+	},
+	"property": {
+		Len: 2,
+		Msb: 6,
+		Options: map[string]uint16{
+			"normalfile":       0,
+			"normaldirectory":  1,
+			"specialfile":      2,
+			"specialdirectory": 3,
+		},
+	},
+	"descriptor": {
+		Len: 2,
+		Msb: 8,
+		Options: map[string]uint16{
+			"d16":  0,
+			"d32":  1,
+			"d64":  2,
+			"d128": 3,
+		},
+	},
+	"reserved": {
+		Len: 1,
+		Msb: 10,
+		Options: map[string]uint16{
+			"reserved": 0,
+		},
+	},
+}
+
+// Where the timestamp type is in the 8 bit timestamp field and the types
+var tstamptab = TimeStamptype{
+	Len: 8,
+	Msb: 0,
+	Options: map[string]uint8{
+		"localinterp":  0,
+		"posix32":      1,
+		"posix64":      2,
+		"posix32_32":   3,
+		"posix64_32":   4,
+		"epoch2000_32": 5,
+	},
+}
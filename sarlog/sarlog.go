@@ -0,0 +1,152 @@
+// Logging - Where the transfer and beacon engines report what they are doing
+// The gocui views, a daemons log file or a test can all be on the other end
+
+package sarlog
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level - How important a log line is
+type Level int
+
+// Levels
+const (
+	Msg    Level = iota // What is going on
+	Err                 // Something went wrong
+	Packet              // Trace of a frame sent or received
+)
+
+// Levels - Names of the levels
+var Levels = map[Level]string{Msg: "msg", Err: "err", Packet: "packet"}
+
+// Field - A named value attached to a log line or event
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F - Shorthand for a Field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Fields - Fields as key=value pairs separated by spaces
+func Fields(fields []Field) string {
+	s := make([]string, 0, len(fields))
+	for _, f := range fields {
+		s = append(s, fmt.Sprintf("%s=%v", f.Key, f.Value))
+	}
+	return strings.Join(s, " ")
+}
+
+// Line - The message and its fields as a single line
+func Line(msg string, fields []Field) string {
+	if len(fields) == 0 {
+		return msg
+	}
+	return msg + " " + Fields(fields)
+}
+
+// Logger - Receives the engines log lines
+type Logger interface {
+	Msg(msg string, fields ...Field)
+	Err(msg string, fields ...Field)
+	Packet(msg string, fields ...Field)
+}
+
+// Event kinds
+const (
+	TransferAdded     = "transfer.added"     // A transfer has started
+	TransferCompleted = "transfer.completed" // The whole file has been sent or received
+	TransferCancelled = "transfer.cancelled" // We cancelled a transfer and told the peer
+	TransferPeerEnded = "transfer.peerended" // The peer ended the transfer
+	TransferRemoved   = "transfer.removed"   // A transfer is no longer listed
+	PeerJoined        = "peer.joined"        // We have heard a beacon from a new peer
+	PeerChanged       = "peer.changed"       // A peers beacon has changed what it tells us
+	PeerLost          = "peer.lost"          // A peer has gone quiet
+)
+
+// Event - Something happened to a transfer or a peer
+type Event struct {
+	Kind   string    // What happened
+	Time   time.Time // When
+	Fields []Field   // Who and what it happened to
+}
+
+// Get - The value of the field key, nil if the event does not have it
+func (e Event) Get(key string) interface{} {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// EventSink - Receives the engines events
+type EventSink interface {
+	Event(e Event)
+}
+
+// Emit - Send an event to l if it is also an EventSink
+func Emit(l Logger, kind string, fields ...Field) {
+	if s, ok := l.(EventSink); ok {
+		s.Event(Event{Kind: kind, Time: time.Now(), Fields: fields})
+	}
+}
+
+// Discard - A Logger that throws everything away
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Msg(string, ...Field)    {}
+func (discard) Err(string, ...Field)    {}
+func (discard) Packet(string, ...Field) {}
+
+// Writer - A Logger writing a timestamped line per log line and event
+// Errors go to Errout, everything else to Out, packet traces only if Packets is set
+type Writer struct {
+	mu      sync.Mutex
+	out     *log.Logger
+	errout  *log.Logger
+	Packets bool
+}
+
+// New - A Writer logging to out and errout
+func New(out io.Writer, errout io.Writer, packets bool) *Writer {
+	return &Writer{out: log.New(out, "", log.LstdFlags), errout: log.New(errout, "", log.LstdFlags), Packets: packets}
+}
+
+func (w *Writer) print(level Level, msg string, fields []Field) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch level {
+	case Err:
+		w.errout.Print(Levels[level], ": ", Line(msg, fields))
+	case Packet:
+		if w.Packets {
+			w.out.Print(Levels[level], ": ", Line(msg, fields))
+		}
+	default:
+		w.out.Print(Levels[level], ": ", Line(msg, fields))
+	}
+}
+
+// Msg - Log what is going on
+func (w *Writer) Msg(msg string, fields ...Field) { w.print(Msg, msg, fields) }
+
+// Err - Log what went wrong
+func (w *Writer) Err(msg string, fields ...Field) { w.print(Err, msg, fields) }
+
+// Packet - Log a frame trace
+func (w *Writer) Packet(msg string, fields ...Field) { w.print(Packet, msg, fields) }
+
+// Event - Log an event as a msg line
+func (w *Writer) Event(e Event) { w.print(Msg, e.Kind, e.Fields) }
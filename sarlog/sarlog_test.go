@@ -0,0 +1,47 @@
+package sarlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var out, errout bytes.Buffer
+	w := New(&out, &errout, false)
+	w.Msg("Sending put request", F("peer", "192.0.2.1:7542"), F("session", 7))
+	w.Err("Access denied", F("file", "secret"))
+	w.Packet("Tx beacon")
+	Emit(w, TransferCancelled, F("session", 7))
+	Emit(Discard, TransferCancelled) // Not an EventSink so nothing happens
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("out %q", out.String())
+	}
+	if !strings.HasSuffix(lines[0], "msg: Sending put request peer=192.0.2.1:7542 session=7") {
+		t.Errorf("msg %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "msg: transfer.cancelled session=7") {
+		t.Errorf("event %q", lines[1])
+	}
+	if !strings.HasSuffix(errout.String(), "err: Access denied file=secret\n") {
+		t.Errorf("err %q", errout.String())
+	}
+
+	w.Packets = true
+	w.Packet("Tx beacon")
+	if !strings.HasSuffix(out.String(), "packet: Tx beacon\n") {
+		t.Errorf("packet %q", out.String())
+	}
+}
+
+func TestEvent(t *testing.T) {
+	e := Event{Kind: PeerLost, Fields: []Field{F("peer", "192.0.2.1"), F("eid", "dtn://a")}}
+	if e.Get("eid") != "dtn://a" || e.Get("session") != nil {
+		t.Errorf("get %v %v", e.Get("eid"), e.Get("session"))
+	}
+	if s := Line("Peer", nil); s != "Peer" {
+		t.Errorf("line %q", s)
+	}
+}
@@ -56,6 +56,17 @@ func UDPAddress(s string) (*net.UDPAddr, error) {
 	return nil, errors.New("Cannot ResolveUDPAddr: " + a)
 }
 
+// LocalAddr - A TCP listen address, a port or :port on its own is on localhost only
+func LocalAddr(addr string) string {
+	if !strings.Contains(addr, ":") { // Just a port
+		addr = ":" + addr
+	}
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	return addr
+}
+
 // UDPinfo - Return string of IP Address and Port #
 func UDPinfo(addr *net.UDPAddr) string {
 	if addr == nil {
@@ -100,9 +111,22 @@ func OutboundIP(typ string) net.IP {
 
 // SetMulticastLoop - Set the Multicast Loopback address OK for Rx Multicasts
 func SetMulticastLoop(conn net.PacketConn) error {
-	file, _ := conn.(*net.UDPConn).File()
-	fd := int(file.Fd())
-	addr := conn.LocalAddr().String()
+	// Set the options through the raw connection, conn.File() would leave the socket blocking
+	// and reads on it would never return when it is closed
+	raw, err := conn.(*net.UDPConn).SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := raw.Control(func(sfd uintptr) {
+		serr = setmulticastloop(int(sfd), conn.LocalAddr().String())
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+func setmulticastloop(fd int, addr string) error {
 	if isIPv4(addr) {
 		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, 1); err != nil {
 			return err
@@ -0,0 +1,170 @@
+// Capture - Output of commands run from somewhere other than the cmd view
+
+package sarwin
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Output - A line a command wrote and the view it was written to
+type Output struct {
+	View string `json:"view"`
+	Text string `json:"text"`
+}
+
+// Capture - Keeps what commands run with its gui write to the views
+type Capture struct {
+	g       *gocui.Gui
+	parent  *gocui.Gui // Where a tee also shows the output
+	up      *Capture   // The capture parent is for, nil if it is the real gui
+	tee     bool
+	mu      sync.Mutex
+	e       *Engine // What the commands run on, nil for whatever up runs on
+	lines   []Output
+	started []*Transfer // Transfers the commands started
+	closed  bool
+}
+
+var capmu sync.Mutex
+var captures = make(map[*gocui.Gui]*Capture)
+
+// NewCapture - A gui to run commands with whose output is kept rather than shown
+// Close the capture when finished with the gui
+func NewCapture() (*gocui.Gui, *Capture) {
+	c := &Capture{g: new(gocui.Gui)}
+	capmu.Lock()
+	captures[c.g] = c
+	capmu.Unlock()
+	return c.g, c
+}
+
+// Tee - A gui to run commands with whose output is kept and also shown with g
+// Close the capture when finished with the gui
+func Tee(g *gocui.Gui) (*gocui.Gui, *Capture) {
+	c := &Capture{g: new(gocui.Gui), parent: g, up: captured(g), tee: true}
+	capmu.Lock()
+	captures[c.g] = c
+	capmu.Unlock()
+	return c.g, c
+}
+
+// Close - Stop keeping output, anything still written with the gui is thrown away
+// or for a tee only shown
+// Commands can leave goroutines behind that log with it, their loggers hold on to the capture
+// so they find it closed, see Logger
+func (c *Capture) Close() {
+	capmu.Lock()
+	delete(captures, c.g)
+	capmu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.lines = nil
+	c.started = nil
+}
+
+// SetEngine - Run the commands on e rather than the process wide tables
+func (c *Capture) SetEngine(e *Engine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.e = e
+}
+
+// The engine commands run with g use, the process wide one unless a capture was given one
+func engine(g *gocui.Gui) *Engine {
+	for c := captured(g); c != nil; c = c.up {
+		c.mu.Lock()
+		e := c.e
+		c.mu.Unlock()
+		if e != nil {
+			return e
+		}
+	}
+	return Global()
+}
+
+// Start a transfer for a command run with g, the captures g is for know it started it
+func initiate(g *gocui.Gui, ttype string, peer *net.UDPAddr, eid string, fname string) (*Transfer, error) {
+	t, err := engine(g).NewInitiator(Logger(g), ttype, peer, eid, fname)
+	if err == nil && t != nil {
+		for c := captured(g); c != nil; c = c.up {
+			c.mu.Lock()
+			if !c.closed {
+				c.started = append(c.started, t)
+			}
+			c.mu.Unlock()
+		}
+	}
+	return t, err
+}
+
+// Started - The transfers commands run with the gui have started so far
+func (c *Capture) Started() []*Transfer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Transfer(nil), c.started...)
+}
+
+// Lines - Everything written so far to the view, all views if view is ""
+func (c *Capture) Lines(view string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lines []string
+	for _, o := range c.lines {
+		if view == "" || o.View == view {
+			lines = append(lines, o.Text)
+		}
+	}
+	return lines
+}
+
+// The capture g is the gui for, nil if it is not one
+func captured(g *gocui.Gui) *Capture {
+	capmu.Lock()
+	defer capmu.Unlock()
+	return captures[g]
+}
+
+// Keep s as written to view vname in the capture g is for and any it tees to
+// Returns the gui to show s with, false if there is nothing to show it
+func cprint(g *gocui.Gui, vname string, s string) (*gocui.Gui, bool) {
+	return captured(g).print(g, vname, s)
+}
+
+// Keep s in c and the captures it tees to, g is the gui of c
+// Returns the gui to show s with, false if there is nothing to show it
+func (c *Capture) print(g *gocui.Gui, vname string, s string) (*gocui.Gui, bool) {
+	for ; c != nil; c = c.up {
+		if c.keep(vname, s); !c.tee {
+			return nil, false
+		}
+		g = c.parent
+	}
+	return g, true
+}
+
+// The gui output written with g is shown with, nil if it is not shown
+func shownwith(g *gocui.Gui) *gocui.Gui {
+	for c := captured(g); c != nil; c = c.up {
+		if !c.tee {
+			return nil
+		}
+		g = c.parent
+	}
+	return g
+}
+
+func (c *Capture) keep(vname string, s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		c.lines = append(c.lines, Output{View: vname, Text: line})
+	}
+}
@@ -0,0 +1,108 @@
+// Engine - The settings and tables a saratoga peer runs its transfers with
+
+package sarwin
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/acl"
+	"github.com/charlesetsmith/saratoga/auth"
+	"github.com/charlesetsmith/saratoga/beacon"
+	"github.com/charlesetsmith/saratoga/quota"
+	"github.com/charlesetsmith/saratoga/sarflags"
+)
+
+// Engine - Who we know, what we are doing and what we allow
+// Main and the cli run on the process wide tables, see Global,
+// a Node embedded in a program can have tables of its own
+type Engine struct {
+	Flags     *sarflags.Cliflags // Settings, changed under sarflags.Climu
+	Peers     *beacon.Registry   // Who we have heard beacons from
+	Transfers *Registry          // Transfers in progress
+	Access    *acl.List          // Who may ask us for what, nil allows everything
+	Keys      *auth.Keys         // Keys to sign and verify requests with, nil is no authentication
+	Limits    *quota.Quotas      // Space checks for incoming files, nil is no checks
+}
+
+var globalmu sync.Mutex
+var global Engine // Access control, keys and quotas of the process wide engine, see SetGlobal
+
+// NewEngine - An engine with empty tables and the access control, keys and quotas from flags
+func NewEngine(flags *sarflags.Cliflags) (*Engine, error) {
+	access, err := acl.New(flags)
+	if err != nil {
+		return nil, err
+	}
+	var keys *auth.Keys
+	if flags.Auth.Keyfile != "" {
+		window := time.Duration(flags.Auth.Window) * time.Second
+		if keys, err = auth.Load(flags.Auth.Keyfile, window, flags.Auth.Required == "yes"); err != nil {
+			return nil, fmt.Errorf("cannot load key file %s: %w", flags.Auth.Keyfile, err)
+		}
+	} else if flags.Auth.Required == "yes" { // No one has a key so no one gets in
+		keys = auth.New(time.Duration(flags.Auth.Window)*time.Second, true)
+	}
+	return &Engine{
+		Flags:     flags,
+		Peers:     beacon.NewRegistry(),
+		Transfers: NewRegistry(),
+		Access:    access,
+		Keys:      keys,
+		Limits:    quota.New(flags),
+	}, nil
+}
+
+// SetGlobal - Main runs on e, its access control, keys and quotas apply to the process wide tables
+func SetGlobal(e *Engine) {
+	globalmu.Lock()
+	defer globalmu.Unlock()
+	global = Engine{Access: e.Access, Keys: e.Keys, Limits: e.Limits}
+}
+
+// Global - The engine made of the process wide tables main sets up
+// Until SetGlobal there is no access control, authentication or quota
+func Global() *Engine {
+	globalmu.Lock()
+	defer globalmu.Unlock()
+	return &Engine{
+		Flags:     sarflags.Cliflag,
+		Peers:     beacon.Peers,
+		Transfers: Transfers,
+		Access:    global.Access,
+		Keys:      global.Keys,
+		Limits:    global.Limits,
+	}
+}
+
+// Sardir - Where the engines files are, the current directory if not set
+func (e *Engine) Sardir() string {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	if e.Flags.Sardir == "" {
+		return "."
+	}
+	return e.Flags.Sardir
+}
+
+// Path - Where fname is, a peer cannot walk out of sardir with it
+func (e *Engine) Path(fname string) string {
+	return filepath.Join(e.Sardir(), acl.Clean(fname))
+}
+
+// Copy the settings for a transfer to keep
+func (e *Engine) copyflags() (*sarflags.Cliflags, error) {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	return e.Flags.CopyCliflags()
+}
+
+// PeerExpiry - How long without a beacon before a peer is lost, 0 is never
+func (e *Engine) PeerExpiry() time.Duration {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	return time.Duration(e.Flags.Timeout.Peerexpiry) *
+		time.Duration(e.Flags.Timeout.Binterval) * time.Second
+}
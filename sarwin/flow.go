@@ -0,0 +1,432 @@
+// Flow - Moving the file of a transfer with metadata, data and status frames
+// The sender sends the metadata then the data, asking for a status every Datacounter frames
+// and with the last frame. The receiver writes what it gets where it belongs and answers
+// with the holes it still has, which the sender resends until the receiver has it all
+
+package sarwin
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/data"
+	"github.com/charlesetsmith/saratoga/dirent"
+	"github.com/charlesetsmith/saratoga/metadata"
+	"github.com/charlesetsmith/saratoga/metrics"
+	"github.com/charlesetsmith/saratoga/sarcrypt"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/status"
+)
+
+// Bytes of the file in each data frame of a transfer starting now
+// Room is left for the largest offset and the cipher overhead whatever the transfer uses,
+// a transfer keeps what it started with so a hole is always resent in the same frames
+// it was first sent in and an offset is never sealed twice with different data
+func paylen() uint64 {
+	return uint64(sarflags.Mtu() - 60 - 8 - 8 - 8 - sarcrypt.Overhead) // IP, UDP, Header & Session, Offset
+}
+
+// A timeout in seconds as a duration
+func secs(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// Agreed - The responder has agreed to the request, if we are sending the file start sending it
+func (t *Transfer) Agreed(l sarlog.Logger) {
+	if t.Sender() {
+		go t.send(l)
+	}
+}
+
+// Entries - What a completed getdir found in the peers directory
+func (t *Transfer) Entries() ([]dirent.DirEnt, error) {
+	Trmu.Lock()
+	buf := t.Data
+	Trmu.Unlock()
+	return dirent.Entries(buf)
+}
+
+// Send the metadata describing what we are sending, progress is inprogress or terminated
+func (t *Transfer) sendmeta(l sarlog.Logger, progress string) error {
+	if t.Dir == nil {
+		return errors.New("no directory entry to send metadata for")
+	}
+	flags := "descriptor=" + sarflags.GetDStr(t.Dir.Header, "descriptor") +
+		",progress=" + progress + ",reliability=udponly"
+	if t.Ttype == "getdir" {
+		flags += ",transfer=directory"
+	} else {
+		flags += ",transfer=file"
+	}
+	if t.Crypt != nil {
+		flags += ",encrypt=yes"
+	}
+	m, err := metadata.New(flags, metadata.Minfo{Session: t.Session, Dir: t.Dir})
+	if err != nil {
+		return err
+	}
+	if t.Crypt != nil { // Only the peer may know what the file is called or tell us to stop
+		m.Dir.Path = t.Crypt.SealPath(m.Header, m.Dir.Path)
+	}
+	if err := m.Send(t.Conn, t.Peer); err != nil {
+		return err
+	}
+	l.Packet("Tx " + m.ShortPrint())
+	return nil
+}
+
+// The bytes of what we are sending from off up to end
+func (t *Transfer) read(off uint64, end uint64) ([]byte, error) {
+	Trmu.Lock()
+	fp, buf := t.Fp, t.Data
+	Trmu.Unlock()
+	if t.Ttype == "getdir" { // The directory listing is in memory
+		return buf[off:end], nil
+	}
+	if fp == nil {
+		return nil, ErrNotRunning
+	}
+	payload := make([]byte, end-off)
+	if _, err := fp.ReadAt(payload, int64(off)); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Send the data frame holding off up to end
+func (t *Transfer) senddata(l sarlog.Logger, off uint64, end uint64, reqstatus bool, eod bool) error {
+	payload, err := t.read(off, end)
+	if err != nil {
+		return err
+	}
+	if t.Crypt != nil {
+		payload = t.Crypt.Seal(off, payload)
+	}
+	flags := "descriptor=" + sarflags.GetDStr(t.Dir.Header, "descriptor")
+	if reqstatus {
+		flags += ",reqstatus=yes"
+	}
+	if eod {
+		flags += ",eod=yes"
+	}
+	if t.Crypt != nil {
+		flags += ",encrypt=yes"
+	}
+	d, err := data.New(flags, data.Dinfo{Session: t.Session, Offset: off, Payload: payload})
+	if err != nil {
+		return err
+	}
+	if err := d.Send(t.Conn, t.Peer); err != nil {
+		return err
+	}
+	l.Packet("Tx " + d.ShortPrint())
+	Trmu.Lock()
+	t.Dcount++
+	Trmu.Unlock()
+	return nil
+}
+
+// Send the frames holding from up to to, the last of them asks for a status
+// again is set when they have been sent before so are counted as retransmitted
+func (t *Transfer) sendrange(l sarlog.Logger, from uint64, to uint64, again bool) error {
+	size := t.Dir.Size
+	plen := t.plen
+	count := t.Cliflags.Timeout.Datacounter
+	for n, off := 1, from/plen*plen; ; n, off = n+1, off+plen {
+		end := min(off+plen, size)
+		last := end >= to
+		reqstatus := last || (count > 0 && n%count == 0)
+		if err := t.senddata(l, off, end, reqstatus, end == size); err != nil {
+			return err
+		}
+		if again {
+			metrics.Retransmitted.Add(float64(end - off))
+		}
+		if last || t.Context().Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Send the file then resend whatever the receivers status frames say it is missing
+// until it has it all. If no status comes back in the status timeout ask again
+func (t *Transfer) send(l sarlog.Logger) {
+	ctx := t.Context()
+	failed := func(err error) {
+		if ctx.Err() == nil { // Not just stopped under us
+			l.Err("Cannot send "+t.Print(), sarlog.F("err", err))
+			t.Cancel(l, false)
+		}
+	}
+	size := t.Dir.Size
+	if err := t.sendmeta(l, "inprogress"); err != nil {
+		failed(err)
+		return
+	}
+	if err := t.sendrange(l, 0, size, false); err != nil {
+		failed(err)
+		return
+	}
+
+	var retry <-chan time.Time
+	wait := secs(t.Cliflags.Timeout.Status)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	if wait > 0 {
+		retry = timer.C
+	}
+	again := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry: // Ask again with the last frame
+			err = t.sendrange(l, max(size, 1)-1, size, true)
+		case s := <-t.status:
+			switch {
+			case !sarflags.StatusHeader(s.Header).Metadatarecvd(): // It could not take any of it
+				if err = t.sendmeta(l, "inprogress"); err == nil {
+					err = t.sendrange(l, 0, size, true)
+				}
+			case s.Progress >= size && len(s.Holes) == 0:
+				t.complete(l)
+				return
+			default: // Holes after the frame that asked for the status are still on their way
+				upto := min(s.Inrespto+t.plen, size)
+				for _, h := range s.Holes {
+					if uint64(h.Start) >= upto || err != nil {
+						break
+					}
+					err = t.sendrange(l, uint64(h.Start), min(uint64(h.End), upto), true)
+				}
+			}
+		}
+		if err != nil {
+			failed(err)
+			return
+		}
+		again()
+	}
+}
+
+// StatusRx - A status frame from the peer for the transfer
+// The first an initiator gets is the responders answer to its request,
+// after that status frames are the receiver telling the sender what it has
+func (t *Transfer) StatusRx(l sarlog.Logger, s status.Status) {
+	if errcode := sarflags.StatusHeader(s.Header).Errcode().String(); errcode != "success" {
+		t.PeerCancelled(l, errcode) // Any error from the peer ends the transfer
+		return
+	}
+	// If the responder did not agree to encrypt as we asked then abandon the transfer
+	if err := sarcrypt.Check(t.Crypt, s.Header); err != nil {
+		l.Err("Abandoning transfer "+t.Print(), sarlog.F("err", err))
+		t.PeerCancelled(l, "accessdenied")
+		return
+	}
+	Trmu.Lock()
+	if t.State != Running {
+		Trmu.Unlock()
+		return
+	}
+	t.lastrx = time.Now()
+	first := t.Direction == Initiator && !t.accepted
+	t.accepted = true
+	if t.Direction == Initiator {
+		t.Progress, t.Inrespto = s.Progress, s.Inrespto
+	}
+	Trmu.Unlock()
+
+	switch {
+	case first && t.Ttype == "delete": // The peer has done it, there is nothing more to come
+		t.complete(l)
+	case first:
+		t.Agreed(l)
+	case t.Sender():
+		select {
+		case t.status <- s:
+		default: // The sender is behind, it will ask again if it needs to
+		}
+	}
+}
+
+// Flags of the status frames a receiver sends
+func (t *Transfer) statusflags() string {
+	Trmu.Lock()
+	havemeta := t.Havemeta
+	Trmu.Unlock()
+	flags := "descriptor=d64,reqholes=requested,errcode=success"
+	if havemeta {
+		flags += ",metadatarecvd=yes"
+	} else {
+		flags += ",metadatarecvd=no"
+	}
+	if t.Crypt != nil {
+		flags += ",encrypt=yes"
+	}
+	return flags
+}
+
+// Write what we have received at off, the file is only created once we are sent it
+// Trmu must be held
+func (t *Transfer) write(off uint64, payload []byte) error {
+	if t.Ttype == "getdir" { // A directory listing is kept in memory
+		copy(t.Data[off:], payload)
+		return nil
+	}
+	if t.Fp == nil {
+		fp, err := os.OpenFile(t.engine().Path(t.Filename), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		t.Fp = fp
+	}
+	_, err := t.Fp.WriteAt(payload, int64(off))
+	return err
+}
+
+// DataRx - A data frame from the peer sending us the file
+// We answer with a status when it asks for one and when we have the whole file
+func (t *Transfer) DataRx(l sarlog.Logger, d *data.Data) {
+	if err := sarcrypt.Check(t.Crypt, d.Header); err != nil {
+		l.Err("Dropping data for "+t.Print(), sarlog.F("err", err))
+		return
+	}
+	payload := d.Payload
+	if t.Crypt != nil {
+		var err error
+		if payload, err = t.Crypt.Open(d.Offset, d.Payload); err != nil {
+			l.Err("Dropping data for "+t.Print(), sarlog.F("offset", d.Offset), sarlog.F("err", err))
+			return
+		}
+	}
+	h := sarflags.DataHeader(d.Header)
+	answer := h.Reqstatus() || h.EOD()
+
+	Trmu.Lock()
+	switch {
+	case t.State == Completed: // Our last status went astray so tell it again we have it all
+		Trmu.Unlock()
+		if answer {
+			t.WriteStatus(l, t.statusflags())
+		}
+		return
+	case t.State != Running:
+		Trmu.Unlock()
+		return
+	}
+	t.lastrx = time.Now()
+	t.Framecount++
+	if !t.Havemeta { // We do not know what it is yet, the sender resends it all once we do
+		Trmu.Unlock()
+		if answer {
+			t.WriteStatus(l, t.statusflags())
+		}
+		return
+	}
+	end := d.Offset + uint64(len(payload))
+	if end > t.Dir.Size {
+		Trmu.Unlock()
+		l.Err("Data beyond the end of "+t.Print(), sarlog.F("offset", d.Offset), sarlog.F("size", t.Dir.Size))
+		return
+	}
+	if err := t.write(d.Offset, payload); err != nil {
+		Trmu.Unlock()
+		l.Err("Cannot write "+t.Print(), sarlog.F("err", err))
+		t.Cancel(l, true)
+		return
+	}
+	t.Curfills = t.Curfills.Add(int(d.Offset), int(end))
+	t.Dcount++
+	t.Inrespto = d.Offset
+	t.Progress = 0 // Everything up to here has arrived
+	if len(t.Curfills) > 0 && t.Curfills[0].Start == 0 {
+		t.Progress = uint64(t.Curfills[0].End)
+	}
+	done := t.Progress == t.Dir.Size
+	Trmu.Unlock()
+
+	if done {
+		t.complete(l)
+	}
+	if answer || done {
+		t.WriteStatus(l, t.statusflags())
+	}
+}
+
+// The whole file has been sent or received. It stays listed for the transfer timeout
+// in case the peer missed our last frame and asks again, then it removes itself
+// A delete has nothing more to hear so goes straight away
+func (t *Transfer) complete(l sarlog.Logger) {
+	Trmu.Lock()
+	if t.State != Running {
+		Trmu.Unlock()
+		return
+	}
+	t.State = Completed
+	if t.stop != nil {
+		t.stop()
+	}
+	if t.Fp != nil {
+		if !t.Sender() {
+			t.Fp.Sync()
+		}
+		t.Fp.Close()
+		t.Fp = nil
+	}
+	linger := secs(t.Cliflags.Timeout.Transfer)
+	Trmu.Unlock()
+	defer close(t.done) // Once it is all tidied up
+
+	e := t.engine()
+	e.Limits.Release(t.Peer.IP, t.Session)
+	// take and give move the file rather than copy it
+	if t.Sender() && (t.Ttype == "take" || t.Ttype == "give") {
+		if err := os.Remove(e.Path(t.Filename)); err != nil {
+			l.Err("Cannot remove file", sarlog.F("file", t.Filename), sarlog.F("err", err))
+		}
+	}
+	sarlog.Emit(l, sarlog.TransferCompleted, t.Fields()...)
+	if t.Ttype == "delete" { // Nothing more can come from the peer
+		if err := t.Remove(); err != nil {
+			l.Err("Unable to remove transfer "+t.Print(), sarlog.F("err", err))
+		}
+		return
+	}
+	time.AfterFunc(linger, func() {
+		t.Remove() // Unless it has been already
+	})
+}
+
+// Cancel the transfer if the peer goes quiet on it for the transfer timeout, 0 waits forever
+func (t *Transfer) watch(l sarlog.Logger) {
+	idle := secs(t.Cliflags.Timeout.Transfer)
+	if idle <= 0 {
+		return
+	}
+	for {
+		Trmu.Lock()
+		wait := time.Until(t.lastrx.Add(idle))
+		Trmu.Unlock()
+		if wait <= 0 {
+			l.Err("Nothing heard from peer, cancelling "+t.Print(), sarlog.F("wait", idle))
+			t.Cancel(l, true)
+			return
+		}
+		select {
+		case <-t.Context().Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Headless - Output when running without the gocui interface
+
+package sarwin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/charlesetsmith/saratoga/sarlog"
+)
+
+var hmu sync.Mutex
+var hlog *sarlog.Writer // Where view output goes, nil when running the gocui interface
+
+// SetHeadless - Send what would go to the gocui views to out and errout instead
+// Errors go to errout, everything else to out, packet traces only if packets is set
+func SetHeadless(out io.Writer, errout io.Writer, packets bool) {
+	hmu.Lock()
+	defer hmu.Unlock()
+	hlog = sarlog.New(out, errout, packets)
+}
+
+// Headless - Are we running without the gocui interface
+func Headless() bool {
+	hmu.Lock()
+	defer hmu.Unlock()
+	return hlog != nil
+}
+
+// Write s to the headless log for view vname, false if we have the gocui interface
+func hprint(vname string, s string) bool {
+	hmu.Lock()
+	w := hlog
+	hmu.Unlock()
+	if w == nil {
+		return false
+	}
+	s = strings.TrimRight(s, "\n")
+	switch vname {
+	case "err":
+		w.Err(s)
+	case "packet":
+		w.Packet(s)
+	default:
+		w.Msg(s)
+	}
+	return true
+}
+
+// Headless version of fprintf, colours are dropped
+func hprintf(vname string, format string, args ...interface{}) bool {
+	return hprint(vname, fmt.Sprintf(format, args...))
+}
+
+// Headless version of fprintln, colours are dropped
+func hprintln(vname string, args ...interface{}) bool {
+	return hprint(vname, fmt.Sprint(args...))
+}
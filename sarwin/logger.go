@@ -0,0 +1,43 @@
+// Logger - The gocui views as somewhere for the engines to log to
+
+package sarwin
+
+import (
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/jroimartin/gocui"
+)
+
+// views - Log lines go to the msg, err and packet views, events to msg
+type views struct {
+	g *gocui.Gui
+	c *Capture // The capture g is for, kept as transfers log long after their command has closed it
+}
+
+// Logger - The gocui views as a sarlog.Logger and sarlog.EventSink
+// Headless the lines go wherever SetHeadless sent them
+func Logger(g *gocui.Gui) sarlog.Logger {
+	return views{g: g, c: captured(g)}
+}
+
+// Write s to view vname unless it was captured and the capture has been closed
+func (v views) println(vname string, colour string, s string) {
+	if g, show := v.c.print(v.g, vname, s); show {
+		fprintln(g, vname, colour, s)
+	}
+}
+
+func (v views) Msg(msg string, fields ...sarlog.Field) {
+	v.println("msg", "green_black", sarlog.Line(msg, fields))
+}
+
+func (v views) Err(msg string, fields ...sarlog.Field) {
+	v.println("err", "red_black", sarlog.Line(msg, fields))
+}
+
+func (v views) Packet(msg string, fields ...sarlog.Field) {
+	v.println("packet", "cyan_black", sarlog.Line(msg, fields))
+}
+
+func (v views) Event(e sarlog.Event) {
+	v.println("msg", "yellow_black", sarlog.Line(e.Kind, e.Fields))
+}
@@ -0,0 +1,169 @@
+// Transfer Registry - The transfers in progress keyed by direction, peer and session
+
+package sarwin
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Key - What uniquely identifies a transfer
+type Key struct {
+	Direction bool   // Initiator or Responder
+	Peer      string // ip:port of the peer
+	Session   uint32 // Session ID
+}
+
+// Key - The registry key of the transfer
+func (t *Transfer) Key() Key {
+	k := Key{Direction: t.Direction, Session: t.Session}
+	if t.Peer != nil {
+		k.Peer = t.Peer.String()
+	}
+	return k
+}
+
+// ErrDuplicate - A transfer with the same direction, peer and session is already in progress
+var ErrDuplicate = errors.New("transfer already in progress")
+
+// ErrNoTransfer - The transfer is not in the registry
+var ErrNoTransfer = errors.New("no such transfer")
+
+// Registry - Transfers in progress
+// It holds pointers so a *Transfer handed out stays the transfer in the registry,
+// changes to the contents of a Transfer are protected by Trmu
+type Registry struct {
+	mu        sync.Mutex
+	transfers map[Key]*Transfer
+	added     []func(*Transfer) // Lifecycle hooks
+	removed   []func(*Transfer)
+}
+
+// NewRegistry - An empty transfer registry
+func NewRegistry() *Registry {
+	return &Registry{transfers: make(map[Key]*Transfer)}
+}
+
+// Transfers - The transfers in progress
+var Transfers = NewRegistry()
+
+// Trmu - Protect the contents of transfers
+var Trmu sync.Mutex
+
+// OnAdd - Call f with each transfer added from now on
+func (r *Registry) OnAdd(f func(*Transfer)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.added = append(r.added, f)
+}
+
+// OnRemove - Call f with each transfer removed from now on
+func (r *Registry) OnRemove(f func(*Transfer)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, f)
+}
+
+// Call the hooks outside the lock so they can use the registry
+func (r *Registry) call(hooks []func(*Transfer), t *Transfer) {
+	for _, f := range hooks {
+		f(t)
+	}
+}
+
+// Add - Add the transfer unless one with the same key is already in progress
+func (r *Registry) Add(t *Transfer) error {
+	r.mu.Lock()
+	k := t.Key()
+	if _, ok := r.transfers[k]; ok {
+		r.mu.Unlock()
+		return ErrDuplicate
+	}
+	r.transfers[k] = t
+	hooks := r.added
+	r.mu.Unlock()
+	r.call(hooks, t)
+	return nil
+}
+
+// Remove - Remove the transfer, only if it is the one in the registry under its key
+func (r *Registry) Remove(t *Transfer) error {
+	r.mu.Lock()
+	k := t.Key()
+	if r.transfers[k] != t {
+		r.mu.Unlock()
+		return ErrNoTransfer
+	}
+	delete(r.transfers, k)
+	hooks := r.removed
+	r.mu.Unlock()
+	r.call(hooks, t)
+	return nil
+}
+
+// Lookup - The transfer with direction, session and peer ip:port, nil if there is none
+func (r *Registry) Lookup(direction bool, session uint32, peer string) *Transfer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.transfers[Key{Direction: direction, Peer: peer, Session: session}]
+}
+
+// Match - The transfer in either direction with the peer ip:port and session, initiators first
+func (r *Registry) Match(peer string, session uint32) *Transfer {
+	if t := r.Lookup(Initiator, session, peer); t != nil {
+		return t
+	}
+	return r.Lookup(Responder, session, peer)
+}
+
+// Find - The transfers in either direction with the peer at addr on any port and session
+func (r *Registry) Find(addr net.IP, session uint32) []*Transfer {
+	var found []*Transfer
+	for _, t := range r.Snapshot() {
+		if t.Session == session && t.Peer != nil && t.Peer.IP.Equal(addr) {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// Snapshot - The transfers in progress now, ordered by peer, session then direction
+// The slice is ours to keep while the registry changes
+func (r *Registry) Snapshot() []*Transfer {
+	r.mu.Lock()
+	list := make([]*Transfer, 0, len(r.transfers))
+	for _, t := range r.transfers {
+		list = append(list, t)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].Key(), list[j].Key()
+		if a.Peer != b.Peer {
+			return a.Peer < b.Peer
+		}
+		if a.Session != b.Session {
+			return a.Session < b.Session
+		}
+		return a.Direction && !b.Direction
+	})
+	return list
+}
+
+// Len - How many transfers are in progress
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.transfers)
+}
+
+// Lookup - Return a pointer to the transfer if we find it in Transfers, nil otherwise
+func Lookup(direction bool, session uint32, peer string) *Transfer {
+	return Transfers.Lookup(direction, session, peer)
+}
+
+// Match - Lookup a host & session and return transfer pointer or nil if it does not exist
+func Match(addr string, session uint32) *Transfer {
+	return Transfers.Match(addr, session)
+}
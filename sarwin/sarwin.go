@@ -6,6 +6,7 @@
 package sarwin
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -15,17 +16,23 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jroimartin/gocui"
 
+	"github.com/charlesetsmith/saratoga/auth"
 	"github.com/charlesetsmith/saratoga/beacon"
+	"github.com/charlesetsmith/saratoga/capability"
 	"github.com/charlesetsmith/saratoga/dirent"
-	"github.com/charlesetsmith/saratoga/fileio"
+	"github.com/charlesetsmith/saratoga/eid"
 	"github.com/charlesetsmith/saratoga/holes"
 	"github.com/charlesetsmith/saratoga/metadata"
+	"github.com/charlesetsmith/saratoga/quota"
 	"github.com/charlesetsmith/saratoga/request"
+	"github.com/charlesetsmith/saratoga/sarcrypt"
 	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
 	"github.com/charlesetsmith/saratoga/sarnet"
 	"github.com/charlesetsmith/saratoga/status"
 	"github.com/charlesetsmith/saratoga/timestamp"
@@ -107,6 +114,9 @@ func Clearwin(g *gocui.Gui, win string) {
 
 	var newview *gocui.View
 
+	if g = shownwith(g); Headless() || g == nil { // No views to clear
+		return
+	}
 	curview := g.CurrentView()
 	curname := curview.Name()
 	// x, y := curview.Cursor()
@@ -303,6 +313,10 @@ func setcolour(colour string) string {
 // If colour is undefined then still print it out but in bright red to show there is an issue
 func fprintf(g *gocui.Gui, vname string, colour string, format string, args ...interface{}) {
 
+	var show bool
+	if g, show = cprint(g, vname, fmt.Sprintf(format, args...)); !show || hprintf(vname, format, args...) {
+		return
+	}
 	g.Update(func(g *gocui.Gui) error {
 		ViewMu.Lock()
 		defer ViewMu.Unlock()
@@ -327,6 +341,10 @@ func fprintf(g *gocui.Gui, vname string, colour string, format string, args ...i
 // If colour is undefined then still print it out but in bright red to show there is an issue
 func fprintln(g *gocui.Gui, vname string, colour string, args ...interface{}) {
 
+	var show bool
+	if g, show = cprint(g, vname, fmt.Sprint(args...)); !show || hprintln(vname, args...) {
+		return
+	}
 	g.Update(func(g *gocui.Gui) error {
 		ViewMu.Lock()
 		defer ViewMu.Unlock()
@@ -545,11 +563,10 @@ func prompt(g *gocui.Gui, v *gocui.View) {
 // Commands - cli commands entered
 var Commands []string
 
-// Sarwg - Wait group for commands to run/finish - We dont quit till this is 0
-var Sarwg sync.WaitGroup
-
+// Quit - ^C exits once transfers finish, a second ^C does not wait for them
 func Quit(g *gocui.Gui, v *gocui.View) error {
-	return gocui.ErrQuit
+	RequestExit(ExitOK, ShuttingDown())
+	return nil
 }
 
 // ShowPacket - Show Packet trace info
@@ -591,12 +608,6 @@ func GetLine(g *gocui.Gui, v *gocui.View) error {
 		// Save the command into history
 		Cinfo.Commands = append(Cinfo.Commands, command[1])
 
-		if command[1] == "exit" || command[1] == "quit" {
-			// Sarwg.Wait()
-			err := Quit(g, v)
-			// THIS IS A KLUDGE FIX IT WITH A CHANNEL
-			log.Fatal("\nGocui Exit. Bye!\n", err)
-		}
 		// RUN THE COMMAND ENTERED!!!
 		Run(g, command[1])
 		prompt(g, v)
@@ -696,38 +707,6 @@ func appendunique(slice []string, i string) []string {
 
 // All of the different command line input handlers
 // Send count beacons to host
-func sendbeacons(g *gocui.Gui, clib Beaconcmd, addr *net.UDPAddr) {
-	// We have a hostname maybe with multiple addresses
-	var err error
-	var txb beacon.Beacon // The assembled beacon to transmita
-	var conn *net.UDPConn
-
-	if conn, err = net.DialUDP("udp", nil, addr); err != nil {
-		ErrPrintln(g, "red_black", "Error:", err.Error(),
-			"Unable to Dial", addr.String())
-		return
-	}
-	defer conn.Close()
-
-	MsgPrintln(g, "cyan_black", "Sending beacon to ", addr.String())
-	eid := fmt.Sprintf("%s-%d", conn.LocalAddr().String(), os.Getpid())
-	binfo := beacon.Binfo{Freespace: 0, Eid: eid} // We work out the Freespace in txb.New
-	if err = txb.New(clib.flags, &binfo); err != nil {
-		ErrPrintln(g, "red_black", "New Beacon Error:", err.Error())
-		return
-	}
-	// We send out beacons every interval seconds (if that is 0 we just send 1 beacon)
-	for iterations := clib.count; iterations > 0; iterations-- {
-		if err = txb.Send(conn); err != nil {
-			ErrPrintln(g, "red_black", "Error:", err.Error(),
-				" Unable to send beacon to ", addr.String())
-			return
-		}
-		PacketPrintln(g, "cyan_black", "Tx ", addr.String(), "\n", txb.ShortPrint())
-		time.Sleep(time.Duration(clib.interval) * time.Second)
-	}
-}
-
 /* ********************************************************************************* */
 //  Transfer handlers
 /* ********************************************************************************* */
@@ -860,16 +839,16 @@ var smu sync.Mutex
 var sessionid uint32
 
 type Transfer struct {
-	Direction  bool                 // Am I the Initiator of; or Responder to a transfer
-	Session    uint32               // Session ID - This is the unique key
-	Conn       *net.UDPConn         // The connection to the remote peer
-	Peer       *net.UDPAddr         // ip address and port of the peer
-	Ttype      string               // Transfer type "get,take,put,give,putblind,delete"
-	Tstamp     timestamp.Timestamp  // Latest timestamp received from Data
-	Tstamptype string               // Timestamp type "localinterp,posix32,posix64,posix32_32,posix64_32,epoch2000_32"
-	Filename   string               // Local File name to receive or remove from remote host or send from local host
-	Fp         *os.File             // File pointer for local file
-	Filemeta   *fileio.FileMetaData // File metadata of the local file
+	Direction  bool                // Am I the Initiator of; or Responder to a transfer
+	Session    uint32              // Session ID - This is the unique key
+	Conn       *net.UDPConn        // The connection to the remote peer, a responders is the listener it was asked on
+	Peer       *net.UDPAddr        // ip address and port of the peer
+	Eid        string              // EID we asked for the peer by or it authenticated as, "" if neither
+	Ttype      string              // Transfer type "get,take,put,give,putblind,delete"
+	Tstamp     timestamp.Timestamp // Latest timestamp received from Data
+	Tstamptype string              // Timestamp type "localinterp,posix32,posix64,posix32_32,posix64_32,epoch2000_32"
+	Filename   string              // Local File name to receive or remove from remote host or send from local host
+	Fp         *os.File            // File pointer for local file
 	// frames    [][]byte           // Frames to process
 	// holes     holes.Holes        // Holes to process
 	Version    string             // Flag
@@ -888,140 +867,250 @@ type Transfer struct {
 	Inrespto   uint64             // In respose to indicator
 	Curfills   holes.Holes        // What has been received
 	Cliflags   *sarflags.Cliflags // Global flags used in this transfer
+	Crypt      *sarcrypt.Session  // Ciphers when the transfer is encrypted, nil when in the clear
+	State      string             // Running, Completed or Cancelled, protected by Trmu
+	Errcode    string             // Why it was cancelled, our own cancel or the peers errcode
+	Started    time.Time          // When we started it
+	plen       uint64             // Bytes of the file in each data frame, fixed when we start
+	ctx        context.Context    // Done when the transfer is over
+	stop       context.CancelFunc
+	done       chan struct{}      // Closed when the transfer has completed or been cancelled
+	status     chan status.Status // Status frames for the sender to act on
+	lastrx     time.Time          // When we last heard from the peer about the transfer
+	accepted   bool               // The responder has agreed to our request
+	claimed    atomic.Bool        // Someone is listening for frames on an initiators Conn
+	e          *Engine            // The tables the transfer is listed in
+	auth       []byte             // Auth field of our request, signed when the transfer is created
+}
+
+// The engine the transfer runs on, transfers made without one run on the process wide tables
+func (t *Transfer) engine() *Engine {
+	if t.e == nil {
+		return Global()
+	}
+	return t.e
+}
+
+// The key we share with the peer, its address key if we have one otherwise its EIDs key
+// The same choice auth makes when signing and verifying requests
+func (t *Transfer) key() []byte {
+	keys := t.engine().Keys
+	if key := keys.Key(t.Peer.IP, ""); key != nil || t.Eid == "" {
+		return key
+	}
+	return keys.Key(nil, t.Eid)
+}
+
+// Transfer states
+const (
+	Running   = "running"   // Transfer is in progress
+	Completed = "completed" // All done, stays listed while the peer might still need an answer
+	Cancelled = "cancelled" // Cancelled by us or the peer, stays listed until removed with rmtran
+)
+
+// ErrNotRunning - The transfer has already been cancelled
+var ErrNotRunning = errors.New("transfer is not running")
+
+// Start the transfer running with a context to stop its goroutines
+func (t *Transfer) start() {
+	t.ctx, t.stop = context.WithCancel(context.Background())
+	t.done = make(chan struct{})
+	t.status = make(chan status.Status, 16)
+	t.State = Running
+	t.Started = time.Now()
+	t.lastrx = t.Started
+	t.plen = paylen()
 }
 
-// Transfers - protected transfers in progress
-var Trmu sync.Mutex
-var Transfers = []Transfer{}
+// Done - Closed when the transfer has completed or been cancelled, Stats says which
+func (t *Transfer) Done() <-chan struct{} {
+	return t.done
+}
 
-// Lookup - Return a pointer to the transfer if we find it in Transfers, nil otherwise
-func Lookup(direction bool, session uint32, peer string) *Transfer {
-	for _, t := range Transfers {
-		// Check if direction (Initiator or Responder), session # and IP address match in our current
-		// list of transfers (if so then return a pointer to it)
+// Claim - True for the first caller only, who then listens for frames on the initiators Conn
+func (t *Transfer) Claim() bool {
+	return t.claimed.CompareAndSwap(false, true)
+}
 
-		if direction == t.Direction && session == t.Session && t.Peer.String() == peer {
-			return &t
-		}
+// Context - Done when the transfer is cancelled, goroutines working on the transfer watch it
+func (t *Transfer) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
 	}
-	return nil
+	return t.ctx
 }
 
-// Lookup a host & session and return transfer pointer or nil if it does not exist
-func Match(addr string, session uint32) *Transfer {
-	for i := len(Transfers) - 1; i >= 0; i-- {
-		if addr == Transfers[i].Conn.RemoteAddr().String() && session == Transfers[i].Session {
-			return &Transfers[i]
-		}
+// Sender - Do we send the file in this transfer rather than receive it
+func (t *Transfer) Sender() bool {
+	if t.Direction == Initiator {
+		return capability.Receives(t.Ttype)
 	}
-	return nil
+	return capability.Sends(t.Ttype)
 }
 
-// CNew - Add a new transfer to the Transfers list
-func NewInitiator(g *gocui.Gui, ttype string, peer *net.UDPAddr, fname string, c *sarflags.Cliflags) (*Transfer, error) {
+// Running - Is the transfer still in progress
+func (t *Transfer) Running() bool {
+	Trmu.Lock()
+	defer Trmu.Unlock()
+	return t.State == Running
+}
+
+// NewInitiator - Add a new transfer we start to the engines Transfers
+// eid is the EID we asked for the peer by, "" if we asked for it by address
+func (e *Engine) NewInitiator(l sarlog.Logger, ttype string, peer *net.UDPAddr, eid string, fname string) (*Transfer, error) {
+	if ShuttingDown() {
+		l.Err("Cannot "+ttype+" "+fname, sarlog.F("err", ErrShuttingDown))
+		return nil, ErrShuttingDown
+	}
 	// screen.Fprintln(g,  "red_black", "Addtran for ", ip.String(), " ", fname, " ", flags)
-	for _, i := range Transfers { // Don't add duplicates (ie dont try act on same fname)
+	for _, i := range e.Transfers.Snapshot() { // Don't add duplicates (ie dont try act on same fname)
+		if !i.Running() { // Cancelled transfers are only kept to be listed
+			continue
+		}
 		// Make sure we have connections to all the current Transfers
 		if i.Conn == nil {
 			emsg := "No connection exists to peer: " + peer.String()
-			ErrPrintln(g, "red_black", emsg)
+			l.Err(emsg)
 			// Remove the transfer as we have no connection to a peer
 			if err := i.Remove(); err != nil {
-				ErrPrintln(g, "red_black", "Can't remove transfer of", i.Session)
+				l.Err("Can't remove transfer", sarlog.F("session", i.Session))
 			}
 			return nil, errors.New(emsg)
 		}
-		// If the file currently exists on our system and we try to "get" it then we won;t allow that
-		// We cant overwrite an existing file on our system
-		if fileio.FileExists(fname) && (ttype == "get" || ttype == "getdelete" || ttype == "getdir") {
-			emsg := fmt.Sprintf("File %s already exists, cannot overwrite", fname)
-			ErrPrintln(g, "red_black", emsg)
-			return nil, errors.New(emsg)
-		}
 		// We do not allow duplicate transfers of a file to the same peer
 		if fname == i.Filename && peer.String() == i.Peer.String() {
 			emsg := fmt.Sprintf("Initiator %s to %s currently in progress",
 				fname, peer.String())
-			ErrPrintln(g, "red_black", emsg)
+			l.Err(emsg)
 			return nil, errors.New(emsg)
 		}
 	}
+	// If the file currently exists on our system and we try to "get" it then we won;t allow that
+	// We cant overwrite an existing file on our system
+	local := e.Path(fname)
+	if _, err := os.Stat(local); err == nil && (ttype == "get" || ttype == "take") {
+		emsg := fmt.Sprintf("File %s already exists, cannot overwrite", fname)
+		l.Err(emsg)
+		return nil, errors.New(emsg)
+	}
 
-	// OK lets create the transfer
+	// Copy the FLAGS to t.cliflags
+	c, err := e.copyflags()
+	if err != nil {
+		return nil, err
+	}
 
-	// Lock it as we are going to add a new transfer slice
-	Trmu.Lock()
-	defer Trmu.Unlock()
+	// Don't start what we or the peer have said we are not willing to do
+	p, havepeer := e.Peers.ByAddr(peer.IP)
+	if err := capability.Initiator(ttype, capability.Local(c.Global),
+		capability.Flags{Rxwilling: p.Canrx, Txwilling: p.Cantx}); err != nil {
+		emsg := fmt.Sprintf("Cannot %s %s with %s: %s", ttype, fname, peer.String(), err)
+		l.Err(emsg)
+		return nil, errors.New(emsg)
+	}
+
+	// OK lets create the transfer
 	t := new(Transfer)
+	t.e = e
 	t.Direction = Initiator
 	t.Ttype = ttype
 	t.Tstamptype = c.Timestamp
-	t.Session = newsession()
 	t.Peer = peer
+	t.Eid = eid
+	t.Filename = fname
+	t.Cliflags = c
 
-	var err error
-	// Dial the peer to create the connection
-	if t.Conn, err = net.DialUDP("udp", nil, peer); err != nil {
-		ErrPrintln(g, "red_black", "Cannot dial peer "+peer.String()+" "+err.Error())
+	// Open up the local file we are sending, what we receive is only created when it arrives
+	if t.Sender() {
+		if t.Fp, err = os.Open(local); err != nil {
+			l.Err("Cannot open file", sarlog.F("file", local), sarlog.F("err", err))
+			return nil, err
+		}
+		fi, err := t.Fp.Stat()
+		if err == nil {
+			t.Dir = new(dirent.DirEnt)
+			err = t.Dir.Info(fname, fi)
+		}
+		if err != nil {
+			t.Fp.Close()
+			return nil, err
+		}
+		// Don't send a file the peer has told us in its beacons it has no room for
+		if havepeer && !quota.Fits(p.Freespace, t.Dir.Size) {
+			emsg := fmt.Sprintf("File %s of %d bytes too big for peer %s with %d kB free",
+				fname, t.Dir.Size, peer.String(), p.Freespace)
+			l.Err(emsg)
+			t.Fp.Close()
+			return nil, errors.New(emsg)
+		}
 	}
 
-	// Open up the local for i/o
-	t.Filename = fname
-	if t.Fp, err = fileio.FileOpen(t.Filename, t.Ttype); err != nil {
-		ErrPrintln(g, "red_black", err)
-	}
-	if t.Filemeta, err = fileio.FileMeta(fname); err != nil {
-		ErrPrintln(g, "red_black", err)
+	t.start()
+	t.Session = newsession()
+	// Dial the peer to create the connection
+	if t.Conn, err = net.DialUDP("udp", nil, peer); err != nil {
+		l.Err("Cannot dial peer", sarlog.F("peer", peer), sarlog.F("err", err))
+		t.close()
+		return nil, err
+	}
+
+	// Sign the request now, encrypted transfers salt their keys with its signature
+	var r request.Request
+	if r, err = t.request(); err == nil {
+		t.auth, err = e.Keys.Sign(t.Peer.IP, t.Eid, r.Header, r.Session, r.Fname)
+	}
+	if err != nil {
+		l.Err("Cannot create request", sarlog.F("peer", peer), sarlog.F("err", err))
+		t.close()
+		return nil, err
+	}
+	// We can only encrypt if we share a key with the peer
+	if t.Cliflags.Global["encrypt"] == "yes" {
+		if t.Crypt, err = sarcrypt.New(t.key(), t.Session, auth.Salt(t.auth)); err != nil {
+			emsg := "Cannot encrypt transfer to " + peer.String() + " " + err.Error()
+			l.Err(emsg)
+			t.close()
+			return nil, errors.New(emsg)
+		}
 	}
-
-	// Copy the FLAGS to t.cliflags
-	if t.Cliflags, err = c.CopyCliflags(); err != nil {
-		panic(err)
+	if err = e.Transfers.Add(t); err != nil {
+		t.close()
+		return nil, err
 	}
-	msg := fmt.Sprintf("Initiator Added %s Transfer to %s %s",
-		t.Ttype, t.Peer.String(), t.Filename)
-	Transfers = append(Transfers, *t)
-	MsgPrintln(g, "green_black", msg)
+	sarlog.Emit(l, sarlog.TransferAdded, t.Fields()...)
+	go t.watch(l)
 	return t, nil
 }
 
-// New - Add a new transfer to the Transfers list upon receipt of a request
+// NewResponder - Add a new transfer to the engines Transfers upon receipt of a request
 // when we receive a request we are therefore a responder
-func NewResponder(g *gocui.Gui, r request.Request, peer string) error {
+// conn is the socket the request came in on, we answer from it as the initiator only hears from there
+// fname is the file in sardir the request was checked against, eid the EID the peer authenticated as
+func (e *Engine) NewResponder(l sarlog.Logger, conn *net.UDPConn, r *request.Request, fname string, udpaddr *net.UDPAddr, eid string) (*Transfer, error) {
 
 	var err error
-	if Lookup(Responder, r.Session, peer) != nil {
-		emsg := fmt.Sprintf("Transfer %s for session %d to %s is currently in progress, cannnot duplicate transfer",
-			Directions[Responder], r.Session, peer)
-		ErrPrintln(g, "red_black", emsg)
-		return errors.New(emsg)
+	if ShuttingDown() {
+		return nil, ErrShuttingDown
 	}
-	var udpaddr *net.UDPAddr
-	if udpaddr, err = net.ResolveUDPAddr("udp", peer); err != nil {
-		return err
-	}
-
-	var tc *net.UDPConn
-	// Dial the peer to create the connection
-	if tc, err = net.DialUDP("udp", nil, udpaddr); err != nil {
-		ErrPrintln(g, "red_black", "Cannot dial peer "+udpaddr.String()+" "+err.Error())
-		return err
+	peer := udpaddr.String()
+	if e.Transfers.Lookup(Responder, r.Session, peer) != nil {
+		l.Err("Transfer already in progress", sarlog.F("direction", Directions[Responder]),
+			sarlog.F("session", r.Session), sarlog.F("peer", peer))
+		return nil, ErrDuplicate
 	}
 
 	// Create the transfer record
-	Trmu.Lock()
-	defer Trmu.Unlock()
 	t := new(Transfer)
+	t.e = e
+	t.start()
 
-	t.Conn = tc
+	t.Conn = conn
 	t.Peer = udpaddr
-	// Lock it as we are going to add a new transfer
 	t.Direction = Responder // We are the Responder
 	t.Session = r.Session
 	// The Header flags set for the transfer
 	t.Version = sarflags.GetStr(r.Header, "version")       // What version of saratoga
 	t.Ttype = sarflags.GetStr(r.Header, "reqtype")         // What is the request type "get,getrm,put,putrm,putblind,rm"
-	t.Fileordir = sarflags.GetStr(r.Header, "fileordir")   // Are we handling a file or directory entry
 	t.Udplite = sarflags.GetStr(r.Header, "udplite")       // Should always be "no"
 	t.Stream = sarflags.GetStr(r.Header, "stream")         // Denotes a named pipe
 	t.Descriptor = sarflags.GetStr(r.Header, "descriptor") // What descriptor we use for the transfer
@@ -1030,68 +1119,90 @@ func NewResponder(g *gocui.Gui, r request.Request, peer string) error {
 	t.Framecount = 0   // No data yet. count of data frames
 	t.Csumtype = ""    // We don't know checksum type until we get a metadata
 	t.Checksum = nil   // Nor do we know what it is
-	t.Filename = r.Fname
+	t.Filename = fname
+	t.Eid = eid
 	t.Tstamptype = "" // Filled out with status or data frame "localinterp,posix32,posix64,posix32_32,posix64_32,epoch2000_32"
 	t.Progress = 0    // Current progress indicator
 	t.Inrespto = 0    // Cururent In response to indicator
 	t.Dir = nil       //
 
-	// flags
-	// property - normalfile, normaldirectory, specialfile, specialdirectory
-	// descriptor - d16, d32, d64, d128
-	// reliability - yes, no
-	var flags string
-
 	switch t.Ttype {
-	case "get", "getrm", "rm": // We are acting on a file local to this system
+	case "get", "take", "getdir": // We are sending a file or directory local to this system
 		// Find the local files metadata to get it's properties
-		if t.Filemeta, err = fileio.FileMeta(t.Filename); err != nil {
-			t.Conn.Close()
-			return err
+		fi, err := os.Stat(e.Path(t.Filename))
+		if err != nil {
+			return nil, err
+		}
+		t.Dir = new(dirent.DirEnt)
+		if err = t.Dir.Info(t.Filename, fi); err != nil {
+			return nil, err
 		}
-		if t.Filemeta.IsDir {
-			flags = sarflags.AddFlag("", "property", "normaldirectory")
-			flags = sarflags.AddFlag(flags, "reliability", "yes")
-		} else if t.Filemeta.IsRegular {
-			flags = sarflags.AddFlag("", "property", "normalfile")
-			flags = sarflags.AddFlag(flags, "reliability", "yes")
-		} else { // specialfile (no such thing as a "specialdirectory")
-			flags = sarflags.AddFlag("", "property", "specialfile")
-			flags = sarflags.AddFlag(flags, "reliability", "no")
-		}
-		flags = sarflags.AddFlag(flags, "descriptor", t.Descriptor)
-
-	case "put", "putrm": // FIX THIS!!!!!!! We are creating/deleting a file local to this system
-		flags = sarflags.AddFlag(flags, "reliability", "yes")
-	case "putblind": // We are putting a file onto this system
-		flags = sarflags.AddFlag(flags, "reliability", "no")
-	}
-	t.Dir = new(dirent.DirEnt)
-	if err = t.Dir.New(flags, t.Filename); err != nil {
-		t.Conn.Close()
-		return err
+	}
+	switch t.Ttype {
+	case "get", "take":
+		if t.Fp, err = os.Open(e.Path(t.Filename)); err != nil {
+			return nil, err
+		}
+	case "getdir": // What we send is the directory entries of what is in it
+		if t.Data, err = listdir(e.Path(t.Filename)); err != nil {
+			return nil, err
+		}
+		t.Dir.Size = uint64(len(t.Data))
+		t.Dir.Header, _ = sarflags.SetD(t.Dir.Header, "descriptor", dirent.Descriptor(t.Dir.Size))
 	}
 	t.Curfills = nil
-	if t.Cliflags, err = sarflags.Cliflag.CopyCliflags(); err != nil {
-		t.Conn.Close()
-		return errors.New("cannot copy CLI flags for transfer")
+	if t.Cliflags, err = e.copyflags(); err != nil {
+		return nil, errors.New("cannot copy CLI flags for transfer")
+	}
+	if sarcrypt.Encrypted(r.Header) {
+		if t.Crypt, err = sarcrypt.New(t.key(), t.Session, auth.Salt(r.Auth)); err != nil {
+			t.close()
+			return nil, err
+		}
 	}
-	t.Data = nil // Buffered data
 
-	msg := fmt.Sprintf("Added %s Transfer to %s session %d",
-		Directions[t.Direction], peer, r.Session)
-	Transfers = append(Transfers, *t)
-	MsgPrintln(g, "green_black", msg)
-	return nil
+	// Another request for the session may have beaten us to it
+	if err = e.Transfers.Add(t); err != nil {
+		t.close()
+		return nil, err
+	}
+	sarlog.Emit(l, sarlog.TransferAdded, t.Fields()...)
+	go t.watch(l)
+	return t, nil
+}
+
+// The directory entries of what is in dir one after the other as a getdir sends them
+func listdir(dir string) ([]byte, error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	for _, de := range des {
+		fi, err := de.Info()
+		if err != nil { // Gone since we read the directory
+			continue
+		}
+		var d dirent.DirEnt
+		if err = d.Info(de.Name(), fi); err != nil {
+			return nil, err
+		}
+		b, err := d.Encode()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
 }
 
 // Info - List transfers in progress to msg window
 func Info(g *gocui.Gui, ttype string) {
-	var tinfo []Transfer
+	var tinfo []*Transfer
 
-	for i := range Transfers {
-		if ttype == "" || Transfers[i].Ttype == ttype {
-			tinfo = append(tinfo, Transfers[i])
+	for _, t := range Transfers.Snapshot() {
+		if ttype == "" || t.Ttype == ttype {
+			tinfo = append(tinfo, t)
 		}
 	}
 	if len(tinfo) > 0 {
@@ -1105,9 +1216,10 @@ func Info(g *gocui.Gui, ttype string) {
 			}
 		}
 		// Table format
-		sfmt := fmt.Sprintf("|%%6s|%%8s|%%%ds|%%%ds|\n", maxaddrlen, maxfname)
-		sborder := fmt.Sprintf(sfmt, strings.Repeat("-", 6), strings.Repeat("-", 8),
-			strings.Repeat("-", maxaddrlen), strings.Repeat("-", maxfname))
+		sfmt := fmt.Sprintf("|%%9s|%%8s|%%%ds|%%10s|%%%ds|%%9s|\n", maxaddrlen, maxfname)
+		sborder := fmt.Sprintf(sfmt, strings.Repeat("-", 9), strings.Repeat("-", 8),
+			strings.Repeat("-", maxaddrlen), strings.Repeat("-", 10), strings.Repeat("-", maxfname),
+			strings.Repeat("-", 9))
 
 		var sslice sort.StringSlice
 		for key := range tinfo {
@@ -1116,7 +1228,7 @@ func Info(g *gocui.Gui, ttype string) {
 		sort.Sort(sslice)
 
 		sbuf := sborder
-		sbuf += fmt.Sprintf(sfmt, "Direct", "Tran Typ", "IP", "Fname")
+		sbuf += fmt.Sprintf(sfmt, "Direction", "Tran Typ", "IP", "Session", "Fname", "State")
 		sbuf += sborder
 		for key := 0; key < len(sslice); key++ {
 			sbuf += sslice[key]
@@ -1132,108 +1244,244 @@ func Info(g *gocui.Gui, ttype string) {
 // WriteStatus -- compose & send status frames
 // Our connection to the client is conn
 // We assemble Status using sflags
-// We transmit status immediately
+// We transmit status immediately, as many frames as it takes to hold the holes
 // We send back a string holding the status error code or "success" keeps transfer alivea
-func (t *Transfer) WriteStatus(g *gocui.Gui, sflags string) string {
+func (t *Transfer) WriteStatus(l sarlog.Logger, sflags string) string {
 
 	if t.Conn == nil {
-		MsgPrintln(g, "cyan_black", "No Connection to write to")
+		l.Err("No Connection to write to")
 		return "badstatus"
 	}
-	MsgPrintln(g, "cyan_black", "Responder Assemble & Send status to ", t.Peer.String())
+	l.Msg("Assemble & Send status", sarlog.F("peer", t.Peer))
 	var maxholes = stpaylen(sflags) // Work out maximum # holes we can put in a single status frame
+	if maxholes <= 0 {
+		l.Err("Cannot fit holes in status", sarlog.F("flags", sflags))
+		return "badstatus"
+	}
 
-	errf := sarflags.FlagValue(sflags, "errcode")
-	var lasthole int
-	h := t.Curfills.Getholes()
-	if errf == "success" {
-		lasthole = len(h) // How many holes do we have
+	Trmu.Lock()
+	sinfo := status.Sinfo{Session: t.Session, Progress: t.Progress, Inrespto: t.Inrespto}
+	var h holes.Holes
+	if sarflags.FlagValue(sflags, "errcode") == "success" {
+		h = t.Curfills.Getholes()
 	}
+	Trmu.Unlock()
 
-	var framecnt int // Number of status frames we will need (at least 1)
-	flags := sflags
-	if lasthole <= maxholes {
-		framecnt = 1
-		flags = sarflags.ReplaceFlag(sflags, "allholes", "yes")
-	} else {
-		h := t.Curfills.Getholes()
-		framecnt = len(h)/maxholes + 1
+	flags := sarflags.ReplaceFlag(sflags, "allholes", "yes")
+	if len(h) > maxholes {
 		flags = sarflags.ReplaceFlag(sflags, "allholes", "no")
 	}
 
 	// Loop through creating and sending the status frames with the holes in them
-	for fc := 0; fc < framecnt; fc++ {
-		starthole := fc * maxholes
-		endhole := starthole + maxholes
-		if endhole > lasthole {
-			endhole = lasthole
-		}
-
-		var st status.Status
-		h := t.Curfills.Getholes()
-		sinfo := status.Sinfo{Session: t.Session, Progress: t.Progress, Inrespto: t.Inrespto, Holes: h}
-		if st.New(flags, &sinfo) != nil {
-			ErrPrintln(g, "red_black", "Cannot asemble status")
+	for starthole := 0; starthole == 0 || starthole < len(h); starthole += maxholes {
+		sinfo.Holes = h[starthole:min(starthole+maxholes, len(h))]
+		st, err := status.New(flags, sinfo)
+		if err != nil {
+			l.Err("Cannot asemble status", sarlog.F("err", err))
 			return "badstatus"
 		}
 		if se := st.Send(t.Conn, t.Peer); se != nil {
-			ErrPrintln(g, "red_black", se.Error())
+			l.Err(se.Error())
 			return "badstatus"
 		}
-		PacketPrintln(g, "cyan_black", "Tx ", st.ShortPrint())
-		MsgPrintln(g, "cyan_black", "Responder Sent Status:", st.Print(),
-			" to ", t.Peer.String())
+		l.Packet("Tx " + st.ShortPrint())
 	}
 	return "success"
 }
 
 // Change - Add metadata information to the Transfer in Transfers list upon receipt of a metadata
-func (t *Transfer) Change(g *gocui.Gui, m metadata.MetaData) error {
+func (t *Transfer) Change(l sarlog.Logger, m metadata.MetaData) error {
 	// Lock it as we are going to add a new transfer slice
 	Trmu.Lock()
 	defer Trmu.Unlock()
+	if t.Havemeta && t.Dir.Size != m.Dir.Size {
+		emsg := fmt.Sprintf("Size of File Differs - Old=%d New=%d",
+			t.Dir.Size, m.Dir.Size)
+		return errors.New(emsg)
+	}
 	t.Csumtype = sarflags.GetStr(m.Header, "csumtype")
 	t.Checksum = make([]byte, len(m.Checksum))
 	copy(t.Checksum, m.Checksum)
 	t.Dir = m.Dir.Copy()
-	// Create the file buffer for the transfer
-	// AT THE MOMENT WE ARE HOLDING THE WHOLE FILE IN A MEMORY BUFFER!!!!
-	// OF COURSE WE NEED TO SORT THIS OUT LATER
-	if len(t.Data) == 0 { // Create the buffer only once
+	// A directory listing is held in memory, files go straight to disk
+	if t.Ttype == "getdir" && len(t.Data) == 0 { // Create the buffer only once
 		t.Data = make([]byte, t.Dir.Size)
 	}
-	if len(t.Data) != (int)(m.Dir.Size) {
-		emsg := fmt.Sprintf("Size of File Differs - Old=%d New=%d",
-			len(t.Data), m.Dir.Size)
-		return errors.New(emsg)
-	}
 	t.Havemeta = true
-	MsgPrintln(g, "yellow_black", "Added metadata to transfer and file buffer size ", len(t.Data))
+	l.Msg("Added metadata to transfer", sarlog.F("session", t.Session), sarlog.F("size", t.Dir.Size))
 	return nil
 }
 
+// Let go of the file and connection of a transfer that never got listed
+func (t *Transfer) close() {
+	if t.Fp != nil {
+		t.Fp.Close()
+		t.Fp = nil
+	}
+	if t.Conn != nil {
+		t.Conn.Close()
+	}
+}
+
 // Remove - Remove a Transfer from the Transfers
 func (t *Transfer) Remove() error {
+	// Stop anything still working on it and let go of its file
+	Trmu.Lock()
+	if t.State == Running { // Nobody is left to finish it
+		t.State = Cancelled
+		t.Errcode = Cancelled
+		close(t.done)
+	}
+	if t.stop != nil {
+		t.stop()
+	}
+	if t.Fp != nil {
+		if !t.Sender() {
+			t.Fp.Sync()
+		}
+		t.Fp.Close()
+		t.Fp = nil
+	}
+	if t.Conn != nil && t.Direction == Initiator { // A responder shares its listener
+		t.Conn.Close()
+	}
+	Trmu.Unlock()
+
+	// Any space held for an incoming file is no longer needed
+	e := t.engine()
+	e.Limits.Release(t.Peer.IP, t.Session)
+
+	if err := e.Transfers.Remove(t); err != nil {
+		return fmt.Errorf("cannot remove %s Transfer for session %d to %s: %w",
+			Directions[t.Direction], t.Session, t.Peer.String(), err)
+	}
+	return nil
+}
+
+// Stop the transfer and close its file, false if it was already stopped
+// The partial file is removed if we were receiving it and removepartial is set
+func (t *Transfer) cancel(l sarlog.Logger, removepartial bool, errcode string) bool {
 	Trmu.Lock()
 	defer Trmu.Unlock()
+	if t.State != Running {
+		return false
+	}
+	t.State = Cancelled
+	t.Errcode = errcode
+	if t.stop != nil {
+		t.stop()
+	}
+	if t.done != nil {
+		close(t.done)
+	}
+	if t.Fp != nil {
+		fname := t.Fp.Name()
+		if !t.Sender() {
+			t.Fp.Sync() // What we have received so far is on disk
+		}
+		if err := t.Fp.Close(); err != nil {
+			l.Err("Cannot close file", sarlog.F("file", fname), sarlog.F("err", err))
+		}
+		t.Fp = nil
+		if removepartial && !t.Sender() {
+			if err := os.Remove(fname); err != nil {
+				l.Err("Cannot remove partial file", sarlog.F("file", fname), sarlog.F("err", err))
+			} else {
+				l.Msg("Removed partial file", sarlog.F("file", fname))
+			}
+		}
+	}
+	t.Data = nil
+	t.engine().Limits.Release(t.Peer.IP, t.Session)
+	return true
+}
 
-	for i := len(Transfers) - 1; i >= 0; i-- {
-		if Lookup(t.Direction, t.Session, t.Peer.String()) != nil {
-			Transfers = append(Transfers[:i], Transfers[i+1:]...)
-			return nil
+// Cancel - Stop the transfer and tell the peer
+// A sender sends metadata with progress terminated, a receiver a status with errcode rxnotinterested
+// The transfer stays listed as cancelled until removed
+func (t *Transfer) Cancel(l sarlog.Logger, removepartial bool) error {
+	if !t.cancel(l, removepartial, Cancelled) {
+		return ErrNotRunning
+	}
+	if t.Conn == nil {
+		return errors.New("no connection to tell peer of cancel")
+	}
+	if t.Sender() {
+		if err := t.sendmeta(l, "terminated"); err != nil {
+			return err
+		}
+	} else {
+		flags := "errcode=rxnotinterested"
+		if t.Crypt != nil {
+			flags += ",encrypt=yes"
+		}
+		st, err := status.New(flags, status.Sinfo{Session: t.Session, Progress: t.Progress, Inrespto: t.Inrespto})
+		if err != nil {
+			return err
+		}
+		if err := st.Send(t.Conn, t.Peer); err != nil {
+			return err
 		}
+		l.Packet("Tx " + st.ShortPrint())
+	}
+	sarlog.Emit(l, sarlog.TransferCancelled, t.Fields()...)
+	return nil
+}
+
+// PeerCancelled - The peer has ended the transfer so stop it without telling the peer
+func (t *Transfer) PeerCancelled(l sarlog.Logger, why string) {
+	if t.cancel(l, false, why) {
+		sarlog.Emit(l, sarlog.TransferPeerEnded, append(t.Fields(), sarlog.F("why", why))...)
+	}
+}
+
+// Stats - Where a transfer has got to
+type Stats struct {
+	State    string      // Running, Completed or Cancelled
+	Errcode  string      // Why it was cancelled
+	Size     uint64      // Of the file, 0 until we have the metadata
+	Progress uint64      // Bytes sent or received so far
+	Inrespto uint64      // Progress the last status was in response to
+	Holes    holes.Holes // Still to be received
+	Started  time.Time
+	Rate     float64 // Bytes a second since started
+}
+
+// Stats - Where the transfer has got to now
+func (t *Transfer) Stats() Stats {
+	Trmu.Lock()
+	defer Trmu.Unlock()
+	s := Stats{State: t.State, Errcode: t.Errcode, Progress: t.Progress, Inrespto: t.Inrespto,
+		Started: t.Started}
+	if t.Dir != nil {
+		s.Size = t.Dir.Size
 	}
-	emsg := fmt.Sprintf("Cannot remove %s Transfer for session %d to %s",
-		Directions[t.Direction], t.Session, t.Peer.String())
-	return errors.New(emsg)
+	if t.Curfills != nil {
+		s.Holes = t.Curfills.Getholes()
+	}
+	if secs := time.Since(t.Started).Seconds(); !t.Started.IsZero() && secs > 0 {
+		s.Rate = float64(t.Progress) / secs
+	}
+	return s
 }
 
 // FmtPrint - String of relevant transfer info
 func (t *Transfer) FmtPrint(sfmt string) string {
-	return fmt.Sprintf(sfmt, "Initiator",
+	Trmu.Lock()
+	state := t.State
+	Trmu.Unlock()
+	return fmt.Sprintf(sfmt, Directions[t.Direction],
 		t.Ttype,
 		t.Peer.String(),
-		t.Filename)
+		strconv.FormatUint(uint64(t.Session), 10),
+		t.Filename,
+		state)
+}
+
+// Fields - The transfer as log and event fields
+func (t *Transfer) Fields() []sarlog.Field {
+	return []sarlog.Field{sarlog.F("direction", Directions[t.Direction]), sarlog.F("ttype", t.Ttype),
+		sarlog.F("peer", t.Peer), sarlog.F("session", t.Session), sarlog.F("file", t.Filename)}
 }
 
 // Print - String of relevant transfer info
@@ -1244,14 +1492,52 @@ func (t *Transfer) Print() string {
 		t.Filename)
 }
 
-// YEAH!!!! Not Doing anything yet
-func (t *Transfer) Do(g *gocui.Gui, e chan error) {
-	MsgPrintln(g, "Doing command - Well actually not doing it!!!!!")
-	ErrPrintln(g, "red_black", "Charles write some code to Do a transfer!!!!")
-	if e != nil {
-		e = nil
+// WriteRequest -- compose & send the request frame that starts an initiators transfer
+// If we hold a key for the peer the request carries the Auth field signed when the transfer was created
+func (t *Transfer) WriteRequest(l sarlog.Logger) error {
+	if t.Conn == nil {
+		return errors.New("no connection to write request to")
+	}
+	r, err := t.request()
+	if err != nil {
+		return err
 	}
-	<-e
+	r.Auth = t.auth
+	buf, err := r.Encode()
+	if err != nil {
+		return err
+	}
+	if _, err = t.Conn.Write(buf); err != nil {
+		return err
+	}
+	l.Packet("Tx " + r.ShortPrint())
+	return nil
+}
+
+// The request for the transfer, without its Auth field
+func (t *Transfer) request() (request.Request, error) {
+	reqtype := t.Ttype
+	if reqtype == "putblind" { // A put as far as the responder is concerned
+		reqtype = "put"
+	}
+	flags := sarflags.Setglobal("request", t.Cliflags)
+	flags = sarflags.ReplaceFlag(flags, "reqtype", reqtype)
+
+	var r request.Request
+	rinfo := request.Rinfo{Session: t.Session, Fname: t.Filename}
+	err := r.New(flags, &rinfo)
+	return r, err
+}
+
+// Do - Start the transfer by sending our request to the peer
+// The rest of the transfer is driven by what the peer sends back
+func (t *Transfer) Do(l sarlog.Logger, e chan error) {
+	if err := t.Context().Err(); err != nil { // Cancelled before we got going
+		e <- err
+		return
+	}
+	l.Msg("Sending "+t.Ttype+" request", sarlog.F("peer", t.Peer))
+	e <- t.WriteRequest(l)
 }
 
 /* ************************************************************************************ */
@@ -1259,114 +1545,224 @@ func (t *Transfer) Do(g *gocui.Gui, e chan error) {
 // These handle I/O to the Screen, write to Err and Msg Windows, read from Cmd Window
 /* ************************************************************************************ */
 
-// Beacon CLI Info
-// beacon <off|V4|V6i|ipaddr> [count]
-type Beaconcmd struct {
-	flags    string        // Header Flags set for beacons
-	count    uint          // How many beacons to send 0|1 == 1
-	interval uint          // interval in seconds between beacons 0|1 == 1
-	v4mcast  bool          // Sending beacons to V4 Multicast
-	v6mcast  bool          // Sending beacons to V6 Multicast
-	hosts    []net.UDPAddr // List of addresses to send beacons to
+// Beacons - The beacons being sent in the background
+var Beacons *beacon.Scheduler
+var beaconsonce sync.Once
+
+// Set up the beacon scheduler, flags are read from the cli flags before every beacon
+func beacons(l sarlog.Logger) *beacon.Scheduler {
+	beaconsonce.Do(func() {
+		flags := func() string {
+			sarflags.Climu.Lock()
+			defer sarflags.Climu.Unlock()
+			return sarflags.Setglobal("beacon", sarflags.Cliflag)
+		}
+		sent := func(s beacon.Schedule, b *beacon.Beacon, err error) {
+			if err != nil {
+				l.Err("Unable to send beacon", sarlog.F("dest", s.Addr), sarlog.F("err", err))
+				return
+			}
+			l.Packet("Tx "+b.ShortPrint(), sarlog.F("dest", s.Addr))
+		}
+		Beacons = beacon.NewScheduler(flags, sent)
+		sarflags.Climu.Lock()
+		Beacons.Eid = sarflags.Cliflag.Eid
+		sarflags.Climu.Unlock()
+	})
+	return Beacons
+}
+
+// Where to send beacons for v4, v6 or an address
+func beacondest(dest string, v4mcast string, v6mcast string) (*net.UDPAddr, error) {
+	switch dest {
+	case "v4":
+		return udpaddress(v4mcast)
+	case "v6":
+		return udpaddress(v6mcast)
+	}
+	return PeerAddress(dest)
+}
+
+// The address of ip on the udpport we have been configured with
+func udpaddress(ip string) (*net.UDPAddr, error) {
+	addr, err := sarnet.UDPAddress(ip)
+	if err != nil {
+		return nil, err
+	}
+	sarflags.Climu.Lock()
+	if sarflags.Cliflag != nil && sarflags.Cliflag.Port != 0 {
+		addr.Port = sarflags.Cliflag.Port
+	}
+	sarflags.Climu.Unlock()
+	return addr, nil
+}
+
+// What the peer alias stands for, the peer itself if it is not one or there is no config
+func unalias(peer string) string {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	if sarflags.Cliflag != nil {
+		if a, ok := sarflags.Cliflag.Aliases[peer]; ok {
+			return a
+		}
+	}
+	return peer
+}
+
+// PeerEid - The EID a peer was given as, directly or by alias, "" if it was given as an address
+func PeerEid(peer string) string {
+	peer = unalias(peer)
+	if eid.Is(peer) {
+		return peer
+	}
+	return ""
 }
 
-var clibeacon Beaconcmd
+// PeerAddress - Where to reach a peer given as an alias, EID, IP address or IP address and port
+// An EID is reached at the address we last heard its beacon from
+func PeerAddress(peer string) (*net.UDPAddr, error) {
+	peer = unalias(peer)
+	if _, _, err := net.SplitHostPort(peer); err == nil { // Not on the saratoga port
+		return net.ResolveUDPAddr("udp", peer)
+	}
+	if eid.Is(peer) {
+		p, ok := beacon.Peers.ByEid(peer)
+		if !ok {
+			return nil, errors.New("no beacon heard from " + peer)
+		}
+		peer = p.Addr
+	}
+	return udpaddress(peer)
+}
 
 // cmdBeacon - Beacon commands
+// beacon [off] [v4|v6|<ip>...]
 func cmdBeacon(g *gocui.Gui, args []string) {
+	sched := beacons(Logger(g))
 
-	// Protects beacon.Beacon structure (EID)
+	// Take a copy of what we need as the scheduler reads the flags while sending
 	sarflags.Climu.Lock()
-	defer sarflags.Climu.Unlock()
-
-	clibeacon.flags = sarflags.Setglobal("beacon", sarflags.Cliflag) // Initialise Global Beacon flags
-	clibeacon.interval = sarflags.Cliflag.Timeout.Binterval          // Set up the correct interval
-	clibeacon.count = sarflags.Cliflag.Bcount
+	count := sarflags.Cliflag.Bcount
+	interval := time.Duration(sarflags.Cliflag.Timeout.Binterval) * time.Second
+	v4mcast := sarflags.Cliflag.V4Multicast
+	v6mcast := sarflags.Cliflag.V6Multicast
+	sarflags.Climu.Unlock()
 
 	if len(args) == 1 { // beacon
-		if !clibeacon.v4mcast && !clibeacon.v6mcast && len(clibeacon.hosts) == 0 {
+		list := sched.List()
+		if len(list) == 0 {
 			MsgPrintln(g, "yellow_black", "No beacons currently being sent")
 			return
 		}
-		if clibeacon.v4mcast {
-			MsgPrintf(g, "yellow_black", "Sending %d IPv4 multicast beacons\n", clibeacon.count)
-		}
-		if clibeacon.v6mcast {
-			MsgPrintf(g, "yellow_black", "Sending %d IPv6 multicast beacons\n", clibeacon.count)
-		}
-		if len(clibeacon.hosts) > 0 {
-			MsgPrintf(g, "cyan_black", "Sending %d beacons to:", clibeacon.count)
-			for _, h := range clibeacon.hosts {
-				MsgPrintln(g, "cyan_black", "\t", h.String())
+		for _, s := range list {
+			if s.Forever() {
+				MsgPrintf(g, "yellow_black", "%s %s sent %d beacons every %s until stopped\n",
+					s.Dest, s.Addr.String(), s.Sent, s.Interval)
+			} else {
+				MsgPrintf(g, "yellow_black", "%s %s sent %d of %d beacons every %s\n",
+					s.Dest, s.Addr.String(), s.Sent, s.Count, s.Interval)
 			}
 		}
 		return
 	}
-	if len(args) == 2 {
-		switch args[1] {
-		case "?": // beacon ?
-			MsgPrintln(g, "magenta_black", prhelp("beacon"))
-			MsgPrintln(g, "green_black", prusage("beacon"))
-			return
-		case "off": // All beacons off
-			clibeacon.flags = sarflags.Setglobal("beacon", sarflags.Cliflag)
+	switch args[1] {
+	case "?": // beacon ?
+		MsgPrintln(g, "magenta_black", prhelp("beacon"))
+		MsgPrintln(g, "green_black", prusage("beacon"))
+		return
+	case "off":
+		if len(args) == 2 { // All beacons off
+			sched.StopAll()
 			MsgPrintln(g, "green_black", "Beacons Disabled")
-			// remove and disable all beacons
-			clibeacon.hosts = nil
-			return
-		case "v4": // beacon v4
-			// V4 Multicast
-			MsgPrintln(g, "cyan_black", "Sending beacon to IPv4 Multicast")
-			clibeacon.flags = sarflags.Setglobal("beacon", sarflags.Cliflag)
-			clibeacon.v4mcast = true
-			// Start up the beacon client sending count IPv4 beacons
-			if addr, err := sarnet.UDPAddress(sarflags.Cliflag.V4Multicast); err == nil {
-				sendbeacons(g, clibeacon, addr)
-			}
 			return
-		case "v6": // beacon v6
-			// V6 Multicast
-			MsgPrintln(g, "cyan_black", "Sending beacon to IPv6 Multicast")
-			clibeacon.flags = sarflags.Setglobal("beacon", sarflags.Cliflag)
-			clibeacon.v6mcast = true
-			if addr, err := sarnet.UDPAddress(sarflags.Cliflag.V6Multicast); err == nil {
-				// Start up the beacon client sending count IPv6 beacons
-				sendbeacons(g, clibeacon, addr)
+		}
+		for _, dest := range args[2:] {
+			if addr, err := beacondest(dest, v4mcast, v6mcast); err == nil && dest != "v4" && dest != "v6" {
+				dest = addr.String()
 			}
-			return
-		default: // beacon <count> or beacon <ipaddr>
-			// beacon ipaddr
-			if addr, err := sarnet.UDPAddress(args[1]); err == nil {
-				MsgPrintln(g, "green_black", "Sending ", clibeacon.count,
-					" beacons to ", addr.String())
-				sendbeacons(g, clibeacon, addr)
-				return
+			if sched.Stop(dest) {
+				MsgPrintln(g, "green_black", "Beacons to ", dest, " stopped")
+			} else {
+				ErrPrintln(g, "red_black", "No beacons being sent to ", dest)
 			}
-			ErrPrintln(g, "red_black", "Invalid IP Address:", args[1])
-			ErrPrintln(g, "red_black", prusage("beacon"))
-			return
 		}
+		return
 	}
-	for i := 2; i < len(args); i++ {
-		if args[1] == "off" {
-			if addr, err := sarnet.UDPAddress(args[i]); err == nil {
-				clibeacon.hosts = sarnet.RemoveUDPAddrValue(clibeacon.hosts, addr)
-				continue
-			}
+	for _, dest := range args[1:] {
+		addr, err := beacondest(dest, v4mcast, v6mcast)
+		if err != nil {
+			ErrPrintln(g, "red_black", "Unknown peer:", dest)
+			ErrPrintln(g, "red_black", prusage("beacon"))
+			continue
 		}
-		// Otherwise we have more args than 1 so send the beacon from the first arg
-		if addr, err := sarnet.UDPAddress(args[i]); err == nil {
-			MsgPrintln(g, "green_black", "Sending ", clibeacon.count, " beacons to ", addr.String())
-			sendbeacons(g, clibeacon, addr)
+		if dest != "v4" && dest != "v6" {
+			dest = addr.String()
+		}
+		if err := sched.Start(dest, addr, count, interval); err != nil {
+			ErrPrintln(g, "red_black", "Error:", err.Error(), " Unable to Dial ", addr.String())
 			continue
 		}
-		ErrPrintln(g, "red_black", "Invalid IP Address:", args[i])
-		ErrPrintln(g, "red_black", prusage("beacon"))
+		if count == 0 && interval > 0 {
+			MsgPrintln(g, "cyan_black", "Sending beacons to ", dest, " every ", interval, " until stopped")
+		} else {
+			MsgPrintln(g, "cyan_black", "Sending ", count, " beacons to ", dest)
+		}
 	}
 }
 
+// cmdCancel - Stop transfers and tell their peers, rm removes what we have received so far
+// cancel <peer> <session> [rm] | cancel all [rm]
 func cmdCancel(g *gocui.Gui, args []string) {
-	MsgPrintln(g, "green_black", args)
+	removepartial := len(args) > 2 && args[len(args)-1] == "rm"
+	if removepartial {
+		args = args[:len(args)-1]
+	}
+	var cancel []*Transfer
+	switch len(args) {
+	case 2:
+		switch args[1] {
+		case "?":
+			MsgPrintln(g, "magenta_black", prhelp("cancel"))
+			MsgPrintln(g, "green_black", prusage("cancel"))
+			return
+		case "all":
+			cancel = engine(g).Transfers.Snapshot()
+		default:
+			ErrPrintln(g, "red_black", prusage("cancel"))
+			return
+		}
+	case 3:
+		udpad, err := PeerAddress(args[1])
+		if err != nil {
+			ErrPrintln(g, "red_black", "Unknown peer:", args[1])
+			return
+		}
+		// We are unsigned so Atoi does not cut it
+		session, err := strconv.ParseUint(args[2], 10, 32)
+		if err != nil {
+			ErrPrintln(g, "red_black", prusage("cancel"))
+			return
+		}
+		if cancel = engine(g).Transfers.Find(udpad.IP, uint32(session)); len(cancel) == 0 {
+			ErrPrintln(g, "red_black", "No such transfer:", args[1], " ", args[2])
+			return
+		}
+	default:
+		ErrPrintln(g, "red_black", prusage("cancel"))
+		return
+	}
+	n := 0
+	for _, t := range cancel {
+		err := t.Cancel(Logger(g), removepartial)
+		if err == ErrNotRunning {
+			continue
+		}
+		n++
+		if err != nil {
+			ErrPrintln(g, "red_black", "Cancelled ", t.Print(), " but could not tell peer: ", err)
+		}
+	}
+	MsgPrintln(g, "green_black", n, " transfers cancelled")
 }
 
 func cmdChecksum(g *gocui.Gui, args []string) {
@@ -1497,33 +1893,30 @@ func cmdDescriptor(g *gocui.Gui, args []string) {
 	ErrPrintln(g, "red_black", "usage:", prusage("descriptor"))
 }
 
-// Cexit = Exit level to quit from saratoga
-var Cexit = -1
-
-// cmdExit -- Quit saratoga
+// cmdExit -- Quit saratoga once transfers in progress finish or the shutdown timeout passes
+// exit [0|1] [now]
 func cmdExit(g *gocui.Gui, args []string) {
-	switch len(args) {
-	case 1: // exit 0
-		Cexit = 0
-		MsgPrintln(g, "green_black", "Good Bye!")
-		return
-	case 2:
-		switch args[1] {
+	code := ExitOK
+	now := false
+	for _, arg := range args[1:] {
+		switch arg {
 		case "?": // Usage
 			MsgPrintln(g, "magenta_black", prhelp("exit"))
 			MsgPrintln(g, "green_black", prusage("exit"))
-		case "0": // exit 0
-			Cexit = 0
-			MsgPrintln(g, "green_black", "Good Bye!")
-		case "1": // exit 1
-			Cexit = 1
-			MsgPrintln(g, "green_black", "Good Bye!")
+			return
+		case "0":
+			code = ExitOK
+		case "1":
+			code = ExitError
+		case "now":
+			now = true
 		default: // Help
 			ErrPrintln(g, "red_black", prusage("exit"))
+			return
 		}
-	default:
-		ErrPrintln(g, "red_black", prusage("exit"))
 	}
+	MsgPrintln(g, "green_black", "Good Bye!")
+	RequestExit(code, now)
 }
 
 // cmdFiiles -- show currently open files and transfers in progress
@@ -1589,10 +1982,12 @@ func cmdGet(g *gocui.Gui, args []string) {
 	case 3:
 		// var t transfer.CTransfer
 
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if _, err := NewInitiator(g, "get", udpad, args[2], sarflags.Cliflag); err != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if _, err := initiate(g, "get", udpad, PeerEid(args[1]), args[2]); err != nil {
 				return
 			}
+		} else {
+			ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 		}
 		return
 	}
@@ -1612,13 +2007,13 @@ func cmdGetdir(g *gocui.Gui, args []string) {
 			return
 		}
 	case 3:
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if _, err := NewInitiator(g, "getdir", udpad, args[2], sarflags.Cliflag); err != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if _, err := initiate(g, "getdir", udpad, PeerEid(args[1]), args[2]); err != nil {
 				MsgPrintln(g, "magenta_black", prhelp("getdir"))
 				ErrPrintln(g, "green_black", prusage("getdir"))
 			}
 		} else {
-			ErrPrintln(g, "green_black", "Invalid IP Address:", args[1])
+			ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 		}
 		return
 	}
@@ -1638,13 +2033,13 @@ func cmdTake(g *gocui.Gui, args []string) {
 			return
 		}
 	case 3:
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if _, err := NewInitiator(g, "take", udpad, args[2], sarflags.Cliflag); err != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if _, err := initiate(g, "take", udpad, PeerEid(args[1]), args[2]); err != nil {
 				MsgPrintln(g, "magenta_black", prhelp("take"))
 				ErrPrintln(g, "green_black", prusage("take"))
 			}
 		} else {
-			ErrPrintln(g, "green_black", "Invalid IP Address:", args[1])
+			ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 		}
 		return
 	}
@@ -1694,6 +2089,78 @@ func cmdHelp(g *gocui.Gui, args []string) {
 	}
 }
 
+// cmdAcl - Show the access control rules applied to incoming requests
+func cmdAcl(g *gocui.Gui, args []string) {
+	switch len(args) {
+	case 1:
+		MsgPrintln(g, "yellow_black", engine(g).Access.Print())
+		return
+	case 2:
+		if args[1] == "?" {
+			MsgPrintln(g, "magenta_black", prhelp("acl"))
+			MsgPrintln(g, "green_black", prusage("acl"))
+			return
+		}
+	}
+	ErrPrintln(g, "red_black", prusage("acl"))
+}
+
+// cmdAlias - Show, set or remove names for peers
+// alias [<name> [<eid>|<ip>|off]]
+func cmdAlias(g *gocui.Gui, args []string) {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+
+	switch len(args) {
+	case 1:
+		if len(sarflags.Cliflag.Aliases) == 0 {
+			MsgPrintln(g, "green_black", "No aliases")
+			return
+		}
+		var names []string
+		for a := range sarflags.Cliflag.Aliases {
+			names = append(names, a)
+		}
+		sort.Strings(names)
+		for _, a := range names {
+			MsgPrintln(g, "green_black", a, " ", sarflags.Cliflag.Aliases[a])
+		}
+		return
+	case 2:
+		if args[1] == "?" {
+			MsgPrintln(g, "magenta_black", prhelp("alias"))
+			MsgPrintln(g, "green_black", prusage("alias"))
+			return
+		}
+		if p, ok := sarflags.Cliflag.Aliases[args[1]]; ok {
+			MsgPrintln(g, "green_black", args[1], " ", p)
+		} else {
+			ErrPrintln(g, "red_black", "No alias ", args[1])
+		}
+		return
+	case 3:
+		if args[2] == "off" {
+			delete(sarflags.Cliflag.Aliases, args[1])
+			return
+		}
+		if eid.Is(args[2]) {
+			if err := eid.Valid(args[2]); err != nil {
+				ErrPrintln(g, "red_black", args[2], " ", err.Error())
+				return
+			}
+		} else if net.ParseIP(args[2]) == nil {
+			ErrPrintln(g, "red_black", "Alias must be for an EID or IP address:", args[2])
+			return
+		}
+		if sarflags.Cliflag.Aliases == nil {
+			sarflags.Cliflag.Aliases = make(map[string]string)
+		}
+		sarflags.Cliflag.Aliases[args[1]] = args[2]
+		return
+	}
+	ErrPrintln(g, "red_black", prusage("alias"))
+}
+
 func cmdBcount(g *gocui.Gui, args []string) {
 	sarflags.Climu.Lock()
 	defer sarflags.Climu.Unlock()
@@ -1805,39 +2272,53 @@ func cmdLs(g *gocui.Gui, args []string) {
 func cmdPeers(g *gocui.Gui, args []string) {
 	switch len(args) {
 	case 1:
-		if len(beacon.Peers) == 0 {
+		peers := engine(g).Peers.Snapshot()
+		if len(peers) == 0 {
 			MsgPrintln(g, "green_black", "No Peers")
 			return
 		}
+		now := time.Now()
 		// Table format
 		// Work out the max length of each field
-		var addrlen, eidlen, dcrelen, dmodlen, cantxlen, canrxlen int
-		for p := range beacon.Peers {
-			if len(beacon.Peers[p].Addr) > addrlen {
-				addrlen = len(beacon.Peers[p].Addr)
+		var addrlen, eidlen, dcrelen, dmodlen, cantxlen, canrxlen, agelen, statelen int
+		for p := range peers {
+			if len(strings.Join(peers[p].Addrs, ",")) > addrlen {
+				addrlen = len(strings.Join(peers[p].Addrs, ","))
+			}
+			if len(peers[p].Eid) > eidlen {
+				eidlen = len(peers[p].Eid)
 			}
-			if len(beacon.Peers[p].Eid) > eidlen {
-				eidlen = len(beacon.Peers[p].Eid)
+			if len(peers[p].Canrx) > canrxlen {
+				canrxlen = len(peers[p].Canrx)
 			}
-			if len(beacon.Peers[p].Canrx) > canrxlen {
-				canrxlen = len(beacon.Peers[p].Canrx)
+			if len(peers[p].Cantx) > cantxlen {
+				cantxlen = len(peers[p].Cantx)
 			}
-			if len(beacon.Peers[p].Cantx) > cantxlen {
-				cantxlen = len(beacon.Peers[p].Cantx)
+			if len(peers[p].Created.Print()) > dcrelen {
+				dcrelen = len(peers[p].Created.Print())
 			}
-			if len(beacon.Peers[p].Created.Print()) > dcrelen {
-				dcrelen = len(beacon.Peers[p].Created.Print())
+			if len(peers[p].Updated.Print()) > dmodlen {
+				dmodlen = len(peers[p].Updated.Print())
 			}
-			if len(beacon.Peers[p].Created.Print()) > dmodlen {
-				dmodlen = len(beacon.Peers[p].Updated.Print())
+			if len(peerage(&peers[p], now)) > agelen {
+				agelen = len(peerage(&peers[p], now))
+			}
+			if len(peers[p].State) > statelen {
+				statelen = len(peers[p].State)
 			}
 		}
 		if eidlen < 3 {
 			eidlen = 3
 		}
+		if agelen < 3 {
+			agelen = 3
+		}
+		if statelen < 5 {
+			statelen = 5
+		}
 
-		bfmt := fmt.Sprintf("+%%%ds+%%6s+%%%ds+%%3s+%%%ds+%%%ds+%%%ds+%%%ds+\n",
-			addrlen, eidlen, canrxlen, cantxlen, dcrelen, dmodlen)
+		bfmt := fmt.Sprintf("+%%%ds+%%6s+%%%ds+%%3s+%%%ds+%%%ds+%%%ds+%%%ds+%%%ds+%%%ds+\n",
+			addrlen, eidlen, canrxlen, cantxlen, dcrelen, dmodlen, agelen, statelen)
 		sborder := fmt.Sprintf(bfmt,
 			strings.Repeat("-", addrlen),
 			strings.Repeat("-", 6),
@@ -1846,26 +2327,30 @@ func cmdPeers(g *gocui.Gui, args []string) {
 			strings.Repeat("-", canrxlen),
 			strings.Repeat("-", cantxlen),
 			strings.Repeat("-", dcrelen),
-			strings.Repeat("-", dmodlen))
+			strings.Repeat("-", dmodlen),
+			strings.Repeat("-", agelen),
+			strings.Repeat("-", statelen))
 
-		sfmt := fmt.Sprintf("|%%%ds|%%6s|%%%ds|%%3s|%%%ds|%%%ds|%%%ds|%%%ds|\n",
-			addrlen, eidlen, canrxlen, cantxlen, dcrelen, dmodlen)
+		sfmt := fmt.Sprintf("|%%%ds|%%6s|%%%ds|%%3s|%%%ds|%%%ds|%%%ds|%%%ds|%%%ds|%%%ds|\n",
+			addrlen, eidlen, canrxlen, cantxlen, dcrelen, dmodlen, agelen, statelen)
 		var sslice sort.StringSlice
-		for key := range beacon.Peers {
-			pinfo := fmt.Sprintf(sfmt, beacon.Peers[key].Addr,
-				strconv.Itoa(int(beacon.Peers[key].Freespace/1024/1024)),
-				beacon.Peers[key].Eid,
-				beacon.Peers[key].Maxdesc,
-				beacon.Peers[key].Canrx,
-				beacon.Peers[key].Cantx,
-				beacon.Peers[key].Created.Print(),
-				beacon.Peers[key].Updated.Print())
+		for key := range peers {
+			pinfo := fmt.Sprintf(sfmt, strings.Join(peers[key].Addrs, ","),
+				strconv.Itoa(int(peers[key].Freespace/1024/1024)),
+				peers[key].Eid,
+				peers[key].Maxdesc,
+				peers[key].Canrx,
+				peers[key].Cantx,
+				peers[key].Created.Print(),
+				peers[key].Updated.Print(),
+				peerage(&peers[key], now),
+				peers[key].State)
 			sslice = append(sslice, pinfo)
 		}
 		sort.Sort(sslice)
 
 		sbuf := sborder
-		sbuf += fmt.Sprintf(sfmt, "IP", "GB", "EID", "Des", "Rx", "Tx", "Created", "Modified")
+		sbuf += fmt.Sprintf(sfmt, "IP", "GB", "EID", "Des", "Rx", "Tx", "Created", "Modified", "Age", "State")
 		sbuf += sborder
 		for key := 0; key < len(sslice); key++ {
 			sbuf += sslice[key]
@@ -1885,6 +2370,11 @@ func cmdPeers(g *gocui.Gui, args []string) {
 	}
 }
 
+// Time since the last beacon from the peer to the nearest second
+func peerage(p *beacon.Peer, now time.Time) string {
+	return p.Age(now).Round(time.Second).String()
+}
+
 // Initiator _put_
 // send a file to a destination
 func cmdPut(g *gocui.Gui, args []string) {
@@ -1900,10 +2390,10 @@ func cmdPut(g *gocui.Gui, args []string) {
 			return
 		}
 	case 3:
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if t, err := NewInitiator(g, "put", udpad, args[2], sarflags.Cliflag); err == nil && t != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if t, err := initiate(g, "put", udpad, PeerEid(args[1]), args[2]); err == nil && t != nil {
 				errflag := make(chan error, 1) // The return channel holding the saratoga errflag
-				go t.Do(g, errflag)            // Actually do the transfer
+				go t.Do(Logger(g), errflag)    // Actually do the transfer
 				errcode := <-errflag
 				if errcode != nil {
 					ErrPrintln(g, "red_black", "Error:", errcode,
@@ -1920,7 +2410,7 @@ func cmdPut(g *gocui.Gui, args []string) {
 			ErrPrintln(g, "green_black", prusage("getrm"))
 			return
 		}
-		ErrPrintln(g, "green_black", "Invalid IP Address:", args[1])
+		ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 	}
 	ErrPrintln(g, "red_black", prusage("put"))
 }
@@ -1941,10 +2431,10 @@ func cmdPutblind(g *gocui.Gui, args []string) {
 		}
 	case 3:
 		// We send the Metadata and do not bother with request/status exchange
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if t, err := NewInitiator(g, "putblind", udpad, args[2], sarflags.Cliflag); err == nil && t != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if t, err := initiate(g, "putblind", udpad, PeerEid(args[1]), args[2]); err == nil && t != nil {
 				errflag := make(chan error, 1) // The return channel holding the saratoga errflag
-				go t.Do(g, errflag)            // Actually do the transfer
+				go t.Do(Logger(g), errflag)    // Actually do the transfer
 				errcode := <-errflag
 				if errcode != nil {
 					ErrPrintln(g, "red_black", "Error:", errcode,
@@ -1961,7 +2451,7 @@ func cmdPutblind(g *gocui.Gui, args []string) {
 			ErrPrintln(g, "green_black", prusage("putblind"))
 			return
 		}
-		ErrPrintln(g, "green_black", "Invalid IP Address:", args[1])
+		ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 	}
 	ErrPrintln(g, "red_black", prusage("putblind"))
 }
@@ -1982,10 +2472,10 @@ func cmdGive(g *gocui.Gui, args []string) {
 		}
 	case 3:
 		// var t *transfer.Transfer
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if t, err := NewInitiator(g, "give", udpad, args[2], sarflags.Cliflag); err == nil && t != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if t, err := initiate(g, "give", udpad, PeerEid(args[1]), args[2]); err == nil && t != nil {
 				errflag := make(chan error, 1) // The return channel holding the saratoga errflag
-				go t.Do(g, errflag)            // Actually do the transfer
+				go t.Do(Logger(g), errflag)    // Actually do the transfer
 				errcode := <-errflag
 				if errcode != nil {
 					ErrPrintln(g, "red_black", "Error:", errcode,
@@ -1999,7 +2489,7 @@ func cmdGive(g *gocui.Gui, args []string) {
 				MsgPrintln(g, "red_black", "Give and remove (ADD MORE CODE  HERE!!!!) local file:", t.Print())
 				return
 			}
-			ErrPrintln(g, "green_black", "Invalid IP Address:", args[1])
+			ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 		}
 		ErrPrintln(g, "red_black", prusage("putblind"))
 	}
@@ -2035,6 +2525,36 @@ func cmdReqtstamp(g *gocui.Gui, args []string) {
 	ErrPrintln(g, "red_black", "usage:", prusage("reqtstamp"))
 }
 
+// cmdEncrypt - Encrypt our transfers and refuse unencrypted requests
+func cmdEncrypt(g *gocui.Gui, args []string) {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+
+	switch len(args) {
+	case 1:
+		if sarflags.Cliflag.Global["encrypt"] == "yes" {
+			MsgPrintln(g, "green_black", "Transfers encrypted")
+		} else {
+			MsgPrintln(g, "green_black", "Transfers not encrypted")
+		}
+		return
+	case 2:
+		switch args[1] {
+		case "?": // usage
+			MsgPrintln(g, "magenta_black", prhelp("encrypt"))
+			MsgPrintln(g, "green_black", prusage("encrypt"))
+			return
+		case "yes":
+			sarflags.Cliflag.Global["encrypt"] = "yes"
+			return
+		case "no":
+			sarflags.Cliflag.Global["encrypt"] = "no"
+			return
+		}
+	}
+	ErrPrintln(g, "red_black", "usage:", prusage("encrypt"))
+}
+
 // Initiator _delete_
 // remove a file from a remote destination
 func cmdDelete(g *gocui.Gui, args []string) {
@@ -2050,10 +2570,10 @@ func cmdDelete(g *gocui.Gui, args []string) {
 			return
 		}
 	case 3:
-		if udpad, err := sarnet.UDPAddress(args[1]); err == nil {
-			if t, err := NewInitiator(g, "delete", udpad, args[2], sarflags.Cliflag); err == nil && t != nil {
+		if udpad, err := PeerAddress(args[1]); err == nil {
+			if t, err := initiate(g, "delete", udpad, PeerEid(args[1]), args[2]); err == nil && t != nil {
 				errflag := make(chan error, 1) // The return channel holding the saratoga errflag
-				go t.Do(g, errflag)            // Actually do the transfer
+				go t.Do(Logger(g), errflag)    // Actually do the transfer
 				errcode := <-errflag
 				if errcode != nil {
 					ErrPrintln(g, "red_black", "Error:", errcode,
@@ -2070,7 +2590,7 @@ func cmdDelete(g *gocui.Gui, args []string) {
 			ErrPrintln(g, "green_black", prusage("delete"))
 			return
 		}
-		ErrPrintln(g, "green_black", "Invalid IP Address:", args[1])
+		ErrPrintln(g, "green_black", "Unknown peer:", args[1])
 	}
 	ErrPrintln(g, "red_black", prusage("delete"))
 }
@@ -2091,6 +2611,9 @@ func cmdRmtran(g *gocui.Gui, args []string) {
 	case 4:
 		ttype := args[1]
 		addr := args[2]
+		if udpad, err := PeerAddress(addr); err == nil {
+			addr = udpad.String()
+		}
 		// We are unsigned so Atoi does not cut it
 		if session, err := strconv.ParseUint(args[3], 10, 32); err == nil {
 			if t := Match(addr, uint32(session)); t != nil {
@@ -2198,6 +2721,8 @@ func cmdTimeout(g *gocui.Gui, args []string) {
 		} else {
 			MsgPrintln(g, "green_black", "transfer:", sarflags.Cliflag.Timeout.Transfer, " sec")
 		}
+		prpeerexpiry(g)
+		prshutdown(g)
 		return
 	case 2:
 		switch args[1] {
@@ -2235,6 +2760,10 @@ func cmdTimeout(g *gocui.Gui, args []string) {
 			} else {
 				MsgPrintln(g, "green_black", "transfer:", sarflags.Cliflag.Timeout.Transfer, " sec")
 			}
+		case "peerexpiry":
+			prpeerexpiry(g)
+		case "shutdown":
+			prshutdown(g)
 		default:
 			ErrPrintln(g, "red_black", prusage("timeout"))
 		}
@@ -2278,6 +2807,12 @@ func cmdTimeout(g *gocui.Gui, args []string) {
 				} else {
 					MsgPrintln(g, "green_black", "transfer:", sarflags.Cliflag.Timeout.Transfer, " sec")
 				}
+			case "peerexpiry":
+				sarflags.Cliflag.Timeout.Peerexpiry = n
+				prpeerexpiry(g)
+			case "shutdown":
+				sarflags.Cliflag.Timeout.Shutdown = n
+				prshutdown(g)
 			default:
 				ErrPrintln(g, "red_black", prusage("timeout"))
 			}
@@ -2300,6 +2835,12 @@ func cmdTimeout(g *gocui.Gui, args []string) {
 			case "transfer":
 				sarflags.Cliflag.Timeout.Transfer = 60
 				MsgPrintln(g, "green_black", "transfer:", sarflags.Cliflag.Timeout.Transfer, " sec")
+			case "peerexpiry":
+				sarflags.Cliflag.Timeout.Peerexpiry = 0
+				prpeerexpiry(g)
+			case "shutdown":
+				sarflags.Cliflag.Timeout.Shutdown = 0
+				prshutdown(g)
 			}
 			return
 		}
@@ -2309,6 +2850,39 @@ func cmdTimeout(g *gocui.Gui, args []string) {
 	ErrPrintln(g, "red_black", prusage("timeout"))
 }
 
+// Show the peer expiry, Climu must be held
+func prpeerexpiry(g *gocui.Gui) {
+	if sarflags.Cliflag.Timeout.Peerexpiry == 0 {
+		MsgPrintln(g, "green_black", "peerexpiry:Never")
+	} else {
+		MsgPrintln(g, "green_black", "peerexpiry:", sarflags.Cliflag.Timeout.Peerexpiry, " beacon intervals")
+	}
+}
+
+// Show how long exit waits for transfers, Climu must be held
+func prshutdown(g *gocui.Gui) {
+	if sarflags.Cliflag.Timeout.Shutdown == 0 {
+		MsgPrintln(g, "green_black", "shutdown:Cancel transfers immediately")
+	} else {
+		MsgPrintln(g, "green_black", "shutdown:", sarflags.Cliflag.Timeout.Shutdown, " sec")
+	}
+}
+
+// ShutdownTimeout - How long exit waits for transfers to finish before cancelling them
+func ShutdownTimeout() time.Duration {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	return time.Duration(sarflags.Cliflag.Timeout.Shutdown) * time.Second
+}
+
+// PeerExpiry - How long without a beacon before a peer is lost, 0 is never
+func PeerExpiry() time.Duration {
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	return time.Duration(sarflags.Cliflag.Timeout.Peerexpiry) *
+		time.Duration(sarflags.Cliflag.Timeout.Binterval) * time.Second
+}
+
 // set the timestamp type we are using
 func cmdTimestamp(g *gocui.Gui, args []string) {
 	sarflags.Climu.Lock()
@@ -2420,10 +2994,10 @@ func cmdTxwilling(g *gocui.Gui, args []string) {
 			MsgPrintln(g, "green_black", prusage("txwilling"))
 			return
 		case "on":
-			sarflags.Cliflag.Global["txwilling"] = "on"
+			sarflags.Cliflag.Global["txwilling"] = "yes"
 			return
 		case "off":
-			sarflags.Cliflag.Global["txwilling"] = "off"
+			sarflags.Cliflag.Global["txwilling"] = "no"
 			return
 		case "capable":
 			sarflags.Cliflag.Global["txwilling"] = "capable"
@@ -2458,6 +3032,8 @@ type cmdfunc func(*gocui.Gui, []string)
 // Commands and function pointers to handle them
 var cmdhandler = map[string]cmdfunc{
 	"?":          cmdHelp,
+	"acl":        cmdAcl,
+	"alias":      cmdAlias,
 	"beacon":     cmdBeacon,
 	"bcount":     cmdBcount,
 	"cancel":     cmdCancel,
@@ -2465,6 +3041,7 @@ var cmdhandler = map[string]cmdfunc{
 	"clear":      cmdClear,
 	"delete":     cmdDelete, // _delete_
 	"descriptor": cmdDescriptor,
+	"encrypt":    cmdEncrypt,
 	"exit":       cmdExit,
 	"files":      cmdFiles,
 	"freespace":  cmdFreespace,
@@ -2493,29 +3070,45 @@ var cmdhandler = map[string]cmdfunc{
 	"usage":      cmdUsage,
 }
 
+// ErrInvalidCommand - There is no such command
+var ErrInvalidCommand = errors.New("invalid command")
+
+// Lookup the command in line, its handler and arguments
+func command(line string) (cmdfunc, []string, error) {
+	// Get rid of leading and trailing whitespace
+	vals := strings.Fields(strings.TrimSpace(line))
+	if len(vals) == 0 {
+		return nil, vals, ErrInvalidCommand
+	}
+	// Lookup the cmd and index func to run via cmdhandler map
+	if _, ok := sarflags.Commands[vals[0]]; ok {
+		if fn, ok := cmdhandler[vals[0]]; ok {
+			return fn, vals, nil
+		}
+	}
+	return nil, vals, ErrInvalidCommand
+}
+
 // Lookup and run the command
 func Run(g *gocui.Gui, name string) bool {
-	if name == "" { // Handle just return
+	if strings.TrimSpace(name) == "" { // Handle just return
 		return true
 	}
-	// for k, v := range c.Global {
-	//	MsgPrintln(g, "white_red", k, "=", v)
-	// }
-	// MsgPrintln(g, "white_black", "Commands=", sarflags.Commands)
-	// Get rid of leading and trailing whitespace
-	s := strings.TrimSpace(name)
-	vals := strings.Fields(s)
-	// Lookup the cmd and index func to run via cmdhandler map
-	for key := range sarflags.Commands {
-		if key == vals[0] {
-			fn, ok := cmdhandler[key]
-			if ok {
-				go fn(g, vals)
-				return true
-			}
-			ErrPrintln(g, "red_black", "Invalid command:", vals[0])
-		}
+	fn, vals, err := command(name)
+	if err != nil {
+		ErrPrintln(g, "red_black", "Invalid command:", vals[0])
+		return false
 	}
-	ErrPrintln(g, "red_black", "Invalid command:", vals[0])
-	return false
+	go fn(g, vals)
+	return true
+}
+
+// Exec - Lookup and run the command, returning once it has finished
+func Exec(g *gocui.Gui, line string) error {
+	fn, vals, err := command(line)
+	if err != nil {
+		return err
+	}
+	fn(g, vals)
+	return nil
 }
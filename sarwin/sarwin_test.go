@@ -0,0 +1,625 @@
+package sarwin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/data"
+	"github.com/charlesetsmith/saratoga/dirent"
+	"github.com/charlesetsmith/saratoga/holes"
+	"github.com/charlesetsmith/saratoga/metadata"
+	"github.com/charlesetsmith/saratoga/metrics"
+	"github.com/charlesetsmith/saratoga/request"
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
+	"github.com/charlesetsmith/saratoga/status"
+)
+
+// recorder - A sarlog.Logger keeping the kinds of the events it is sent
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) Msg(string, ...sarlog.Field)    {}
+func (r *recorder) Err(string, ...sarlog.Field)    {}
+func (r *recorder) Packet(string, ...sarlog.Field) {}
+
+func (r *recorder) Event(e sarlog.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e.Kind)
+}
+
+func (r *recorder) kinds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	var added, removed atomic.Int64
+	r.OnAdd(func(*Transfer) { added.Add(1) })
+	r.OnRemove(func(*Transfer) { removed.Add(1) })
+
+	peer := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 7542}
+	tr := &Transfer{Direction: Initiator, Session: 1, Peer: peer, Ttype: "get"}
+	if err := r.Add(tr); err != nil {
+		t.Fatal(err)
+	}
+	// Same key is a duplicate, other direction is not
+	if err := r.Add(&Transfer{Direction: Initiator, Session: 1, Peer: peer}); err != ErrDuplicate {
+		t.Errorf("duplicate add %v", err)
+	}
+	resp := &Transfer{Direction: Responder, Session: 1, Peer: peer, Ttype: "put"}
+	if err := r.Add(resp); err != nil {
+		t.Fatal(err)
+	}
+	// Handles are stable, changes through one are seen through the other
+	l := r.Lookup(Initiator, 1, peer.String())
+	if l != tr {
+		t.Fatal("lookup did not return the registered transfer")
+	}
+	l.Filename = "changed"
+	if r.Lookup(Initiator, 1, peer.String()).Filename != "changed" {
+		t.Error("change through lookup lost")
+	}
+	if r.Match(peer.String(), 1) != tr || r.Lookup(Responder, 1, peer.String()) != resp {
+		t.Error("match or responder lookup")
+	}
+	// Only the transfer itself can be removed under its key
+	if err := r.Remove(&Transfer{Direction: Responder, Session: 1, Peer: peer}); err != ErrNoTransfer {
+		t.Errorf("removed a copy %v", err)
+	}
+	snap := r.Snapshot()
+	if len(snap) != 2 || snap[0] != tr || snap[1] != resp {
+		t.Fatalf("snapshot %v", snap)
+	}
+	if err := r.Remove(tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Remove(tr); err != ErrNoTransfer {
+		t.Errorf("second remove %v", err)
+	}
+	if len(snap) != 2 || r.Len() != 1 || r.Match(peer.String(), 1) != resp {
+		t.Error("snapshot changed or wrong transfer removed")
+	}
+	if added.Load() != 2 || removed.Load() != 1 {
+		t.Errorf("hooks added %d removed %d", added.Load(), removed.Load())
+	}
+	r.Remove(resp)
+
+	// Concurrent add, lookup, snapshot and remove, run with go test -race
+	const workers = 8
+	const sessions = 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			p := &net.UDPAddr{IP: net.IPv4(10, 0, 0, byte(w+1)), Port: 7542}
+			for s := uint32(0); s < sessions; s++ {
+				tr := &Transfer{Direction: s%2 == 0, Session: s, Peer: p}
+				if err := r.Add(tr); err != nil {
+					t.Error(err)
+					return
+				}
+				if r.Lookup(tr.Direction, s, p.String()) != tr {
+					t.Error("lookup of added transfer")
+				}
+				if s%20 == 0 {
+					for _, x := range r.Snapshot() {
+						_ = x.Key()
+					}
+				}
+				if s%3 == 0 {
+					if err := r.Remove(tr); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	kept := workers * (sessions - (sessions+2)/3)
+	if r.Len() != kept {
+		t.Errorf("%d transfers want %d", r.Len(), kept)
+	}
+	if int(added.Load()) != 2+workers*sessions || int(removed.Load()) != 2+workers*sessions-kept {
+		t.Errorf("hooks added %d removed %d", added.Load(), removed.Load())
+	}
+}
+
+func TestCancel(t *testing.T) {
+	conf := sarflags.New()
+	l := new(recorder)
+
+	// Files are in sardir
+	dir := t.TempDir()
+	conf.Sardir = dir
+	e, err := NewEngine(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The initiator connects its socket to the listener it sent the request to
+	// so it only hears frames sent from there
+	laddr, _ := net.ResolveUDPAddr("udp4", "127.0.0.1:0")
+	listener, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	laddr = listener.LocalAddr().(*net.UDPAddr)
+	initiator, err := net.DialUDP("udp4", nil, laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer initiator.Close()
+	iaddr := initiator.LocalAddr().(*net.UDPAddr)
+	frame := func(conn *net.UDPConn) []byte {
+		buf := make([]byte, 9000)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf[:n]
+	}
+	open := func(tr *Transfer) {
+		if tr.Fp, err = os.OpenFile(filepath.Join(dir, tr.Filename), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+			t.Fatal(err)
+		}
+		tr.Fp.WriteString("partial")
+	}
+	initiated := func(fname string) *Transfer {
+		tr := &Transfer{Direction: Initiator, Ttype: "put", Session: 7, Peer: laddr, Filename: fname, Conn: initiator, e: e}
+		tr.start()
+		open(tr)
+		fi, _ := tr.Fp.Stat()
+		tr.Dir = new(dirent.DirEnt)
+		if err := tr.Dir.Info(fname, fi); err != nil {
+			t.Fatal(err)
+		}
+		if tr.Cliflags, err = conf.CopyCliflags(); err != nil {
+			t.Fatal(err)
+		}
+		return tr
+	}
+
+	// Receiver of a put answers from the listener, removes the partial file and sends a status
+	h := sarflags.RequestHeader(0)
+	h.SetVersion(sarflags.VersionV1)
+	h.SetFrametype(sarflags.FrametypeRequest)
+	h.SetReqtype(sarflags.ReqtypePut)
+	rx, err := e.NewResponder(l, listener, &request.Request{Header: uint32(h), Session: 7}, "rx", iaddr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open(rx)
+	if err := rx.Cancel(l, true); err != nil {
+		t.Fatal(err)
+	}
+	var st status.Status
+	if err := st.Decode(frame(initiator)); err != nil {
+		t.Fatal(err)
+	}
+	if st.Session != 7 || sarflags.GetStr(st.Header, "errcode") != "rxnotinterested" {
+		t.Errorf("status %s", st.Print())
+	}
+	if _, err := os.Stat(filepath.Join(dir, rx.Filename)); !os.IsNotExist(err) {
+		t.Error("partial file not removed")
+	}
+	if rx.Context().Err() == nil || rx.Running() || rx.State != Cancelled || rx.Fp != nil {
+		t.Errorf("receiver not stopped, state %s", rx.State)
+	}
+	if err := rx.Cancel(l, true); err != ErrNotRunning {
+		t.Errorf("second cancel %v", err)
+	}
+	// Removing it leaves the listener open for everyone else
+	if err := rx.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := initiator.Write([]byte("still listening")); err != nil {
+		t.Fatal(err)
+	}
+	if string(frame(listener)) != "still listening" {
+		t.Error("listener closed with the transfer")
+	}
+
+	// Sender of a put keeps its file and sends metadata with progress terminated
+	tx := initiated("tx")
+	if err := tx.Cancel(l, true); err != nil {
+		t.Fatal(err)
+	}
+	var m metadata.MetaData
+	if err := m.Decode(frame(listener)); err != nil {
+		t.Fatal(err)
+	}
+	if m.Session != 7 || sarflags.GetStr(m.Header, "progress") != "terminated" {
+		t.Errorf("metadata %s", m.Print())
+	}
+	if _, err := os.Stat(filepath.Join(dir, tx.Filename)); err != nil {
+		t.Error("sender file removed")
+	}
+	if tx.Running() {
+		t.Error("sender still running")
+	}
+	// The peer cancelling is not sent back to it
+	pc := initiated("pc")
+	pc.PeerCancelled(l, "rxnotinterested")
+	if pc.Running() {
+		t.Error("peer cancel left transfer running")
+	}
+	listener.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _ := listener.Read(make([]byte, 100)); n != 0 {
+		t.Error("peer told of its own cancel")
+	}
+	if k := l.kinds(); strings.Join(k, ",") != "transfer.added,transfer.cancelled,transfer.cancelled,transfer.peerended" {
+		t.Errorf("events %v", k)
+	}
+}
+
+func TestResend(t *testing.T) {
+	conf := sarflags.New()
+	conf.Sardir = t.TempDir()
+	conf.Timeout.Status = 0 // Only resend what the status asks for
+	e, err := NewEngine(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sarflags.Mtu() == 0 {
+		sarflags.MtuSet(1500)
+	}
+	plen := int(paylen())
+	want := bytes.Repeat([]byte("0123456789"), plen*4/10+1) // Five frames
+	if err := os.WriteFile(filepath.Join(conf.Sardir, "f"), want, 0644); err != nil {
+		t.Fatal(err)
+	}
+	laddr, _ := net.ResolveUDPAddr("udp4", "127.0.0.1:0")
+	peer, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	tr, err := e.NewInitiator(sarlog.Discard, "put", peer.LocalAddr().(*net.UDPAddr), "", "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Remove()
+	// Data frames at the offsets the peer is sent until it has seen n of them
+	offsets := func(n int) []uint64 {
+		var offs []uint64
+		buf := make([]byte, 9000)
+		for len(offs) < n {
+			peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+			l, _, err := peer.ReadFromUDP(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var d data.Data
+			if sarflags.Header(binary.BigEndian.Uint32(buf[:4])).Frametype().String() == "data" && d.Decode(buf[:l]) == nil {
+				offs = append(offs, d.Offset)
+			}
+		}
+		return offs
+	}
+	reply := func(progress uint64, h holes.Holes) {
+		st, err := status.New("descriptor=d64,metadatarecvd=yes,allholes=yes,reqholes=requested,errcode=success",
+			status.Sinfo{Session: tr.Session, Progress: progress, Inrespto: uint64(len(want)), Holes: h})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.StatusRx(sarlog.Discard, *st)
+	}
+
+	reply(0, nil) // The responder agrees
+	if offs := offsets(5); offs[4] != uint64(4*plen) {
+		t.Errorf("first sent %v", offs)
+	}
+	// Only the second frame went missing, it is resent as it was sent whatever the mtu is now
+	mtu := sarflags.Mtu()
+	sarflags.MtuSet(9000)
+	defer sarflags.MtuSet(mtu)
+	before := metrics.Retransmitted.Value()
+	reply(uint64(plen), holes.Holes{{Start: plen, End: 2 * plen}})
+	if offs := offsets(1); offs[0] != uint64(plen) {
+		t.Errorf("resent %v", offs)
+	}
+	if got := metrics.Retransmitted.Value() - before; got != float64(plen) {
+		t.Errorf("retransmitted %v bytes want %d", got, plen)
+	}
+	reply(uint64(len(want)), nil)
+	select {
+	case <-tr.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("not done with it all received")
+	}
+	if st := tr.Stats(); st.State != Completed {
+		t.Errorf("state %s", st.State)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	defer shuttingdown.Store(false) // Later tests start transfers
+	l := sarlog.Discard
+	peer := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 7542}
+	running := func(session uint32) *Transfer {
+		tr := &Transfer{Direction: Responder, Ttype: "put", Session: session, Peer: peer, Filename: "f"}
+		tr.start()
+		if err := Transfers.Add(tr); err != nil {
+			t.Fatal(err)
+		}
+		return tr
+	}
+
+	// A transfer finishing inside the drain time is not cancelled
+	done := running(20)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		done.cancel(l, false, Cancelled)
+	}()
+	start := time.Now()
+	if code := Shutdown(l, 5*time.Second, ExitOK); code != ExitOK {
+		t.Errorf("drained shutdown exit %d", code)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("shutdown waited past the last transfer")
+	}
+	if Transfers.Len() != 0 {
+		t.Error("transfers left after shutdown")
+	}
+
+	// No new work once shutting down
+	if _, err := Global().NewInitiator(l, "get", peer, "", "f"); err != ErrShuttingDown {
+		t.Errorf("new transfer while shutting down %v", err)
+	}
+	if _, err := Global().NewResponder(l, nil, &request.Request{Session: 22}, "f", peer, ""); err != ErrShuttingDown {
+		t.Errorf("new responder while shutting down %v", err)
+	}
+
+	// Exit now stops waiting and what is still running is cancelled
+	stuck := running(21)
+	RequestExit(ExitOK, true)
+	if code := <-Exit; code != ExitOK {
+		t.Errorf("requested exit %d", code)
+	}
+	start = time.Now()
+	if code := Shutdown(l, time.Hour, ExitOK); code != ExitCancelled {
+		t.Errorf("cancelled shutdown exit %d", code)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("exit now waited for transfers")
+	}
+	if stuck.Running() || stuck.Context().Err() == nil {
+		t.Error("transfer still running after shutdown")
+	}
+	// Only the first request to exit reaches main
+	RequestExit(ExitError, false)
+	select {
+	case code := <-Exit:
+		t.Errorf("second exit request %d", code)
+	default:
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	var out, errout bytes.Buffer
+	SetHeadless(&out, &errout, false)
+	defer func() { hlog = nil }()
+	if !Headless() {
+		t.Fatal("not headless")
+	}
+	MsgPrintln(nil, "green_black", "hello ", 1)
+	ErrPrintf(nil, "red_black", "bad %s\n", "thing")
+	PacketPrintln(nil, "white_black", "Rx beacon")
+	if !strings.HasSuffix(out.String(), "msg: hello 1\n") {
+		t.Errorf("msg output %q", out.String())
+	}
+	if !strings.HasSuffix(errout.String(), "err: bad thing\n") {
+		t.Errorf("err output %q", errout.String())
+	}
+	if strings.Contains(out.String(), "packet") || strings.Contains(out.String(), "\033[") {
+		t.Errorf("packet or colour in output %q", out.String())
+	}
+	SetHeadless(&out, &errout, true)
+	PacketPrintln(nil, "white_black", "Rx beacon")
+	if !strings.HasSuffix(out.String(), "packet: Rx beacon\n") {
+		t.Errorf("packet output %q", out.String())
+	}
+}
+
+func TestCapture(t *testing.T) {
+	capmu.Lock()
+	before := len(captures)
+	capmu.Unlock()
+
+	// A closed capture is forgotten and loggers it handed out before drop what they write
+	g, c := NewCapture()
+	log := Logger(g)
+	log.Msg("kept")
+	c.Close()
+	if captured(g) != nil {
+		t.Error("closed capture still known")
+	}
+	log.Msg("late")
+	log.Err("late")
+	if lines := c.Lines("msg"); len(lines) != 0 {
+		t.Errorf("closed capture output %q", lines)
+	}
+
+	// A tee passes what is logged after it is closed on to the capture it tees to
+	pg, parent := NewCapture()
+	defer parent.Close()
+	tg, tee := Tee(pg)
+	log = Logger(tg)
+	log.Msg("before")
+	tee.Close()
+	log.Msg("after")
+	if lines := parent.Lines("msg"); len(lines) != 2 || lines[0] != "before" || lines[1] != "after" {
+		t.Errorf("parent output %q", lines)
+	}
+	if lines := tee.Lines("msg"); len(lines) != 0 {
+		t.Errorf("closed tee output %q", lines)
+	}
+
+	capmu.Lock()
+	after := len(captures)
+	capmu.Unlock()
+	if after != before+1 {
+		t.Errorf("%d captures left want %d", after, before+1)
+	}
+
+	// Commands run on the engine their capture was given
+	conf := sarflags.New()
+	conf.Acldefault = "deny"
+	conf.Acl = []sarflags.Aclrule{{Peer: "10.1.0.0/16", Ops: []string{"get"}}}
+	e, err := NewEngine(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eg, ec := NewCapture()
+	defer ec.Close()
+	ec.SetEngine(e)
+	if err := Exec(eg, "acl"); err != nil {
+		t.Fatal(err)
+	}
+	if out := strings.Join(ec.Lines("msg"), "\n"); !strings.Contains(out, "deny") || !strings.Contains(out, "peer:10.1.0.0/16") {
+		t.Errorf("acl output %q", out)
+	}
+}
+
+func TestSource(t *testing.T) {
+	conf := sarflags.New()
+	dir := t.TempDir()
+	conf.Sardir = dir
+	sarflags.Cliflag = conf
+	write := func(name string, lines ...string) string {
+		fname := filepath.Join(dir, name)
+		if err := os.WriteFile(fname, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fname
+	}
+
+	// Commands run in order, each finished before the next, output shows with g
+	g, c := NewCapture()
+	defer c.Close()
+	write("inner.sh", "alias inner $PEER")
+	fname := write("setup.sh",
+		"# Setup",
+		"set PEER 10.1.1.1",
+		"alias far $PEER # trailing comment",
+		"sleep 10ms",
+		"source "+filepath.Join(dir, "inner.sh"),
+		"wait 1",
+		"alias inner",
+		"nosuchcommand",
+		"alias never 10.2.2.2")
+	err := Source(g, fname)
+	var se *ScriptError
+	if !errors.As(err, &se) || se.Line != 8 || !errors.Is(err, ErrInvalidCommand) {
+		t.Fatalf("script stopped with %v want invalid command at line 8", err)
+	}
+	if conf.Aliases["far"] != "10.1.1.1" || conf.Aliases["inner"] != "10.1.1.1" {
+		t.Errorf("aliases %v", conf.Aliases)
+	}
+	if _, ok := conf.Aliases["never"]; ok {
+		t.Error("script ran on after the error")
+	}
+	if lines := c.Lines("msg"); len(lines) == 0 || lines[len(lines)-1] != "inner 10.1.1.1" {
+		t.Errorf("output %q", lines)
+	}
+
+	// A command writing to the err view stops the script
+	err = Source(g, write("fail.sh", "alias nosuchalias", "alias never 10.2.2.2"))
+	if !errors.As(err, &se) || se.Line != 1 || !errors.Is(err, ErrCommandFailed) {
+		t.Errorf("script stopped with %v want command failed at line 1", err)
+	}
+
+	// As does sourcing itself forever
+	loop := filepath.Join(dir, "loop.sh")
+	write("loop.sh", "source "+loop)
+	if err := Source(g, loop); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("script sourcing itself stopped with %v", err)
+	}
+
+	// wait is over when the transfers have completed, a cancelled one fails it
+	running := func(session uint32) *Transfer {
+		tr := &Transfer{Direction: Initiator, Ttype: "get", Session: session, Filename: "f",
+			Peer: &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 7542}, Cliflags: conf}
+		tr.start()
+		return tr
+	}
+	s := &script{started: []*Transfer{running(30), running(31)}}
+	for _, tr := range s.started {
+		go func(tr *Transfer) {
+			time.Sleep(50 * time.Millisecond)
+			tr.complete(sarlog.Discard)
+		}(tr)
+	}
+	if err := s.wait(2 * time.Second); err != nil || len(s.started) != 0 {
+		t.Errorf("wait for completed transfers %v", err)
+	}
+	s.started = []*Transfer{running(32)}
+	if err := s.wait(50 * time.Millisecond); err == nil || !strings.Contains(err.Error(), "still running") {
+		t.Errorf("wait for running transfer %v", err)
+	}
+	cancelled := running(33)
+	cancelled.PeerCancelled(sarlog.Discard, "filenotfound")
+	s.started = []*Transfer{cancelled}
+	if err := s.wait(0); err == nil || !strings.Contains(err.Error(), "filenotfound") {
+		t.Errorf("wait for cancelled transfer %v", err)
+	}
+
+	// wait is for what the script's own commands started, not whatever else is running
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	e, err := NewEngine(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eg, ec := NewCapture()
+	defer ec.Close()
+	ec.SetEngine(e)
+	other := running(34)
+	if other.Conn, err = net.DialUDP("udp4", nil, peer.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Transfers.Add(other); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Remove()
+	s = &script{g: eg, vars: make(map[string]string)}
+	if err := s.run("get " + peer.LocalAddr().String() + " remote.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.started) != 1 || s.started[0] == other || s.started[0].Filename != "remote.txt" {
+		t.Fatalf("script started %v", s.started)
+	}
+	s.started[0].Remove()
+
+	s = &script{vars: map[string]string{"EID": "mine"}}
+	if v := s.lookup("SARDIR"); v != dir {
+		t.Errorf("$SARDIR is %s want %s", v, dir)
+	}
+	if v := s.lookup("EID"); v != "mine" {
+		t.Errorf("$EID is %s want the one set", v)
+	}
+	if u := uncomment("put peer file#1 # a comment"); u != "put peer file#1 " {
+		t.Errorf("uncomment gave %q", u)
+	}
+}
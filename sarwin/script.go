@@ -0,0 +1,237 @@
+// Script - Run a file of cli commands one after another
+// Each line is a command as typed into the cmd view, # starts a comment
+// $SARDIR, $EID, variables given with set and the environment are expanded
+// Scripts also have
+//	set <name> <value> - set a variable
+//	sleep <secs> - pause, secs can be a duration such as 500ms
+//	wait [<secs>] - wait for the transfers the script started to finish
+//	source <file> - run another script
+// The script stops at the first command that fails
+
+package sarwin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/jroimartin/gocui"
+)
+
+// ErrCommandFailed - The command wrote to the err view
+var ErrCommandFailed = errors.New("command failed")
+
+// ErrTooDeep - Scripts sourcing scripts went too deep, most likely one sources itself
+var ErrTooDeep = errors.New("scripts nested too deep")
+
+// ScriptError - Where a script stopped and why
+type ScriptError struct {
+	File string
+	Line int
+	Cmd  string
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %v", e.File, e.Line, e.Cmd, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// How many scripts can source each other
+const maxdepth = 8
+
+// A script running, sourced scripts share its variables and transfers
+type script struct {
+	g       *gocui.Gui
+	vars    map[string]string
+	started []*Transfer // Waited for by wait
+	depth   int
+}
+
+// Source - Run the commands in the file in order, returning when the last has finished
+// Output goes to the views of g, the error is a ScriptError saying where the script stopped
+func Source(g *gocui.Gui, fname string) error {
+	s := &script{g: g, vars: make(map[string]string)}
+	return s.source(fname)
+}
+
+func (s *script) source(fname string) error {
+	if s.depth >= maxdepth {
+		return ErrTooDeep
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s.depth++
+	defer func() { s.depth-- }()
+
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(os.Expand(uncomment(scanner.Text()), s.lookup))
+		if line == "" {
+			continue
+		}
+		if err := s.run(line); err != nil {
+			var se *ScriptError
+			if errors.As(err, &se) { // Already says where in the script it sourced
+				return err
+			}
+			return &ScriptError{File: fname, Line: lineno, Cmd: line, Err: err}
+		}
+	}
+	return scanner.Err()
+}
+
+// The line up to a # at its start or after a space
+func uncomment(line string) string {
+	for i, c := range line {
+		if c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// The value of $name, those set in the script first then ours then the environment
+func (s *script) lookup(name string) string {
+	if v, ok := s.vars[name]; ok {
+		return v
+	}
+	sarflags.Climu.Lock()
+	defer sarflags.Climu.Unlock()
+	if c := sarflags.Cliflag; c != nil {
+		switch name {
+		case "SARDIR":
+			return c.Sardir
+		case "EID":
+			return c.Eid
+		}
+	}
+	return os.Getenv(name)
+}
+
+// Secs or a duration such as 1m30s
+func duration(arg string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(arg, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid time %s", arg)
+	}
+	return d, nil
+}
+
+// Run a line of the script, the command has finished when we return
+func (s *script) run(line string) error {
+	vals := strings.Fields(line)
+	switch vals[0] {
+	case "set":
+		if len(vals) != 3 {
+			return errors.New("usage: set <name> <value>")
+		}
+		s.vars[vals[1]] = vals[2]
+		return nil
+	case "sleep":
+		if len(vals) != 2 {
+			return errors.New("usage: sleep <secs>")
+		}
+		d, err := duration(vals[1])
+		if err != nil {
+			return err
+		}
+		time.Sleep(d)
+		return nil
+	case "wait":
+		var d time.Duration
+		switch len(vals) {
+		case 1:
+		case 2:
+			var err error
+			if d, err = duration(vals[1]); err != nil {
+				return err
+			}
+		default:
+			return errors.New("usage: wait [<secs>]")
+		}
+		return s.wait(d)
+	case "source":
+		if len(vals) != 2 {
+			return errors.New("usage: source <file>")
+		}
+		return s.source(vals[1])
+	}
+
+	// Anything else is a cli command, its output is shown as well as kept to see if it failed
+	tg, c := Tee(s.g)
+	defer c.Close()
+	err := Exec(tg, line)
+	s.started = append(s.started, c.Started()...)
+	if err != nil {
+		return err
+	}
+	if errs := c.Lines("err"); len(errs) > 0 {
+		return fmt.Errorf("%w: %s", ErrCommandFailed, errs[0])
+	}
+	return nil
+}
+
+// Wait for the transfers the script started to complete or be cancelled, no longer than d if it is not 0
+// Cancelled ones fail the wait with why they were cancelled
+func (s *script) wait(d time.Duration) error {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	started := s.started
+	s.started = nil
+	for i, t := range started {
+		select {
+		case <-t.Done():
+		case <-timeout:
+			return fmt.Errorf("%d transfers still running after %s", len(started)-i, d)
+		}
+	}
+	for _, t := range started {
+		if st := t.Stats(); st.State != Completed {
+			return fmt.Errorf("transfer %s %s: %s", t.Ttype, t.Filename, st.Errcode)
+		}
+	}
+	return nil
+}
+
+// source runs commands through cmdhandler so it is added here rather than in it
+func init() {
+	cmdhandler["source"] = cmdSource
+}
+
+// Run a script from the cmd view
+func cmdSource(g *gocui.Gui, args []string) {
+	switch len(args) {
+	case 2:
+		if args[1] == "?" {
+			MsgPrintln(g, "magenta_black", prhelp("source"))
+			MsgPrintln(g, "green_black", prusage("source"))
+			return
+		}
+		if err := Source(g, args[1]); err != nil {
+			ErrPrintln(g, "red_black", "Script stopped: ", err)
+			return
+		}
+		MsgPrintln(g, "green_black", "Script ", args[1], " done")
+		return
+	}
+	ErrPrintln(g, "red_black", prusage("source"))
+}
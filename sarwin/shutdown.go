@@ -0,0 +1,106 @@
+// Shutdown - Stop saratoga without leaving peers and files hanging
+
+package sarwin
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/charlesetsmith/saratoga/sarlog"
+)
+
+// Exit codes
+const (
+	ExitOK        = 0 // Clean shutdown
+	ExitError     = 1 // Asked to exit with an error or something failed
+	ExitCancelled = 2 // Transfers still running at the deadline had to be cancelled
+)
+
+// Exit - Exit codes asked for, main shuts down when it receives one
+var Exit = make(chan int, 1)
+
+// ErrShuttingDown - No new transfers are started once shutdown has begun
+var ErrShuttingDown = errors.New("saratoga is shutting down")
+
+var shuttingdown atomic.Bool
+var exiting atomic.Bool            // Exit has been sent, main only shuts down once
+var hurry = make(chan struct{}, 1) // Stop waiting for transfers to drain
+
+// ShuttingDown - Are we refusing new work
+func ShuttingDown() bool {
+	return shuttingdown.Load()
+}
+
+// RequestExit - Ask main to shut down and exit with code
+// now does not wait for transfers in progress to finish, it cancels them
+func RequestExit(code int, now bool) {
+	if now {
+		select {
+		case hurry <- struct{}{}:
+		default:
+		}
+	}
+	if exiting.CompareAndSwap(false, true) {
+		Exit <- code
+	}
+}
+
+// Running transfers
+func running() int {
+	n := 0
+	for _, t := range Transfers.Snapshot() {
+		if t.Running() {
+			n++
+		}
+	}
+	return n
+}
+
+// Shutdown - Refuse new work, stop sending beacons, wait up to drain for transfers to finish
+// then cancel what is left telling the peers, and close every transfers file and connection
+// Returns the exit code to use, ExitCancelled if code was ExitOK and transfers were cancelled
+func Shutdown(l sarlog.Logger, drain time.Duration, code int) int {
+	shuttingdown.Store(true)
+	if Beacons != nil {
+		Beacons.StopAll()
+	}
+
+	if n := running(); n > 0 && drain > 0 {
+		l.Msg("Waiting for transfers to finish", sarlog.F("transfers", n), sarlog.F("wait", drain))
+		deadline := time.NewTimer(drain)
+		tick := time.NewTicker(100 * time.Millisecond)
+	wait:
+		for running() > 0 {
+			select {
+			case <-deadline.C:
+				break wait
+			case <-hurry:
+				break wait
+			case <-tick.C:
+			}
+		}
+		deadline.Stop()
+		tick.Stop()
+	}
+
+	cancelled := 0
+	for _, t := range Transfers.Snapshot() {
+		if err := t.Cancel(l, false); err != ErrNotRunning {
+			cancelled++
+			if err != nil {
+				l.Err("Cancelled "+t.Print()+" but could not tell peer", sarlog.F("err", err))
+			}
+		}
+		if err := t.Remove(); err != nil {
+			l.Err(err.Error())
+		}
+	}
+	if cancelled > 0 {
+		l.Msg("Transfers cancelled", sarlog.F("transfers", cancelled))
+		if code == ExitOK {
+			code = ExitCancelled
+		}
+	}
+	return code
+}
@@ -7,10 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"reflect"
 	"strings"
 
+	"github.com/charlesetsmith/saratoga/frames"
 	"github.com/charlesetsmith/saratoga/holes"
+	"github.com/charlesetsmith/saratoga/metrics"
 	"github.com/charlesetsmith/saratoga/sarflags"
 	"github.com/charlesetsmith/saratoga/timestamp"
 )
@@ -32,6 +33,8 @@ type Sinfo struct {
 	Holes    holes.Holes
 }
 
+var _ frames.Frame[Sinfo] = (*Status)(nil)
+
 type Packet struct {
 	Addr net.UDPAddr
 	Info Status
@@ -55,8 +58,7 @@ func (s *Status) Values() Status {
 // New - Construct a Status frame - return byte slice of frame
 // Flags is of format "flagname1=flagval1,flagname2=flagval2...
 // The timestamp type to use is also in the flags as "timestamp=flagval"
-// func (s *Status) New(flags string, session uint32, progress uint64, inrespto uint64, holes holes.Holes) error {
-func (s *Status) New(flags string, info interface{}) error {
+func (s *Status) New(flags string, info *Sinfo) error {
 
 	var err error
 
@@ -75,7 +77,7 @@ func (s *Status) New(flags string, info interface{}) error {
 	for fl := range flag {
 		f := strings.Split(flag[fl], "=") // f[0]=name f[1]=val
 		switch f[0] {
-		case "descriptor", "stream", "metadatarecvd", "allholes", "reqholes", "errcode":
+		case "descriptor", "stream", "metadatarecvd", "allholes", "reqholes", "errcode", "encrypt":
 			if s.Header, err = sarflags.Set(s.Header, f[0], f[1]); err != nil {
 				return err
 			}
@@ -102,46 +104,46 @@ func (s *Status) New(flags string, info interface{}) error {
 			return errors.New("Invalid Flag " + f[0] + " for Status Frame")
 		}
 	}
-	e := reflect.ValueOf(info).Elem()
-	s.Session = uint32(e.FieldByName("Session").Uint())
-	s.Progress = e.FieldByName("Progress").Uint()
-	s.Inrespto = e.FieldByName("Inrespto").Uint()
-	for i := 0; i < e.FieldByName("Holes").Len(); i++ {
-		var h holes.Hole
-		// Get the Start and End from within the Holes Structure
-		h.Start = int(e.FieldByName("Holes").Index(i).FieldByName("Start").Int())
-		h.End = int(e.FieldByName("Holes").Index(i).FieldByName("End").Int())
-		s.Holes = append(s.Holes, h)
-	}
+	s.info(info)
 	return nil
 }
 
 // Make - Construct a status frame with a given header
-// func (s *Status) Make(header uint32, session uint32, progress uint64, inrespto uint64, holes holes.Holes) error {
-func (s *Status) Make(header uint32, info interface{}) error {
+func (s *Status) Make(header uint32, info *Sinfo) error {
 
-	var err error
+	h := sarflags.StatusHeader(header)
+	h.SetVersion(sarflags.VersionV1)
+	h.SetFrametype(sarflags.FrametypeStatus)
 
-	if header, err = sarflags.Set(header, "version", "v1"); err != nil {
-		return err
-	}
-	if header, err = sarflags.Set(header, "frametype", "status"); err != nil {
-		return err
+	s.Header = uint32(h)
+	s.info(info)
+	return nil
+}
+
+// Assign the values from the Sinfo structure, the holes are copied
+func (s *Status) info(info *Sinfo) {
+	s.Session = info.Session
+	s.Progress = info.Progress
+	s.Inrespto = info.Inrespto
+	s.Holes = append(s.Holes, info.Holes...)
+}
+
+// New - A status frame from flags and info, see Status.New
+func New(flags string, info Sinfo) (*Status, error) {
+	s := new(Status)
+	if err := s.New(flags, &info); err != nil {
+		return nil, err
 	}
+	return s, nil
+}
 
-	s.Header = header
-	e := reflect.ValueOf(info).Elem()
-	s.Session = uint32(e.FieldByName("Session").Uint())
-	s.Progress = e.FieldByName("Progress").Uint()
-	s.Inrespto = e.FieldByName("Inrespto").Uint()
-	for i := 0; i < e.FieldByName("Holes").Len(); i++ {
-		var h holes.Hole
-		// Get the Start and End from within the Holes Structure
-		h.Start = int(e.FieldByName("Holes").Index(i).FieldByName("Start").Int())
-		h.End = int(e.FieldByName("Holes").Index(i).FieldByName("End").Int())
-		s.Holes = append(s.Holes, h)
+// Make - A status frame with a given header and info, see Status.Make
+func Make(header uint32, info Sinfo) (*Status, error) {
+	s := new(Status)
+	if err := s.Make(header, &info); err != nil {
+		return nil, err
 	}
-	return nil
+	return s, nil
 }
 
 // Put - Encode the Saratoga Status frame
@@ -152,20 +154,21 @@ func (s Status) Encode() ([]byte, error) {
 	// Create the frame slice
 	framelen := 4 + 4 // Header + Session
 
-	if sarflags.GetStr(s.Header, "reqtstamp") == "yes" {
+	h := sarflags.StatusHeader(s.Header)
+	if h.Reqtstamp() {
 		framelen += 16 // Timestamp
 	}
 
 	var dsize int
 
-	switch sarflags.GetStr(s.Header, "descriptor") { // Offset
-	case "d16":
+	switch h.Descriptor() { // Offset
+	case sarflags.DescriptorD16:
 		dsize = 2
-	case "d32":
+	case sarflags.DescriptorD32:
 		dsize = 4
-	case "d64":
+	case sarflags.DescriptorD64:
 		dsize = 8
-	case "d128":
+	case sarflags.DescriptorD128:
 		return nil, errors.New("d128 not supported in status")
 		// dsize = 16
 	default:
@@ -242,8 +245,9 @@ func (s *Status) Decode(frame []byte) error {
 	}
 	s.Header = binary.BigEndian.Uint32(frame[:4])
 	s.Session = binary.BigEndian.Uint32(frame[4:8])
+	h := sarflags.StatusHeader(s.Header)
 	pos := 8
-	if sarflags.GetStr(s.Header, "reqtstamp") == "yes" {
+	if h.Reqtstamp() {
 		var err error
 
 		if err = s.Tstamp.Get(frame[pos:24]); err != nil {
@@ -254,8 +258,8 @@ func (s *Status) Decode(frame []byte) error {
 
 	var dsize int
 
-	switch sarflags.GetStr(s.Header, "descriptor") {
-	case "d16":
+	switch h.Descriptor() {
+	case sarflags.DescriptorD16:
 		dsize = 2
 		s.Progress = uint64(binary.BigEndian.Uint16(frame[pos : pos+dsize]))
 		pos += dsize
@@ -270,7 +274,7 @@ func (s *Status) Decode(frame []byte) error {
 			pos += dsize
 			s.Holes = append(s.Holes, holes.Hole{Start: start, End: end})
 		}
-	case "d32":
+	case sarflags.DescriptorD32:
 		dsize = 4
 		s.Progress = uint64(binary.BigEndian.Uint32(frame[pos : pos+dsize]))
 		pos += dsize
@@ -284,7 +288,7 @@ func (s *Status) Decode(frame []byte) error {
 			pos += dsize
 			s.Holes = append(s.Holes, holes.Hole{Start: start, End: end})
 		}
-	case "d64":
+	case sarflags.DescriptorD64:
 		dsize = 8
 		s.Progress = uint64(binary.BigEndian.Uint64(frame[pos : pos+dsize]))
 		pos += dsize
@@ -298,7 +302,7 @@ func (s *Status) Decode(frame []byte) error {
 			pos += dsize
 			s.Holes = append(s.Holes, holes.Hole{Start: start, End: end})
 		}
-	case "d128":
+	case sarflags.DescriptorD128:
 		return errors.New("d128 not supported in status")
 		/* when we get there!
 		dsize = 16
@@ -364,9 +368,17 @@ func (s Status) Send(conn *net.UDPConn, to *net.UDPAddr) error {
 	if buf, err = s.Encode(); err != nil {
 		return err
 	}
-	if wlen, err = conn.WriteTo(buf, to); err != nil {
+	// A connection dialed to the peer can only Write
+	if conn.RemoteAddr() != nil {
+		wlen, err = conn.Write(buf)
+	} else {
+		wlen, err = conn.WriteTo(buf, to)
+	}
+	if err != nil {
 		return err
 	}
+	metrics.Sent("status", conn, to, wlen)
+	metrics.Errcodes.Inc("sent", sarflags.GetStr(s.Header, "errcode"))
 	if wlen != len(buf) {
 		return fmt.Errorf("status sent (%d) to %s != frame size (%d)", wlen, to.String(), len(buf))
 	}
@@ -4,21 +4,15 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/charlesetsmith/saratoga/holes"
 	"github.com/charlesetsmith/saratoga/sarflags"
 )
 
 func TestStatus(t *testing.T) {
 	// var err error
 	// var Cmdptr *sarflags.Cliflags
-	// The Command line interface commands, help & usage to be read from saratoga.json
-	cmdptr := new(sarflags.Cliflags)
-
-	// Read in JSON config file and parse it into the Config structure.
-	if err := cmdptr.ReadConfig("../saratoga/saratoga.json"); err != nil {
-		emsg := "Cannot open or parse saratoga.json Readconf error: " + err.Error()
-		t.Fatal(emsg)
-		return
-	}
+	// The Command line interface commands, help & usage are built in to sarflags
+	cmdptr := sarflags.New()
 
 	fmt.Println("Global Settings: ", cmdptr.Global)
 	var sta Sinfo
@@ -37,3 +31,23 @@ func TestStatus(t *testing.T) {
 	}
 	t.Log(sptr.Print())
 }
+
+// The holes are copied from the info, changing them after does not change the frame
+func TestStatusHoles(t *testing.T) {
+	sinfo := Sinfo{Session: 1234, Progress: 10, Inrespto: 20, Holes: holes.Holes{{Start: 10, End: 20}, {Start: 30, End: 40}}}
+	s, err := New("descriptor=d32,reqholes=requested,errcode=success", sinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinfo.Holes[0].Start = 15
+	if len(s.Holes) != 2 || s.Holes[0] != (holes.Hole{Start: 10, End: 20}) || s.Holes[1] != (holes.Hole{Start: 30, End: 40}) {
+		t.Errorf("New holes %v want [{10 20} {30 40}]", s.Holes)
+	}
+	m, err := Make(s.Header, sinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Header != s.Header || m.Session != 1234 || m.Progress != 10 || m.Inrespto != 20 || len(m.Holes) != 2 || m.Holes[0].Start != 15 {
+		t.Errorf("Make %+v from header %#x", m.Values(), s.Header)
+	}
+}
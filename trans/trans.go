@@ -1,112 +1,150 @@
 package trans
 
 import (
+	"errors"
 	"net"
 	"os"
-	"sync"
 
-	"github.com/charlesetsmith/saratoga/dirent"
-	"github.com/charlesetsmith/saratoga/fileio"
-	"github.com/charlesetsmith/saratoga/holes"
+	"github.com/charlesetsmith/saratoga/acl"
+	"github.com/charlesetsmith/saratoga/auth"
+	"github.com/charlesetsmith/saratoga/capability"
+	"github.com/charlesetsmith/saratoga/data"
+	"github.com/charlesetsmith/saratoga/metadata"
 	"github.com/charlesetsmith/saratoga/request"
+	"github.com/charlesetsmith/saratoga/sarcrypt"
 	"github.com/charlesetsmith/saratoga/sarflags"
+	"github.com/charlesetsmith/saratoga/sarlog"
 	"github.com/charlesetsmith/saratoga/sarwin"
 	"github.com/charlesetsmith/saratoga/status"
-	"github.com/charlesetsmith/saratoga/timestamp"
-	"github.com/jroimartin/gocui"
 )
 
-type Transfer struct {
-	Peer      net.UDPAddr         // Remote Host
-	Session   uint32              // Session + peer is the unique key
-	Ttype     string              // Transfer type: get,take,put,give,delete,getdir
-	Rxwilling string              // Are we willing to receive files (ignored): no,invalid,capable,yes
-	Txwilling string              // Are we willing to send files (ignored): no,invalid,capable,yes
-	Filename  string              // Remote File name to get/put (if null send error status)
-	Timetype  string              // Timestamp type: posix32, posix64, posix32_32, posix64_32, epock2000_32, ""
-	Tstamp    timestamp.Timestamp // Timestamp used in transfer
-	Stflags   string              // Status Flags currently set WORK ON THIS!!!!!
-	Csumtype  string              // What type of checksum are we using
-	Checksum  []byte              // Checksum of the remote file to be get/put if requested
-	Havemeta  bool                // Have we recieved a metadata yet
-	Dir       dirent.DirEnt       // Directory entry info of the file to get/put
-	Fp        *os.File            // Local File to write to/read from
-	Data      []byte              // Buffered data
-	Dcount    int                 // Count of Data frames so we can schedule status
-	Progress  uint64              // Current Progress indicator
-	Inrespto  uint64              // In respose to indicator
-	CurFills  holes.Holes         // What has been received
-}
-
-// Trmu - Protect transfer
-var Trmu sync.Mutex
-
-// Transfers - Slice of transfers currently in progress
-var Transfers = []Transfer{}
-
-// We have received a request frame from a remote host
-// Create the transfer associated with the received request
+// We have received a request frame from a remote host on conn
+// Create the transfer associated with the received request, it answers from conn
 // Send status frame back via the tx channel upon failure or success
-func AddRxTran(g *gocui.Gui, r *request.Request, from *net.UDPAddr, tx chan interface{}) bool {
-	ttype := sarflags.GetStr(r.Header, "request")
+func AddRxTran(l sarlog.Logger, e *sarwin.Engine, conn *net.UDPConn, r *request.Request, from *net.UDPAddr, tx chan interface{}) bool {
+	header := sarflags.RequestHeader(r.Header)
+	ttype := header.Reqtype().String()
 
 	// If a bad version received then send back a Status errcode to the initiator
 	var st status.Status
-	sinfo := status.Sinfo{Session: 0, Progress: 0, Inrespto: 0, Holes: nil}
+	sinfo := status.Sinfo{Session: r.Session, Progress: 0, Inrespto: 0, Holes: nil}
 
-	if sarflags.GetStr(r.Header, "version") != "v1" {
+	// Values we have no name for are as bad as a bad version
+	if header.Version() != sarflags.VersionV1 || sarflags.Unnamed(ttype) ||
+		sarflags.Unnamed(header.Rxwilling().String()) || sarflags.Unnamed(header.Txwilling().String()) {
+		l.Err("Bad request", sarlog.F("peer", from.IP), sarlog.F("version", header.Version()), sarlog.F("ttype", ttype))
 		if st.New("errcode=badrequest", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create badrequest status")
+			l.Err("Cannot create badrequest status")
 			return false
 		}
 		tx <- st.Val(from)
 		return false
 	}
 
-	if sarflags.GetStr(r.Header, "udplite") != "no" {
-		sarwin.ErrPrintln(g, "red_black", "UDP Lite not supported")
+	if header.Udplite() {
+		l.Err("UDP Lite not supported")
 		if st.New("errcode=badrequest", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create badrequest status")
+			l.Err("Cannot create badrequest status")
 			return false
 		}
 		tx <- st.Val(from)
 		return false
 	}
 
-	if sarflags.GetStr(r.Header, "stream") != "no" {
-		sarwin.ErrPrintln(g, "red_black", "Streams not supported")
-		if st.New("errcode=badrequest", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create badrequest status")
+	// No new transfers once we have started shutting down
+	if sarwin.ShuttingDown() {
+		l.Err("Refusing request, shutting down", sarlog.F("peer", from.IP), sarlog.F("ttype", ttype), sarlog.F("file", r.Fname))
+		if st.New("errcode=unspecified", &sinfo) != nil {
+			l.Err("Cannot create unspecified status")
+			return false
+		}
+		tx <- st.Val(from)
+		return false
+	}
+
+	// Is the peer who it says it is, eid is only set when it proved it with that EIDs key
+	eid, err := e.Keys.Verify(from.IP, r.Header, r.Session, r.Fname, r.Auth)
+	if err != nil {
+		l.Err("Authentication failed", sarlog.F("peer", from.IP), sarlog.F("ttype", ttype), sarlog.F("file", r.Fname), sarlog.F("err", err))
+		// Create STATUS and set errcode to "accessdenied"
+		if st.New("errcode=accessdenied", &sinfo) != nil {
+			l.Err("Cannot create accessdenied status")
+			return false
+		}
+		tx <- st.Val(from)
+		return false
+	}
+	// It has proved it is eid so this is where to reach it
+	e.Peers.Confirm(eid, from.IP)
+
+	// Is the peer allowed to make this request
+	// The file is the one the acl checked, wherever the request tried to walk to
+	fname := acl.Clean(r.Fname)
+	if !e.Access.Allowed(from.IP, eid, ttype, fname) {
+		l.Err("Access denied", sarlog.F("peer", from.IP), sarlog.F("ttype", ttype), sarlog.F("file", fname))
+		// Create STATUS and set errcode to "accessdenied"
+		if st.New("errcode=accessdenied", &sinfo) != nil {
+			l.Err("Cannot create accessdenied status")
+			return false
+		}
+		tx <- st.Val(from)
+		return false
+	}
+
+	sarflags.Climu.Lock()
+	local := capability.Local(e.Flags.Global)
+	wantencrypt := e.Flags.Global["encrypt"] == "yes"
+	sarflags.Climu.Unlock()
+
+	// Are we willing to do what is asked of us
+	if errcode := local.Responder(ttype, header.Stream()); errcode != "success" {
+		l.Err("Refusing request", sarlog.F("peer", from.IP), sarlog.F("ttype", ttype), sarlog.F("file", fname),
+			sarlog.F("rxwilling", local.Rxwilling), sarlog.F("txwilling", local.Txwilling), sarlog.F("stream", local.Stream))
+		if st.New("errcode="+errcode, &sinfo) != nil {
+			l.Err("Cannot create " + errcode + " status")
+			return false
+		}
+		tx <- st.Val(from)
+		return false
+	}
+
+	// We need the peers key and the requests salt to encrypt and when we encrypt we refuse anything in the clear
+	encrypt := sarcrypt.Encrypted(r.Header)
+	if (encrypt && (e.Keys.Key(from.IP, eid) == nil || auth.Salt(r.Auth) == nil)) || (!encrypt && wantencrypt) {
+		l.Err("Encryption mismatch", sarlog.F("peer", from.IP), sarlog.F("ttype", ttype), sarlog.F("file", fname))
+		// Create STATUS and set errcode to "accessdenied"
+		if st.New("errcode=accessdenied", &sinfo) != nil {
+			l.Err("Cannot create accessdenied status")
 			return false
 		}
 		tx <- st.Val(from)
 		return false
 	}
+	// Let the initiator know we agreed to encrypt
+	stflags := "errcode=success"
+	if encrypt {
+		stflags += ",encrypt=yes"
+	}
 
-	// ONLY create a transfer if both rxwilling and txwilling are set to "yes"
-	// Thehese are silly flags as if I am the sender I a already know if I ca can receive files
-	// and if I am the receiver it is up to me to just ignore requests that have these flags set to
-	// capable, no or invalid
-	// RECOMMEND THESE FLAGS BE REMOVED FROM THE DRAFT
-	// If I receive a request and cannot do it then send back a STATUS with the corresponding error
-	// code should be the action, which is what I am going to do in this implementation
+	// The initiators rxwilling and txwilling say whether it can do its half of the transfer
+	// If it cannot then send back a STATUS with the corresponding error code
 	var rxwilling string
-	if rxwilling = sarflags.GetStr(r.Header, "rxwilling"); rxwilling == "invalid" {
+	if rxwilling = header.Rxwilling().String(); rxwilling == "invalid" {
 		// Create STATUS and set errcode to "cantreceive"
-		sarwin.ErrPrintln(g, "red_black", "Invalid Rxwilling")
+		l.Err("Invalid Rxwilling")
 		if st.New("errcode=cantreceive", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create cantreceive status")
+			l.Err("Cannot create cantreceive status")
 			return false
 		}
 		tx <- st.Val(from)
 		return false
 	}
 	var txwilling string
-	if txwilling = sarflags.GetStr(r.Header, "txwilling"); txwilling == "invalid" {
-		sarwin.ErrPrintln(g, "red_black", "Invalid Txwilling")
+	if txwilling = header.Txwilling().String(); txwilling == "invalid" {
+		l.Err("Invalid Txwilling")
 		// Create STATUS and set errcode to "cantsend"
 		if st.New("errcode=cantsend", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create cantsend status")
+			l.Err("Cannot create cantsend status")
 			return false
 		}
 		tx <- st.Val(from)
@@ -114,57 +152,58 @@ func AddRxTran(g *gocui.Gui, r *request.Request, from *net.UDPAddr, tx chan inte
 	}
 
 	// See if the file exists on our local system
-	exists := fileio.FileExists(r.Fname)
+	path := e.Path(fname)
+	_, err = os.Stat(path)
+	exists := err == nil
 	switch ttype {
 	// Open the local file to read from
 	case "get", "getdir", "take":
 		if rxwilling != "yes" {
-			sarwin.ErrPrintln(g, "red_black", "Cannot get as rxwilling set to ", rxwilling)
+			l.Err("Cannot get", sarlog.F("rxwilling", rxwilling))
 			// Create STATUS and set errcode to "cantreceive"
 			if st.New("errcode=cantreceive", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create rxwilling status")
+				l.Err("Cannot create rxwilling status")
 				return false
 			}
 			tx <- st.Val(from)
 			return false
 		}
 		if !exists {
-			sarwin.ErrPrintln(g, "red_black", "Local File ", r.Fname, "does not exist for ", ttype)
+			l.Err("Local file does not exist", sarlog.F("file", fname), sarlog.F("ttype", ttype))
 			// Create STATUS and set errcode to "filenotfound"
 			if st.New("errcode=filenotfound", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create filenotfound status")
+				l.Err("Cannot create filenotfound status")
 				return false
 			}
 			tx <- st.Val(from)
 			return false
 		}
-		// Open the file and transfer here
 
 	// Delete the local file
 	case "delete":
 		if !exists {
-			sarwin.ErrPrintln(g, "red_black", "Local File ", r.Fname, "does not exist for ", ttype)
+			l.Err("Local file does not exist", sarlog.F("file", fname), sarlog.F("ttype", ttype))
 			// Create STATUS and set errcode to "filenotfound"
 			if st.New("errcode=filenotfound", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create filenotfound status")
+				l.Err("Cannot create filenotfound status")
 				return false
 			}
 			tx <- st.Val(from)
 			return false
 		}
 		// Delete the file
-		if err := fileio.FileRm(r.Fname); err != nil {
-			sarwin.ErrPrintln(g, "red_black", "Unable to remove  ", r.Fname)
+		if err := os.Remove(path); err != nil {
+			l.Err("Unable to remove", sarlog.F("file", fname), sarlog.F("err", err))
 			if st.New("errcode=didnotdelete", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create didnotdelete status")
+				l.Err("Cannot create didnotdelete status")
 				return false
 			}
 			tx <- st.Val(from)
 			return false
 		}
 		// Create STATUS and set errcode to "success" signalling deletion of the file
-		if st.New("errcode=success", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create success status")
+		if st.New(stflags, &sinfo) != nil {
+			l.Err("Cannot create success status")
 			return false
 		}
 		tx <- st.Val(from)
@@ -173,61 +212,135 @@ func AddRxTran(g *gocui.Gui, r *request.Request, from *net.UDPAddr, tx chan inte
 	// Open the local file to write to
 	case "put", "give":
 		if txwilling != "yes" {
-			sarwin.ErrPrintln(g, "red_black", "Cannot put as txwilling set to ", txwilling)
+			l.Err("Cannot put", sarlog.F("txwilling", txwilling))
 			// Create STATUS and set errcode to "cantsend"
 			if st.New("errcode=cantsend", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create cantsend status")
+				l.Err("Cannot create cantsend status")
 				return false
 			}
 			tx <- st.Val(from)
 			return false
 		}
 		if exists {
-			sarwin.ErrPrintln(g, "red_black", "Local File ", r.Fname, "already exists for ", ttype)
+			l.Err("Local file already exists", sarlog.F("file", fname), sarlog.F("ttype", ttype))
 			// Create STATUS and set errcode to "fileinuse"
 			if st.New("errcode=fileinuse", &sinfo) != nil {
-				sarwin.ErrPrintln(g, "red_black", "Cannot create fileinuse status")
+				l.Err("Cannot create fileinuse status")
 				return false
 			}
 			tx <- st.Val(from)
 			return false
 		}
-		// Create the file and transfer here
 	default:
-		sarwin.ErrPrintln(g, "red_black", "Invalid request")
+		l.Err("Invalid request")
 		// Create STATUS and set errcode to "badrequest"
 		if st.New("errcode=badrequest", &sinfo) != nil {
-			sarwin.ErrPrintln(g, "red_black", "Cannot create badrequest status")
+			l.Err("Cannot create badrequest status")
+			return false
+		}
+		tx <- st.Val(from)
+		return false
+	}
+	// We are the responder for the rest of the transfer
+	t, err := e.NewResponder(l, conn, r, fname, from, eid)
+	if err != nil {
+		l.Err("Cannot start transfer", sarlog.F("peer", from), sarlog.F("ttype", ttype), sarlog.F("file", fname),
+			sarlog.F("err", err))
+		errcode := "unspecified"
+		if errors.Is(err, sarwin.ErrDuplicate) { // The session is already in use by the peer
+			errcode = "badrequest"
+		}
+		if st.New("errcode="+errcode, &sinfo) != nil {
+			l.Err("Cannot create " + errcode + " status")
 			return false
 		}
 		tx <- st.Val(from)
 		return false
 	}
-	timetype := "epoch_2000_32"
-	ts := new(timestamp.Timestamp)
-	ts.Now(timetype)
-	dent := new(dirent.DirEnt)
+	// Create STATUS and set errcode to "success"
+	if st.New(stflags, &sinfo) != nil {
+		l.Err("Cannot create success status")
+		t.Cancel(l, false)
+		return false
+	}
+	tx <- st.Val(from)
+	t.Agreed(l) // If we are sending then off we go
+	return true
+}
+
+// We have received a metadata frame from a remote host that is sending us a file
+// Make sure the file will fit before we accept it or allocate anything for it
+// Send a filetobig status back via the tx channel if it will not
+// Space is only held for a transfer we have agreed to receive, until it is over
+func MetadataRx(l sarlog.Logger, e *sarwin.Engine, m *metadata.MetaData, from *net.UDPAddr, tx chan interface{}) bool {
+	var st status.Status
+	sinfo := status.Sinfo{Session: m.Session, Progress: 0, Inrespto: 0, Holes: nil}
+
+	t := e.Transfers.Match(from.String(), m.Session)
+	if t == nil || t.Sender() {
+		l.Err("Metadata for no transfer we are receiving", sarlog.F("peer", from), sarlog.F("session", m.Session))
+		return false
+	}
+	// An encrypted transfers metadata must have been sealed with its key, whatever it says
+	if err := sarcrypt.Check(t.Crypt, m.Header); err != nil {
+		l.Err("Dropping metadata for "+t.Print(), sarlog.F("err", err))
+		return false
+	}
+	if t.Crypt != nil {
+		path, err := t.Crypt.OpenPath(m.Header, m.Dir.Path)
+		if err != nil {
+			l.Err("Dropping metadata for "+t.Print(), sarlog.F("err", err))
+			return false
+		}
+		m.Dir.Path = path
+	}
+	// The sender has given up on the transfer
+	if sarflags.MetadataHeader(m.Header).Progress() == sarflags.ProgressTerminated {
+		t.PeerCancelled(l, "progress terminated")
+		return false
+	}
+	if !t.Running() {
+		return false
+	}
 
-	// Do some error checking to see that the transfer is not already underway
+	// Quotas go by the EID the peer proved it was
+	if err := e.Limits.Check(from.IP, t.Eid, m.Session, m.Dir.Size); err != nil {
+		l.Err("Refusing file", sarlog.F("peer", from.IP), sarlog.F("file", m.Dir.Path), sarlog.F("err", err))
+		// Create STATUS and set errcode to "filetobig"
+		if st.New("errcode=filetobig", &sinfo) != nil {
+			l.Err("Cannot create filetobig status")
+			return false
+		}
+		tx <- st.Val(from)
+		return false
+	}
 
-	// Initialise the Transfer with the information we have in the request
-	t := Transfer{Peer: *from, Session: r.Session, Ttype: ttype,
-		Filename: r.Fname,
-		Timetype: timetype, Tstamp: *ts,
-		Stflags: "", Csumtype: "none", Checksum: nil,
-		Havemeta: false, Dir: *dent, Fp: nil,
-		Data: nil, Dcount: 0,
-		Progress: 0, Inrespto: 0, CurFills: nil}
+	// It fits so now the transfer can set itself up to receive it
+	if err := t.Change(l, *m); err != nil {
+		l.Err("Cannot change transfer "+t.Print(), sarlog.F("err", err))
+		t.Cancel(l, true)
+		return false
+	}
+	// It may have been cancelled or timed out while we checked, cancelling released what it had
+	if !t.Running() {
+		e.Limits.Release(from.IP, m.Session)
+		return false
+	}
+	return true
+}
 
-	// Add the transfer to our current slice
-	Trmu.Lock()
-	Transfers = append(Transfers, t)
-	Trmu.Unlock()
-	// Create STATUS and set errcode to "success"
-	if st.New("errcode=success", &sinfo) != nil {
-		sarwin.ErrPrintln(g, "red_black", "Cannot create success status")
+// We have received a data frame from a remote host that is sending us a file
+// Hand it to the transfer receiving it, anything else is dropped
+func DataRx(l sarlog.Logger, e *sarwin.Engine, d *data.Data, from *net.UDPAddr) bool {
+	t := e.Transfers.Match(from.String(), d.Session)
+	if t == nil {
+		l.Err("Data for no transfer", sarlog.F("peer", from), sarlog.F("session", d.Session))
 		return false
 	}
-	tx <- st.Val(from)
+	if t.Sender() {
+		l.Err("Data for a transfer we are sending "+t.Print(), sarlog.F("peer", from))
+		return false
+	}
+	t.DataRx(l, d)
 	return true
 }